@@ -148,6 +148,13 @@ func routes(w *web.Web) {
 				return "/v1/exec/" + c.Params["query_set"] + "/view/" + c.Params["view_name"] + "/" + c.Params["item_key"]
 			}))
 
+	// Get a page of the items from the view :view_name on this :item_key.
+	w.Handle("GET", "/v1/exec/view/:view_name/:item_key",
+		handlers.Proxy(xeniadURL,
+			func(c *web.Context) string {
+				return "/v1/exec/view/" + c.Params["view_name"] + "/" + c.Params["item_key"]
+			}))
+
 	// Get all the items from the view :view_name on this :item_key.
 	w.Handle("POST", "/v1/exec/view/:view_name/:item_key",
 		handlers.Proxy(xeniadURL,
@@ -155,6 +162,28 @@ func routes(w *web.Web) {
 				return "/v1/exec/view/" + c.Params["view_name"] + "/" + c.Params["item_key"]
 			}))
 
+	// Check the consistency of the graph with the items.
+	w.Handle("GET", "/v1/graph/check",
+		handlers.Proxy(xeniadURL, func(c *web.Context) string { return "/v1/graph/check" }))
+
+	// Get the items adjacent to :item_key in the graph.
+	w.Handle("GET", "/v1/graph/:item_key/neighbors",
+		handlers.Proxy(xeniadURL,
+			func(c *web.Context) string {
+				return "/v1/graph/" + c.Params["item_key"] + "/neighbors"
+			}))
+
+	// Get the shortest path between :item_key and :to in the graph.
+	w.Handle("GET", "/v1/graph/:item_key/shortest_path/:to",
+		handlers.Proxy(xeniadURL,
+			func(c *web.Context) string {
+				return "/v1/graph/" + c.Params["item_key"] + "/shortest_path/" + c.Params["to"]
+			}))
+
+	// Execute a batch of xenia queries.
+	w.Handle("POST", "/v1/exec/batch",
+		handlers.Proxy(xeniadURL, func(c *web.Context) string { return "/v1/exec/batch" }))
+
 	// Execute xenia queries directly.
 	w.Handle("GET", "/v1/exec/:query_set",
 		handlers.Proxy(xeniadURL, func(c *web.Context) string { return "/v1/exec/" + c.Params["query_set"] }))
@@ -92,7 +92,7 @@ func loadItems(context interface{}, db *db.DB, store *cayley.Handle) error {
 	}
 
 	for _, itm := range items {
-		if err := sponge.Import(context, db, store, &itm); err != nil {
+		if _, err := sponge.Import(context, db, store, &itm); err != nil {
 			return err
 		}
 	}
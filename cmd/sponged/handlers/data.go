@@ -9,6 +9,7 @@ import (
 	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/coralproject/shelf/internal/sponge"
 	"github.com/coralproject/shelf/internal/sponge/item"
+	"github.com/coralproject/shelf/internal/wire"
 )
 
 // dataHandle maintains the set of handlers for the data api, which is responsible
@@ -26,7 +27,8 @@ const defaultVersion = 1
 //==============================================================================
 
 // Import receives POSTed data, itemizes it then imports it via the item API.
-// 204 SuccessNoContent, 400 Bad Request, 404 Not Found, 500 Internal.
+// The item is returned with the report of the relationships inferred for it.
+// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal.
 func (dataHandle) Import(c *web.Context) error {
 
 	// Unmarshall the data packet from the Request Body.
@@ -56,11 +58,15 @@ func (dataHandle) Import(c *web.Context) error {
 
 	// Upsert the item into the items collection and add/remove necessary
 	// quads to/from the graph.
-	if err := sponge.Import(c.SessionID, db, graphHandle, &itm); err != nil {
+	report, err := sponge.Import(c.SessionID, db, graphHandle, &itm)
+	if err != nil {
+		if _, ok := err.(*wire.RequiredError); ok {
+			c.RespondError(err.Error(), http.StatusBadRequest)
+			return nil
+		}
 		return err
 	}
 
-	// Respond with no content success.
-	c.Respond(itm, http.StatusOK)
+	c.Respond(importResult{itm, report}, http.StatusOK)
 	return nil
 }
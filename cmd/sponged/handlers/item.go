@@ -10,6 +10,7 @@ import (
 	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/coralproject/shelf/internal/sponge"
 	"github.com/coralproject/shelf/internal/sponge/item"
+	"github.com/coralproject/shelf/internal/wire"
 )
 
 // itemHandle maintains the set of handlers for theitem api.
@@ -18,6 +19,13 @@ type itemHandle struct{}
 // Item fronts the access to the item service functionality.
 var Item itemHandle
 
+// importResult is the response to an import. It contains the imported item and
+// the report of the relationships inferred for it.
+type importResult struct {
+	item.Item
+	Report *wire.InferenceReport `json:"report"`
+}
+
 //==============================================================================
 
 // Retrieve returns the items, specified by IDs, from the system.
@@ -40,8 +48,9 @@ func (itemHandle) Retrieve(c *web.Context) error {
 //==============================================================================
 
 // Import inserts or updates the posted Item document into the items collection
-// and adds/removes any necessary quads to/from the relationship graph.
-// 204 SuccessNoContent, 400 Bad Request, 404 Not Found, 500 Internal
+// and adds/removes any necessary quads to/from the relationship graph. The
+// item is returned with the report of the relationships inferred for it.
+// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
 func (itemHandle) Import(c *web.Context) error {
 
 	// Decode the item.
@@ -59,19 +68,26 @@ func (itemHandle) Import(c *web.Context) error {
 
 	// Upsert the item into the items collection and add/remove necessary
 	// quads to/from the graph.
-	if err := sponge.Import(c.SessionID, db, graphHandle, &itm); err != nil {
+	report, err := sponge.Import(c.SessionID, db, graphHandle, &itm)
+	if err != nil {
+		if _, ok := err.(*wire.RequiredError); ok {
+			c.RespondError(err.Error(), http.StatusBadRequest)
+			return nil
+		}
 		return err
 	}
 
-	c.Respond(itm, http.StatusOK)
+	c.Respond(importResult{itm, report}, http.StatusOK)
 	return nil
 }
 
 //==============================================================================
 
 // Remove removes the specified Item from the items collection and removes any
-// relevant quads from the graph database.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// relevant quads from the graph database, applying the on delete policies of
+// the relationships other items hold to it. With the dry_run query parameter
+// set to true nothing is removed and the changes are returned instead.
+// 200 Success, 204 SuccessNoContent, 400 Bad Request, 404 Not Found, 409 Conflict, 500 Internal
 func (itemHandle) Remove(c *web.Context) error {
 	db := c.Ctx["DB"].(*db.DB)
 
@@ -80,7 +96,27 @@ func (itemHandle) Remove(c *web.Context) error {
 		return err
 	}
 
+	if c.Request.URL.Query().Get("dry_run") == "true" {
+		plan, err := sponge.PlanRemove(c.SessionID, db, graphHandle, c.Params["id"])
+		if err != nil {
+			if err == item.ErrNotFound {
+				err = web.ErrNotFound
+			}
+			return err
+		}
+
+		c.Respond(plan, http.StatusOK)
+		return nil
+	}
+
 	if err := sponge.Remove(c.SessionID, db, graphHandle, c.Params["id"]); err != nil {
+		if _, ok := err.(*sponge.RestrictError); ok {
+			c.RespondError(err.Error(), http.StatusConflict)
+			return nil
+		}
+		if err == item.ErrNotFound {
+			err = web.ErrNotFound
+		}
 		return err
 	}
 
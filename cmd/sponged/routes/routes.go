@@ -16,6 +16,7 @@ import (
 	errorm "github.com/coralproject/shelf/internal/platform/midware/error"
 	logm "github.com/coralproject/shelf/internal/platform/midware/log"
 	"github.com/coralproject/shelf/internal/platform/midware/mongo"
+	"github.com/coralproject/shelf/internal/wire"
 )
 
 const (
@@ -33,6 +34,20 @@ const (
 
 	// cfgEnableCORS is set the key to the state for CORS on the service.
 	cfgEnableCORS = "ENABLE_CORS"
+
+	// cfgTypeCheck is the key for the policy used for relationships between
+	// items of types not declared by the relationship: reject, warn or allow.
+	cfgTypeCheck = "TYPE_CHECK"
+
+	// cfgGraphBackend is the key for the backend holding the graph of
+	// relationships: mongo, memstore or bolt. Defaults to mongo. The bolt
+	// backend keeps the whole graph in memory and only persists it to a file,
+	// so the graph must fit in RAM.
+	cfgGraphBackend = "GRAPH_BACKEND"
+
+	// cfgGraphPath is the key for the file holding the graph with the bolt
+	// backend.
+	cfgGraphPath = "GRAPH_PATH"
 )
 
 func init() {
@@ -56,6 +71,25 @@ func API() http.Handler {
 		os.Exit(1)
 	}
 
+	// Configure the backend holding the graph of relationships.
+	if backend, err := cfg.String(cfgGraphBackend); err == nil && backend != "" {
+		path, _ := cfg.String(cfgGraphPath)
+		if err := db.SetGraphBackend(backend, path); err != nil {
+			log.Error("startup", "Init", err, "Initializing Graph Backend")
+			os.Exit(1)
+		}
+		log.Dev("startup", "Init", "Graph Backend : Backend[%s] Path[%s]", backend, path)
+	}
+
+	// Configure the default policy for relationship type constraints.
+	if policy, err := cfg.String(cfgTypeCheck); err == nil {
+		if err := wire.SetTypeCheck(policy); err != nil {
+			log.Error("startup", "Init", err, "Initializing Type Check")
+			os.Exit(1)
+		}
+		log.Dev("startup", "Init", "Type Check : Policy[%s]", policy)
+	}
+
 	w := web.New(logm.Midware, errorm.Midware)
 
 	publicKey, err := cfg.String(cfgAuthPublicKey)
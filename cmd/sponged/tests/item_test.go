@@ -8,11 +8,10 @@ import (
 	"net/http/httptest"
 	"testing"
 
-	"github.com/ardanlabs/kit/cfg"
 	"github.com/ardanlabs/kit/tests"
 	"github.com/cayleygraph/cayley"
 	"github.com/cayleygraph/cayley/quad"
-	cayleyshelf "github.com/coralproject/shelf/internal/platform/db/cayley"
+	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/coralproject/shelf/internal/sponge/item"
 	"github.com/coralproject/shelf/internal/sponge/item/itemfix"
 )
@@ -24,7 +23,18 @@ const itemPrefix = "ITEST_"
 func setup(t *testing.T) *cayley.Handle {
 	tests.ResetLog()
 
-	store, err := cayleyshelf.New(cfg.MustURL("MONGO_URI").String(), nil)
+	mgoDB, err := db.NewMGO(tests.Context, tests.TestSession)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to get a Mongo session : %s", tests.Failed, err)
+	}
+	defer mgoDB.CloseMGO(tests.Context)
+
+	// The in-memory graph is shared with the handlers of the app.
+	if err := mgoDB.NewCayley(tests.Context, tests.TestSession); err != nil {
+		t.Fatalf("\t%s\tShould be able to connect to the cayley graph : %s", tests.Failed, err)
+	}
+
+	store, err := mgoDB.GraphHandle(tests.Context)
 	if err != nil {
 		t.Fatalf("\t%s\tShould be able to connect to the cayley graph : %s", tests.Failed, err)
 	}
@@ -38,6 +38,13 @@ func runTest(m *testing.M) int {
 	// Setup the app for performing tests.
 	a = routes.API()
 
+	// Keep the graph in memory so the tests don't depend on the quads
+	// stored in MongoDB, whatever backend the configuration selects.
+	if err := db.SetGraphBackend(db.GraphMemory, ""); err != nil {
+		fmt.Println("Can't use the in-memory graph: " + err.Error())
+		return 1
+	}
+
 	// Snatch the mongo session so we can create some test data.
 	db, err := db.NewMGO(tests.Context, tests.TestSession)
 	if err != nil {
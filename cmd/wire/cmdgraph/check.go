@@ -0,0 +1,47 @@
+package cmdgraph
+
+import (
+	"encoding/json"
+
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/spf13/cobra"
+)
+
+var checkLong = `Use check to compare the graph with the quads expected from the items
+and their patterns. Missing, extra and dangling quads are reported.
+
+Example:
+	graph check
+`
+
+// addCheck handles checking the consistency of the graph.
+func addCheck() {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check compares the graph with the items.",
+		Long:  checkLong,
+		RunE:  runCheck,
+	}
+
+	graphCmd.AddCommand(cmd)
+}
+
+// runCheck is the code that implements the check command.
+func runCheck(cmd *cobra.Command, args []string) error {
+	cmd.Println("Checking Graph")
+
+	r, err := wire.CheckGraph("", mgoDB, graphDB)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the results for printing.
+	data, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("\n%s\n\n", string(data))
+	cmd.Printf("Checking Graph : Missing[%d] Extra[%d] Dangling[%d]\n", len(r.Missing), len(r.Extra), len(r.Dangling))
+	return nil
+}
@@ -0,0 +1,38 @@
+package cmdgraph
+
+import (
+	"github.com/cayleygraph/cayley"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/spf13/cobra"
+)
+
+// graphCmd represents the parent for all graph cli commands.
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "graph provides a CLI for exploring the graph.",
+}
+
+var (
+	// mgoDB holds the session for the DB access.
+	mgoDB *db.DB
+
+	// graphDB holds the graph handle for graph access.
+	graphDB *cayley.Handle
+)
+
+// GetCommands returns the graph commands.
+func GetCommands(conn *db.DB, store *cayley.Handle) *cobra.Command {
+	mgoDB = conn
+	graphDB = store
+
+	addNeighbors()
+	addPath()
+	addCheck()
+	addRebuild()
+	addExport()
+	addImport()
+	addDerive()
+	addRule()
+	addStats()
+	return graphCmd
+}
@@ -0,0 +1,39 @@
+package cmdgraph
+
+import (
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/spf13/cobra"
+)
+
+var deriveLong = `Use derive to recompute the relationships derived by the rules. Missing
+derived relationships are added and the ones no longer derived, including
+those of deleted rules, are removed.
+
+Example:
+	graph derive
+`
+
+// addDerive handles recomputing the derived relationships.
+func addDerive() {
+	cmd := &cobra.Command{
+		Use:   "derive",
+		Short: "Derive recomputes the relationships derived by the rules.",
+		Long:  deriveLong,
+		RunE:  runDerive,
+	}
+
+	graphCmd.AddCommand(cmd)
+}
+
+// runDerive is the code that implements the derive command.
+func runDerive(cmd *cobra.Command, args []string) error {
+	cmd.Println("Deriving Graph")
+
+	r, err := wire.DeriveGraph("", mgoDB, graphDB)
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("Deriving Graph : Rules[%d] Derived[%d] Added[%d] Removed[%d]\n", r.Rules, r.Derived, len(r.Added), len(r.Removed))
+	return nil
+}
@@ -0,0 +1,82 @@
+package cmdgraph
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/spf13/cobra"
+)
+
+var exportLong = `Use export to write the quads in the graph as N-Quads, JSON-LD or
+Graphviz DOT. The quads can be limited to predicates and to the
+relationships of items of some types.
+
+Example:
+	graph export -f nquads -o graph.nq
+
+	graph export -f dot -p coral_authored,coral_on -t coral_comment
+`
+
+// export contains the state for this command.
+var export struct {
+	format     string
+	output     string
+	predicates string
+	types      string
+}
+
+// addExport handles exporting the graph.
+func addExport() {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export writes the quads in the graph.",
+		Long:  exportLong,
+		RunE:  runExport,
+	}
+
+	cmd.Flags().StringVarP(&export.format, "format", "f", wire.FormatNQuads, "Format: nquads, jsonld or dot")
+	cmd.Flags().StringVarP(&export.output, "output", "o", "", "Output file, standard output by default")
+	cmd.Flags().StringVarP(&export.predicates, "predicates", "p", "", "Comma separated predicates to export")
+	cmd.Flags().StringVarP(&export.types, "types", "t", "", "Comma separated item types to export the relationships of")
+
+	graphCmd.AddCommand(cmd)
+}
+
+// runExport is the code that implements the export command.
+func runExport(cmd *cobra.Command, args []string) error {
+	cmd.Printf("Exporting Graph : Format[%s]\n", export.format)
+
+	var w io.Writer = os.Stdout
+	if export.output != "" {
+		f, err := os.Create(export.output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	filter := wire.ExportFilter{
+		Predicates: splitList(export.predicates),
+		Types:      splitList(export.types),
+	}
+
+	n, err := wire.ExportGraph("", mgoDB, graphDB, w, export.format, filter)
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("Exporting Graph : Exported[%d]\n", n)
+	return nil
+}
+
+// splitList splits a comma separated list, returning nil for an empty list.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
@@ -0,0 +1,72 @@
+package cmdgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/spf13/cobra"
+)
+
+var importLong = `Use import to load N-Quads into the graph. Every predicate must have a
+relationship definition and the types of the related items are checked
+against it. Nothing is loaded if a quad is invalid.
+
+Example:
+	graph import -i graph.nq
+
+	graph import -i graph.nq -b 500
+`
+
+// imp contains the state for this command.
+var imp struct {
+	input     string
+	batchSize int
+}
+
+// addImport handles importing quads into the graph.
+func addImport() {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import loads N-Quads into the graph.",
+		Long:  importLong,
+		RunE:  runImport,
+	}
+
+	cmd.Flags().StringVarP(&imp.input, "input", "i", "", "N-Quads file")
+	cmd.Flags().IntVarP(&imp.batchSize, "batch", "b", wire.DefaultBatchSize, "Number of quads applied at a time")
+
+	graphCmd.AddCommand(cmd)
+}
+
+// runImport is the code that implements the import command.
+func runImport(cmd *cobra.Command, args []string) error {
+	cmd.Printf("Importing Graph : File[%s]\n", imp.input)
+
+	// Validate the input parameters.
+	if imp.input == "" {
+		return fmt.Errorf("input file must be specified")
+	}
+
+	f, err := os.Open(imp.input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := wire.ImportGraph("", mgoDB, graphDB, f, imp.batchSize)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the results for printing.
+	data, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("\n%s\n\n", string(data))
+	cmd.Printf("Importing Graph : Added[%d] Existing[%d]\n", r.Added, r.Existing)
+	return nil
+}
@@ -0,0 +1,77 @@
+package cmdgraph
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/spf13/cobra"
+)
+
+var neighborsLong = `Use neighbors to list the items adjacent to an item.
+
+Example:
+	graph neighbors -i itemkey
+
+	graph neighbors -i itemkey -p predicate -d in --depth 2 --hydrate
+`
+
+// neighbors contains the state for this command.
+var neighbors struct {
+	itemKey   string
+	predicate string
+	direction string
+	depth     int
+	hydrate   bool
+}
+
+// addNeighbors handles listing the neighbors of an item.
+func addNeighbors() {
+	cmd := &cobra.Command{
+		Use:   "neighbors",
+		Short: "Neighbors lists the items adjacent to an item.",
+		Long:  neighborsLong,
+		RunE:  runNeighbors,
+	}
+
+	cmd.Flags().StringVarP(&neighbors.itemKey, "key", "i", "", "Item key")
+	cmd.Flags().StringVarP(&neighbors.predicate, "predicate", "p", "", "Predicate to follow")
+	cmd.Flags().StringVarP(&neighbors.direction, "direction", "d", "", "Direction to follow, in or out")
+	cmd.Flags().IntVar(&neighbors.depth, "depth", 1, "Number of hops to follow")
+	cmd.Flags().BoolVar(&neighbors.hydrate, "hydrate", false, "Include the items")
+
+	graphCmd.AddCommand(cmd)
+}
+
+// runNeighbors is the code that implements the neighbors command.
+func runNeighbors(cmd *cobra.Command, args []string) error {
+	cmd.Printf("Listing Neighbors : Item[%s]\n", neighbors.itemKey)
+
+	// Validate the input parameters.
+	if neighbors.itemKey == "" {
+		return fmt.Errorf("item key must be specified")
+	}
+
+	params := wire.NeighborParams{
+		ItemKey:   neighbors.itemKey,
+		Predicate: neighbors.predicate,
+		Direction: neighbors.direction,
+		Depth:     neighbors.depth,
+		Hydrate:   neighbors.hydrate,
+	}
+
+	n, err := wire.GetNeighbors("", mgoDB, graphDB, &params)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the results for printing.
+	data, err := json.MarshalIndent(n, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("\n%s\n\n", string(data))
+	cmd.Println("\n", "Listing Neighbors : Listed")
+	return nil
+}
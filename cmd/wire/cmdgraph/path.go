@@ -0,0 +1,67 @@
+package cmdgraph
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/spf13/cobra"
+)
+
+var pathLong = `Use path to find the shortest path between two items.
+
+Example:
+	graph path -f itemkey -t itemkey
+
+	graph path -f itemkey -t itemkey -p predicate --hydrate
+`
+
+// shortest contains the state for this command.
+var shortest struct {
+	from      string
+	to        string
+	predicate string
+	hydrate   bool
+}
+
+// addPath handles finding the shortest path between two items.
+func addPath() {
+	cmd := &cobra.Command{
+		Use:   "path",
+		Short: "Path finds the shortest path between two items.",
+		Long:  pathLong,
+		RunE:  runPath,
+	}
+
+	cmd.Flags().StringVarP(&shortest.from, "from", "f", "", "Item key to start from")
+	cmd.Flags().StringVarP(&shortest.to, "to", "t", "", "Item key to reach")
+	cmd.Flags().StringVarP(&shortest.predicate, "predicate", "p", "", "Predicate to follow")
+	cmd.Flags().BoolVar(&shortest.hydrate, "hydrate", false, "Include the items")
+
+	graphCmd.AddCommand(cmd)
+}
+
+// runPath is the code that implements the path command.
+func runPath(cmd *cobra.Command, args []string) error {
+	cmd.Printf("Finding Path : From[%s] To[%s]\n", shortest.from, shortest.to)
+
+	// Validate the input parameters.
+	if shortest.from == "" || shortest.to == "" {
+		return fmt.Errorf("from and to item keys must be specified")
+	}
+
+	gp, err := wire.ShortestPath("", mgoDB, graphDB, shortest.from, shortest.to, shortest.predicate, shortest.hydrate)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the results for printing.
+	data, err := json.MarshalIndent(gp, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("\n%s\n\n", string(data))
+	cmd.Println("\n", "Finding Path : Found")
+	return nil
+}
@@ -0,0 +1,51 @@
+package cmdgraph
+
+import (
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/spf13/cobra"
+)
+
+var rebuildLong = `Use rebuild to reconcile the graph with the items by adding the missing
+quads and removing the extra and dangling ones.
+
+Example:
+	graph rebuild
+
+	graph rebuild -b 500
+`
+
+// rebuild contains the state for this command.
+var rebuild struct {
+	batchSize int
+}
+
+// addRebuild handles rebuilding the graph.
+func addRebuild() {
+	cmd := &cobra.Command{
+		Use:   "rebuild",
+		Short: "Rebuild reconciles the graph with the items.",
+		Long:  rebuildLong,
+		RunE:  runRebuild,
+	}
+
+	cmd.Flags().IntVarP(&rebuild.batchSize, "batch", "b", wire.DefaultBatchSize, "Number of quads applied at a time")
+
+	graphCmd.AddCommand(cmd)
+}
+
+// runRebuild is the code that implements the rebuild command.
+func runRebuild(cmd *cobra.Command, args []string) error {
+	cmd.Println("Rebuilding Graph")
+
+	progress := func(done, total int) {
+		cmd.Printf("Rebuilding Graph : %d/%d quads\n", done, total)
+	}
+
+	r, err := wire.RebuildGraph("", mgoDB, graphDB, rebuild.batchSize, progress)
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("Rebuilding Graph : Added[%d] Removed[%d] Reconciled[%d]\n", len(r.Missing), len(r.Extra)+len(r.Dangling), r.Reconciled)
+	return nil
+}
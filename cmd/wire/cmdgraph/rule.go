@@ -0,0 +1,122 @@
+package cmdgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/coralproject/shelf/internal/wire/rule"
+	"github.com/spf13/cobra"
+)
+
+var ruleLong = `Use rule to manage the rules deriving relationships from chains of
+relationships. Run graph derive after changing the rules to update the
+derived relationships.
+
+Example:
+	graph rule upsert -p rule.json
+
+	graph rule list
+
+	graph rule delete -n participated
+`
+
+// ruleCmd represents the parent for the rule commands.
+var ruleCmd = &cobra.Command{
+	Use:   "rule",
+	Short: "Rule manages the rules deriving relationships.",
+	Long:  ruleLong,
+}
+
+// rl contains the state for the rule commands.
+var rl struct {
+	path string
+	name string
+}
+
+// addRule handles managing the rules.
+func addRule() {
+	upsert := &cobra.Command{
+		Use:   "upsert",
+		Short: "Upsert adds or updates a rule from a JSON file.",
+		RunE:  runRuleUpsert,
+	}
+	upsert.Flags().StringVarP(&rl.path, "path", "p", "", "Path to the rule file")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List shows the rules.",
+		RunE:  runRuleList,
+	}
+
+	del := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete removes a rule.",
+		RunE:  runRuleDelete,
+	}
+	del.Flags().StringVarP(&rl.name, "name", "n", "", "Name of the rule")
+
+	ruleCmd.AddCommand(upsert, list, del)
+	graphCmd.AddCommand(ruleCmd)
+}
+
+// runRuleUpsert is the code that implements the rule upsert command.
+func runRuleUpsert(cmd *cobra.Command, args []string) error {
+	cmd.Printf("Upserting Rule : Path[%s]\n", rl.path)
+
+	if rl.path == "" {
+		return fmt.Errorf("path must be provided")
+	}
+
+	f, err := os.Open(rl.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r rule.Rule
+	if err := json.NewDecoder(f).Decode(&r); err != nil {
+		return err
+	}
+
+	if err := rule.Upsert("", mgoDB, &r); err != nil {
+		return err
+	}
+
+	cmd.Printf("Upserting Rule : Name[%s] Upserted\n", r.Name)
+	return nil
+}
+
+// runRuleList is the code that implements the rule list command.
+func runRuleList(cmd *cobra.Command, args []string) error {
+	cmd.Println("Listing Rules")
+
+	rules, err := rule.GetAll("", mgoDB)
+	if err != nil && err != rule.ErrNotFound {
+		return err
+	}
+
+	data, err := json.MarshalIndent(rules, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("\n%s\n\n", string(data))
+	return nil
+}
+
+// runRuleDelete is the code that implements the rule delete command.
+func runRuleDelete(cmd *cobra.Command, args []string) error {
+	cmd.Printf("Deleting Rule : Name[%s]\n", rl.name)
+
+	if rl.name == "" {
+		return fmt.Errorf("name must be provided")
+	}
+
+	if err := rule.Delete("", mgoDB, rl.name); err != nil {
+		return err
+	}
+
+	cmd.Printf("Deleting Rule : Name[%s] Deleted\n", rl.name)
+	return nil
+}
@@ -0,0 +1,56 @@
+package cmdgraph
+
+import (
+	"encoding/json"
+
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/spf13/cobra"
+)
+
+var statsLong = `Use stats to count the relationships per predicate and per triple of
+subject type, predicate and object type, the items and the orphans without
+relationships per type, and list the items with the most relationships.
+
+Example:
+	graph stats
+
+	graph stats -n 25
+`
+
+// stats contains the state for this command.
+var stats struct {
+	top int
+}
+
+// addStats handles reporting the graph statistics.
+func addStats() {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Stats reports statistics about the graph and the items.",
+		Long:  statsLong,
+		RunE:  runStats,
+	}
+
+	cmd.Flags().IntVarP(&stats.top, "top", "n", wire.DefaultStatsTop, "Number of items with the most relationships")
+
+	graphCmd.AddCommand(cmd)
+}
+
+// runStats is the code that implements the stats command.
+func runStats(cmd *cobra.Command, args []string) error {
+	cmd.Println("Graph Stats")
+
+	s, err := wire.Stats("", mgoDB, graphDB, stats.top)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("\n%s\n\n", string(data))
+	cmd.Printf("Graph Stats : Quads[%d] Derived[%d] Items[%d] Orphans[%d]\n", s.Quads, s.Derived, s.Items, s.Orphans)
+	return nil
+}
@@ -0,0 +1,30 @@
+package cmdrelationship
+
+import (
+	"github.com/cayleygraph/cayley"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/spf13/cobra"
+)
+
+// relationshipCmd represents the parent for all relationship cli commands.
+var relationshipCmd = &cobra.Command{
+	Use:   "relationship",
+	Short: "relationship provides a CLI for managing relationships.",
+}
+
+var (
+	// mgoDB holds the session for the DB access.
+	mgoDB *db.DB
+
+	// graphDB holds the graph handle for graph access.
+	graphDB *cayley.Handle
+)
+
+// GetCommands returns the relationship commands.
+func GetCommands(conn *db.DB, store *cayley.Handle) *cobra.Command {
+	mgoDB = conn
+	graphDB = store
+
+	addMigrate()
+	return relationshipCmd
+}
@@ -0,0 +1,70 @@
+package cmdrelationship
+
+import (
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/spf13/cobra"
+)
+
+var migrateLong = `Use migrate to rename a predicate. The patterns, relationships, views and
+rules referencing the predicate are updated, then its quads are rewritten in
+place in batches. Run it again to resume a migration that failed. A dry run
+lists the changes without making them.
+
+Example:
+	relationship migrate --from on --to on_asset --dry-run
+
+	relationship migrate --from on --to on_asset -b 500
+`
+
+// migrate contains the state for this command.
+var migrate struct {
+	from      string
+	to        string
+	batchSize int
+	dryRun    bool
+}
+
+// addMigrate handles renaming a predicate.
+func addMigrate() {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate renames a predicate in the documents and the graph.",
+		Long:  migrateLong,
+		RunE:  runMigrate,
+	}
+
+	cmd.Flags().StringVar(&migrate.from, "from", "", "Predicate to rename")
+	cmd.Flags().StringVar(&migrate.to, "to", "", "New name of the predicate")
+	cmd.Flags().IntVarP(&migrate.batchSize, "batch", "b", wire.DefaultBatchSize, "Number of quads rewritten at a time")
+	cmd.Flags().BoolVar(&migrate.dryRun, "dry-run", false, "List the changes without making them")
+
+	relationshipCmd.AddCommand(cmd)
+}
+
+// runMigrate is the code that implements the migrate command.
+func runMigrate(cmd *cobra.Command, args []string) error {
+	cmd.Printf("Migrating Predicate : From[%s] To[%s] DryRun[%v]\n", migrate.from, migrate.to, migrate.dryRun)
+
+	mp := wire.MigrateParams{
+		From:      migrate.from,
+		To:        migrate.to,
+		BatchSize: migrate.batchSize,
+		DryRun:    migrate.dryRun,
+	}
+
+	progress := func(done, total int) {
+		cmd.Printf("Migrating Predicate : %d/%d quads\n", done, total)
+	}
+
+	r, err := wire.MigratePredicate("", mgoDB, graphDB, &mp, progress)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range r.Changes {
+		cmd.Printf("%s %s %s : %s -> %s\n", c.Kind, c.Name, c.Field, c.Before, c.After)
+	}
+
+	cmd.Printf("Migrating Predicate : Changes[%d] Quads[%d]\n", len(r.Changes), r.Quads)
+	return nil
+}
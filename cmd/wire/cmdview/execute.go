@@ -3,8 +3,12 @@ package cmdview
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/coralproject/shelf/internal/wire"
+	"github.com/coralproject/shelf/internal/xenia"
+	"github.com/coralproject/shelf/internal/xenia/query"
 	"github.com/spf13/cobra"
 )
 
@@ -12,14 +16,31 @@ var executeLong = `Use execute to execute a view.
 
 Example:
 	view execute -n viewname -i itemkey -c resultscollection -b bufferlimit
+
+	view execute -n viewname -i itemkey -l 20 --sort=-data.date_created -u cursor
+
+	view execute -n viewname -i itemkey --from 2016-01-22T15:00:00Z --to 2016-01-22T16:00:00Z
+
+	view execute -n viewname -i itemkey1,itemkey2
+
+	view execute -n viewname -q setname -f data.asset_id
 `
 
 // execute contains the state for this command.
 var execute struct {
 	viewName          string
 	itemKey           string
+	set               string
+	field             string
 	resultsCollection string
 	bufferLimit       int
+	limit             int
+	offset            int
+	cursor            string
+	sort              string
+	asOf              string
+	from              string
+	to                string
 }
 
 // addExecute handles the execution of a view.
@@ -32,9 +53,18 @@ func addExecute() {
 	}
 
 	cmd.Flags().StringVarP(&execute.viewName, "name", "n", "", "View name")
-	cmd.Flags().StringVarP(&execute.itemKey, "key", "i", "", "Item key")
+	cmd.Flags().StringVarP(&execute.itemKey, "key", "i", "", "Item key, or comma separated item keys")
+	cmd.Flags().StringVarP(&execute.set, "set", "q", "", "Query set yielding the item keys")
+	cmd.Flags().StringVarP(&execute.field, "field", "f", "item_id", "Field of the query set documents holding the item keys")
 	cmd.Flags().StringVarP(&execute.resultsCollection, "collection", "c", "", "Results collection")
 	cmd.Flags().IntVarP(&execute.bufferLimit, "buffer", "b", 0, "Buffer Limit")
+	cmd.Flags().IntVarP(&execute.limit, "limit", "l", 0, "Maximum number of items")
+	cmd.Flags().IntVarP(&execute.offset, "offset", "o", 0, "Number of items to skip")
+	cmd.Flags().StringVarP(&execute.cursor, "cursor", "u", "", "Cursor of the next page")
+	cmd.Flags().StringVarP(&execute.sort, "sort", "s", "", "Item field to sort by, prefix with - for descending")
+	cmd.Flags().StringVar(&execute.asOf, "as-of", "", "Follow relationships created at or before this RFC3339 time")
+	cmd.Flags().StringVar(&execute.from, "from", "", "Follow relationships created at or after this RFC3339 time")
+	cmd.Flags().StringVar(&execute.to, "to", "", "Follow relationships created at or before this RFC3339 time")
 
 	viewCmd.AddCommand(cmd)
 }
@@ -44,8 +74,8 @@ func runExecute(cmd *cobra.Command, args []string) error {
 	cmd.Printf("Executing View : Name[%s]\n", execute.viewName)
 
 	// Validate the input parameters.
-	if execute.viewName == "" || execute.itemKey == "" {
-		return fmt.Errorf("view name and item key must be specified")
+	if execute.viewName == "" || (execute.itemKey == "" && execute.set == "") {
+		return fmt.Errorf("view name and item key or query set must be specified")
 	}
 
 	// Ready the view parameters.
@@ -54,6 +84,52 @@ func runExecute(cmd *cobra.Command, args []string) error {
 		ItemKey:           execute.itemKey,
 		ResultsCollection: execute.resultsCollection,
 		BufferLimit:       execute.bufferLimit,
+		Limit:             execute.limit,
+		Offset:            execute.offset,
+		Cursor:            execute.cursor,
+		Sort:              execute.sort,
+	}
+
+	// Start from several items, if requested.
+	var keys []string
+	if execute.itemKey != "" {
+		keys = strings.Split(execute.itemKey, ",")
+	}
+
+	if execute.set != "" {
+		set, err := query.GetByName("", mgoDB, execute.set)
+		if err != nil {
+			return err
+		}
+
+		ids, err := xenia.ExecIDs("", mgoDB, set, nil, execute.field)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, ids...)
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("query set yielded no item keys")
+	}
+
+	if len(keys) > 1 {
+		viewParams.ItemKey = ""
+		viewParams.ItemKeys = keys
+	}
+
+	// Limit the relationships followed to a time window, if requested.
+	for _, p := range []struct {
+		value string
+		dst   **time.Time
+	}{{execute.asOf, &viewParams.AsOf}, {execute.from, &viewParams.From}, {execute.to, &viewParams.To}} {
+		if p.value != "" {
+			t, err := time.Parse(time.RFC3339, p.value)
+			if err != nil {
+				return err
+			}
+			*p.dst = &t
+		}
 	}
 
 	// Execute the view.
@@ -0,0 +1,119 @@
+package cmdview
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/spf13/cobra"
+)
+
+var explainLong = `Use explain to show how a view would be executed without saving anything.
+
+The graph path of each path of the view is shown along with the items reached
+and tagged by each segment, how the tagged items are embedded, and warnings
+about unknown predicates or a start type not matching the first relationship.
+
+Example:
+	view explain -n viewname -i itemkey
+
+	view explain -n viewname -i itemkey1,itemkey2 --from 2016-01-22T15:00:00Z
+`
+
+// explain contains the state for this command.
+var explain struct {
+	viewName string
+	itemKey  string
+	asOf     string
+	from     string
+	to       string
+}
+
+// addExplain handles the explanation of a view.
+func addExplain() {
+	cmd := &cobra.Command{
+		Use:   "explain",
+		Short: "Explain shows how a view would be executed.",
+		Long:  explainLong,
+		RunE:  runExplain,
+	}
+
+	cmd.Flags().StringVarP(&explain.viewName, "name", "n", "", "View name")
+	cmd.Flags().StringVarP(&explain.itemKey, "key", "i", "", "Item key, or comma separated item keys")
+	cmd.Flags().StringVar(&explain.asOf, "as-of", "", "Follow relationships created at or before this RFC3339 time")
+	cmd.Flags().StringVar(&explain.from, "from", "", "Follow relationships created at or after this RFC3339 time")
+	cmd.Flags().StringVar(&explain.to, "to", "", "Follow relationships created at or before this RFC3339 time")
+
+	viewCmd.AddCommand(cmd)
+}
+
+// runExplain is the code that implements the explain command.
+func runExplain(cmd *cobra.Command, args []string) error {
+	cmd.Printf("Explaining View : Name[%s]\n", explain.viewName)
+
+	// Validate the input parameters.
+	if explain.viewName == "" || explain.itemKey == "" {
+		return fmt.Errorf("view name and item key must be specified")
+	}
+
+	// Ready the view parameters.
+	viewParams := wire.ViewParams{
+		ViewName: explain.viewName,
+		ItemKey:  explain.itemKey,
+	}
+
+	if keys := strings.Split(explain.itemKey, ","); len(keys) > 1 {
+		viewParams.ItemKey = ""
+		viewParams.ItemKeys = keys
+	}
+
+	// Limit the relationships followed to a time window, if requested.
+	for _, p := range []struct {
+		value string
+		dst   **time.Time
+	}{{explain.asOf, &viewParams.AsOf}, {explain.from, &viewParams.From}, {explain.to, &viewParams.To}} {
+		if p.value != "" {
+			t, err := time.Parse(time.RFC3339, p.value)
+			if err != nil {
+				return err
+			}
+			*p.dst = &t
+		}
+	}
+
+	// Explain the view.
+	ve, err := wire.Explain("", mgoDB, graphDB, &viewParams)
+	if err != nil {
+		return err
+	}
+
+	// Print the graph path of each path first, then the full explanation.
+	for _, root := range ve.Roots {
+		cmd.Printf("\nRoot : Key[%s] Items[%d]\n", root.ItemKey, root.Items)
+		for _, pe := range root.Paths {
+			cmd.Printf("\nPath %d : %s\n", pe.Path, pe.Query)
+			for _, se := range pe.Segments {
+				cmd.Printf("\tLevel[%d] %s(%s) Hops[%d-%d] Count[%d]", se.Level, se.Direction, se.Predicate, se.MinHops, se.MaxHops, se.Count)
+				if se.EmbedAs != "" {
+					cmd.Printf(" Embed[%s in %s]", se.EmbedAs, se.EmbedIn)
+				}
+				cmd.Println()
+			}
+		}
+	}
+
+	for _, w := range ve.Warnings {
+		cmd.Printf("\nWarning : %s", w)
+	}
+
+	data, err := json.MarshalIndent(ve, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("\n\n%s\n\n", string(data))
+	cmd.Println("\n", "Explaining View : Explained")
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/ardanlabs/kit/cfg"
 	"github.com/ardanlabs/kit/log"
 	"github.com/cayleygraph/cayley"
+	"github.com/coralproject/shelf/cmd/wire/cmdgraph"
+	"github.com/coralproject/shelf/cmd/wire/cmdrelationship"
 	"github.com/coralproject/shelf/cmd/wire/cmdview"
 	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/spf13/cobra"
@@ -19,6 +21,16 @@ const (
 
 	// cfgMongoURI is the key for the URI to the MongoDB service.
 	cfgMongoURI = "MONGO_URI"
+
+	// cfgGraphBackend is the key for the backend holding the graph of
+	// relationships: mongo, memstore or bolt. Defaults to mongo. The bolt
+	// backend keeps the whole graph in memory and only persists it to a file,
+	// so the graph must fit in RAM.
+	cfgGraphBackend = "GRAPH_BACKEND"
+
+	// cfgGraphPath is the key for the file holding the graph with the bolt
+	// backend.
+	cfgGraphPath = "GRAPH_PATH"
 )
 
 // wire includes information about the wire cobra command.
@@ -71,6 +83,14 @@ func main() {
 
 	// Configure Cayley.
 	wire.Println("Configuring Cayley")
+	if backend, err := cfg.String(cfgGraphBackend); err == nil && backend != "" {
+		path, _ := cfg.String(cfgGraphPath)
+		if err := db.SetGraphBackend(backend, path); err != nil {
+			wire.Println("Unable to use graph backend", backend)
+			os.Exit(1)
+		}
+	}
+
 	if err := mgoDB.NewCayley("startup", mongoURI.Path); err != nil {
 		wire.Println("Unable to get Cayley support")
 	}
@@ -81,8 +101,10 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Add the graph and view commands to the CLI tool.
+	// Add the graph, relationship and view commands to the CLI tool.
 	wire.AddCommand(
+		cmdgraph.GetCommands(mgoDB, graphDB),
+		cmdrelationship.GetCommands(mgoDB, graphDB),
 		cmdview.GetCommands(mgoDB, graphDB),
 	)
 
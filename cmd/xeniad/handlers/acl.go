@@ -0,0 +1,84 @@
+// Package handlers contains the handler logic for processing requests.
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/platform/auth"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/wire/view"
+	"github.com/coralproject/shelf/internal/xenia/query"
+	"github.com/coralproject/shelf/internal/xenia/script"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// claims returns the claims of the authenticated caller. A nil map is
+// returned when authentication is disabled.
+func claims(c *web.Context) map[string]interface{} {
+	if mc, ok := c.Ctx["claims"].(*jwt.MapClaims); ok && mc != nil {
+		return *mc
+	}
+
+	return nil
+}
+
+// allowed reports if the caller is granted the operation by the ACL.
+func allowed(c *web.Context, acl *auth.ACL, op string) bool {
+	return acl.Allowed(claims(c), op)
+}
+
+// authorize returns an error if the caller is not granted the operation on
+// the named resource by the ACL.
+func authorize(c *web.Context, acl *auth.ACL, op string, resource string) error {
+	if !allowed(c, acl, op) {
+		err := fmt.Errorf("%s access to %s denied", op, resource)
+		log.Error(c.SessionID, "authorize", err, "Checking ACL")
+		return web.ErrNotAuthorized
+	}
+
+	return nil
+}
+
+// authorizeExec returns an error if the caller is not granted execute access
+// to the set and to the pre/post scripts it uses.
+func authorizeExec(c *web.Context, db *db.DB, set *query.Set) error {
+	if err := authorize(c, set.ACL, auth.OpExec, "set "+set.Name); err != nil {
+		return err
+	}
+
+	for _, name := range []string{set.PreScript, set.PstScript} {
+		if name == "" {
+			continue
+		}
+
+		scr, err := script.GetByName(c.SessionID, db, name)
+		if err != nil {
+			if err == script.ErrNotFound {
+				continue
+			}
+			return err
+		}
+
+		if err := authorize(c, scr.ACL, auth.OpExec, "script "+scr.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// authorizeView returns an error if the caller is not granted execute access
+// to the named view. A missing view is left for the execution to report.
+func authorizeView(c *web.Context, db *db.DB, name string) error {
+	v, err := view.GetByName(c.SessionID, db, name)
+	if err != nil {
+		if err == view.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	return authorize(c, v.ACL, auth.OpExec, "view "+v.Name)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/ardanlabs/kit/tests"
+	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/platform/auth"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// TestMain initializes the logging of the handlers, which need no database to
+// be tested here.
+func TestMain(m *testing.M) {
+	log.Init(ioutil.Discard, func() int { return log.USER }, log.Ldefault)
+	os.Exit(m.Run())
+}
+
+// newContext returns a context for a request made with the given claims.
+func newContext(target string, mc jwt.MapClaims) *web.Context {
+	c := web.Context{
+		Request:   httptest.NewRequest("GET", target, nil),
+		Params:    map[string]string{},
+		SessionID: tests.Context,
+		Ctx:       map[string]interface{}{},
+	}
+
+	if mc != nil {
+		c.Ctx["claims"] = &mc
+	}
+
+	return &c
+}
+
+// TestAuthorize tests granting operations by the roles of the caller.
+func TestAuthorize(t *testing.T) {
+	acl := auth.ACL{
+		Read: []string{"analytics"},
+		Exec: []string{"analytics"},
+	}
+
+	t.Log("Given the need to grant operations by the roles of the caller.")
+	{
+		t.Log("\tWhen the caller holds a required role")
+		{
+			c := newContext("/v1/exec/set", jwt.MapClaims{"roles": []interface{}{"staff", "analytics"}})
+			if err := authorize(c, &acl, auth.OpExec, "set"); err != nil {
+				t.Fatalf("\t%s\tShould be granted the operation : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be granted the operation.", tests.Success)
+		}
+
+		t.Log("\tWhen the caller holds none of the required roles")
+		{
+			c := newContext("/v1/exec/set", jwt.MapClaims{"roles": "staff"})
+			if err := authorize(c, &acl, auth.OpExec, "set"); err != web.ErrNotAuthorized {
+				t.Fatalf("\t%s\tShould be denied the operation : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be denied the operation.", tests.Success)
+		}
+	}
+}
+
+// TestQueryVars tests the vars of the query string don't override the vars
+// of the route.
+func TestQueryVars(t *testing.T) {
+	t.Log("Given the need to read the vars of a set from the query string.")
+	{
+		t.Log("\tWhen the query string names another view")
+		{
+			c := newContext("/v1/exec/set/view/public/item?view=secret&item=other&limit=5", nil)
+			vars := queryVars(c, map[string]string{"view": "public", "item": "key"})
+
+			if vars["view"] != "public" || vars["item"] != "key" {
+				t.Fatalf("\t%s\tShould keep the view and item of the route : %v", tests.Failed, vars)
+			}
+			t.Logf("\t%s\tShould keep the view and item of the route.", tests.Success)
+
+			if vars["limit"] != "5" {
+				t.Fatalf("\t%s\tShould add the other vars : %v", tests.Failed, vars)
+			}
+			t.Logf("\t%s\tShould add the other vars.", tests.Success)
+		}
+	}
+}
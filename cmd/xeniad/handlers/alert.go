@@ -0,0 +1,217 @@
+// Package handlers contains the handler logic for processing requests.
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/xenia/alert"
+	"github.com/coralproject/shelf/internal/xenia/query"
+)
+
+// alertHandle maintains the set of handlers for the alert api.
+type alertHandle struct{}
+
+// Alert fronts the access to the alert service functionality.
+var Alert alertHandle
+
+//==============================================================================
+
+// List returns all the existing alerts in the system on sets the caller can
+// execute.
+// 200 Success, 404 Not Found, 500 Internal
+func (alertHandle) List(c *web.Context) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	alerts, err := alert.GetAll(c.SessionID, db)
+	if err != nil {
+		if err == alert.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	granted := make([]alert.Alert, 0, len(alerts))
+	for _, a := range alerts {
+		if err := authorizeAlert(c, db, a.Set, a.Params["view"]); err != nil {
+			if err == web.ErrNotAuthorized {
+				continue
+			}
+			return err
+		}
+
+		// Secrets are never handed back out.
+		a.Secret = ""
+		granted = append(granted, a)
+	}
+
+	c.Respond(granted, http.StatusOK)
+	return nil
+}
+
+// Retrieve returns the specified alert from the system.
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
+func (alertHandle) Retrieve(c *web.Context) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	a, err := alert.GetByName(c.SessionID, db, c.Params["name"])
+	if err != nil {
+		if err == alert.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authorizeAlert(c, db, a.Set, a.Params["view"]); err != nil {
+		return err
+	}
+
+	a.Secret = ""
+
+	c.Respond(a, http.StatusOK)
+	return nil
+}
+
+// History returns the most recent state changes of the specified alert. The
+// history of a removed alert is authorized against the set of its events.
+// 200 Success, 400 Bad Request, 401 Unauthorized, 500 Internal
+func (alertHandle) History(c *web.Context) error {
+	limit := 100
+	if l := c.Request.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return web.ErrValidation
+		}
+		limit = n
+	}
+
+	db := c.Ctx["DB"].(*db.DB)
+
+	events, err := alert.GetHistory(c.SessionID, db, c.Params["name"], limit)
+	if err != nil {
+		return err
+	}
+
+	a, err := alert.GetByName(c.SessionID, db, c.Params["name"])
+	switch {
+	case err == nil:
+		err = authorizeAlert(c, db, a.Set, a.Params["view"])
+	case err == alert.ErrNotFound && len(events) > 0:
+		err = authorizeAlert(c, db, events[0].Set, "")
+	case err == alert.ErrNotFound:
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Respond(events, http.StatusOK)
+	return nil
+}
+
+//==============================================================================
+
+// Upsert inserts or updates the posted alert document into the database. The
+// caller must be allowed to execute the set and view the alert uses, and the
+// ones of the alert it replaces.
+// 204 SuccessNoContent, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
+func (alertHandle) Upsert(c *web.Context) error {
+	var a alert.Alert
+	if err := json.NewDecoder(c.Request.Body).Decode(&a); err != nil {
+		return err
+	}
+
+	db := c.Ctx["DB"].(*db.DB)
+
+	set, err := query.GetByName(c.SessionID, db, a.Set)
+	if err != nil {
+		if err == query.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authorizeExec(c, db, set); err != nil {
+		return err
+	}
+
+	if name := a.Params["view"]; name != "" {
+		if err := authorizeView(c, db, name); err != nil {
+			return err
+		}
+	}
+
+	old, err := alert.GetByName(c.SessionID, db, a.Name)
+	switch {
+	case err == nil:
+		err = authorizeAlert(c, db, old.Set, old.Params["view"])
+	case err == alert.ErrNotFound:
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := alert.Upsert(c.SessionID, db, &a); err != nil {
+		return err
+	}
+
+	c.Respond(nil, http.StatusNoContent)
+	return nil
+}
+
+//==============================================================================
+
+// Delete removes the specified alert from the system.
+// 204 SuccessNoContent, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
+func (alertHandle) Delete(c *web.Context) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	a, err := alert.GetByName(c.SessionID, db, c.Params["name"])
+	if err != nil {
+		if err == alert.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authorizeAlert(c, db, a.Set, a.Params["view"]); err != nil {
+		return err
+	}
+
+	if err := alert.Delete(c.SessionID, db, c.Params["name"]); err != nil {
+		if err == alert.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	c.Respond(nil, http.StatusNoContent)
+	return nil
+}
+
+//==============================================================================
+
+// authorizeAlert returns an error if the caller is not granted execute access
+// to the named set and view an alert uses. A removed set has no ACL left to
+// check.
+func authorizeAlert(c *web.Context, db *db.DB, setName, viewName string) error {
+	if viewName != "" {
+		if err := authorizeView(c, db, viewName); err != nil {
+			return err
+		}
+	}
+
+	set, err := query.GetByName(c.SessionID, db, setName)
+	if err != nil {
+		if err == query.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	return authorizeExec(c, db, set)
+}
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/platform/auth"
 	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/coralproject/shelf/internal/xenia"
 	"github.com/coralproject/shelf/internal/xenia/query"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // execHandle maintains the set of handlers for the exec api.
@@ -21,9 +24,11 @@ var Exec execHandle
 //==============================================================================
 
 // Name runs the specified Set and returns results.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (execHandle) Name(c *web.Context) error {
-	set, err := query.GetByName(c.SessionID, c.Ctx["DB"].(*db.DB), c.Params["name"])
+	db := c.Ctx["DB"].(*db.DB)
+
+	set, err := query.GetByName(c.SessionID, db, c.Params["name"])
 	if err != nil {
 		if err == query.ErrNotFound {
 			err = web.ErrNotFound
@@ -31,15 +36,21 @@ func (execHandle) Name(c *web.Context) error {
 		return err
 	}
 
+	if err := authorizeExec(c, db, set); err != nil {
+		return err
+	}
+
 	var vars map[string]string
 
 	return execute(c, set, vars)
 }
 
 // NameOnView runs the specified Set on a view and returns results.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (execHandle) NameOnView(c *web.Context) error {
-	set, err := query.GetByName(c.SessionID, c.Ctx["DB"].(*db.DB), c.Params["name"])
+	db := c.Ctx["DB"].(*db.DB)
+
+	set, err := query.GetByName(c.SessionID, db, c.Params["name"])
 	if err != nil {
 		if err == query.ErrNotFound {
 			err = web.ErrNotFound
@@ -47,6 +58,14 @@ func (execHandle) NameOnView(c *web.Context) error {
 		return err
 	}
 
+	if err := authorizeExec(c, db, set); err != nil {
+		return err
+	}
+
+	if err := authorizeView(c, db, c.Params["view"]); err != nil {
+		return err
+	}
+
 	vars := map[string]string{
 		"view": c.Params["view"],
 		"item": c.Params["item"],
@@ -56,26 +75,54 @@ func (execHandle) NameOnView(c *web.Context) error {
 }
 
 // Custom runs the provided Set and return results.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (execHandle) Custom(c *web.Context) error {
 	var set *query.Set
 	if err := json.NewDecoder(c.Request.Body).Decode(&set); err != nil {
 		return err
 	}
 
+	// A null document decodes into no set.
+	if set == nil {
+		return web.ErrValidation
+	}
+
+	// The caller provided the set, so only the scripts it uses are checked.
+	set.ACL = nil
+	if err := authorizeExec(c, c.Ctx["DB"].(*db.DB), set); err != nil {
+		return err
+	}
+
 	var vars map[string]string
 
 	return execute(c, set, vars)
 }
 
 // CustomOnView runs the provided Set on a view and return results.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (execHandle) CustomOnView(c *web.Context) error {
 	var set *query.Set
 	if err := json.NewDecoder(c.Request.Body).Decode(&set); err != nil {
 		return err
 	}
 
+	// A null document decodes into no set.
+	if set == nil {
+		return web.ErrValidation
+	}
+
+	db := c.Ctx["DB"].(*db.DB)
+
+	// The caller provided the set, so only the scripts it uses are checked.
+	set.ACL = nil
+	if err := authorizeExec(c, db, set); err != nil {
+		return err
+	}
+
+	if err := authorizeView(c, db, c.Params["view"]); err != nil {
+		return err
+	}
+
 	vars := map[string]string{
 		"view": c.Params["view"],
 		"item": c.Params["item"],
@@ -84,27 +131,166 @@ func (execHandle) CustomOnView(c *web.Context) error {
 	return execute(c, set, vars)
 }
 
+// maxBatchSets is the maximum number of sets a batch can execute.
+const maxBatchSets = 50
+
+// batchRequest is the document posted to execute a batch of sets.
+type batchRequest struct {
+	Sets []struct {
+		Name string            `json:"name"`
+		Key  string            `json:"key"`
+		Vars map[string]string `json:"vars"`
+	} `json:"sets"`
+}
+
+// Batch runs the specified Sets concurrently and returns the result of each
+// set under its key, which defaults to the set name. A set that can't be found
+// or executed, or runs on a view the caller can't execute, only fails its own
+// result.
+// 200 Success, 400 Bad Request, 401 Unauthorized, 500 Internal
+func (execHandle) Batch(c *web.Context) error {
+	var req batchRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	if len(req.Sets) == 0 || len(req.Sets) > maxBatchSets {
+		return web.ErrValidation
+	}
+
+	db := c.Ctx["DB"].(*db.DB)
+
+	results := make(map[string]*query.Result, len(req.Sets))
+	batch := make([]xenia.BatchSet, 0, len(req.Sets))
+
+	for _, rs := range req.Sets {
+		key := rs.Key
+		if key == "" {
+			key = rs.Name
+		}
+
+		if _, exists := results[key]; exists {
+			return web.ErrValidation
+		}
+
+		set, err := query.GetByName(c.SessionID, db, rs.Name)
+		if err == nil {
+			err = authorizeExec(c, db, set)
+		}
+
+		// A set run on a view also needs the caller to execute the view.
+		if err == nil && rs.Vars["view"] != "" {
+			err = authorizeView(c, db, rs.Vars["view"])
+		}
+
+		if err != nil {
+			results[key] = &query.Result{Results: bson.M{"error": err.Error()}}
+			continue
+		}
+
+		// Reserve the key so duplicates are caught.
+		results[key] = nil
+		batch = append(batch, xenia.BatchSet{Key: key, Set: set, Vars: rs.Vars})
+	}
+
+	for key, r := range xenia.ExecBatch(c.SessionID, db, batch) {
+		results[key] = r
+	}
+
+	c.Respond(results, http.StatusOK)
+	return nil
+}
+
+// SlowLog returns the most recent queries that exceeded the slow query
+// threshold. Queries from sets the caller can't read are left out.
+// 200 Success, 400 Bad Request, 401 Unauthorized, 500 Internal
+func (execHandle) SlowLog(c *web.Context) error {
+	limit := 100
+	if l := c.Request.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return web.ErrValidation
+		}
+		limit = n
+	}
+
+	db := c.Ctx["DB"].(*db.DB)
+
+	sqs, err := xenia.SlowLog(c.SessionID, db, limit)
+	if err != nil {
+		return err
+	}
+
+	acls := make(map[string]*auth.ACL)
+	readable := make([]xenia.SlowQuery, 0, len(sqs))
+	for _, sq := range sqs {
+		acl, exists := acls[sq.Set]
+		if !exists {
+			set, err := query.GetByName(c.SessionID, db, sq.Set)
+			if err != nil && err != query.ErrNotFound {
+				return err
+			}
+			if set != nil {
+				acl = set.ACL
+			}
+			acls[sq.Set] = acl
+		}
+
+		if allowed(c, acl, auth.OpRead) {
+			readable = append(readable, sq)
+		}
+	}
+
+	c.Respond(readable, http.StatusOK)
+	return nil
+}
+
 //==============================================================================
 
 // execute takes a context and Set and executes the set returning
 // any possible response.
 func execute(c *web.Context, set *query.Set, vars map[string]string) error {
+	db := c.Ctx["DB"].(*db.DB)
 
 	// Parse the vars in the query string.
-	if c.Request.URL.RawQuery != "" {
-		if m, err := url.ParseQuery(c.Request.URL.RawQuery); err == nil {
-			if vars == nil {
-				vars = make(map[string]string)
-			}
-			for k, v := range m {
-				vars[k] = v[0]
-			}
+	vars = queryVars(c, vars)
+
+	// The set runs on the results of the view, so the caller must be able to
+	// execute it, whether it was named by the route or the query string.
+	if name := vars["view"]; name != "" {
+		if err := authorizeView(c, db, name); err != nil {
+			return err
 		}
 	}
 
 	// Get the result.
-	result := xenia.Exec(c.SessionID, c.Ctx["DB"].(*db.DB), set, vars)
+	result := xenia.Exec(c.SessionID, db, set, vars)
 
 	c.Respond(result, http.StatusOK)
 	return nil
 }
+
+// queryVars adds the vars in the query string to the vars. The vars already
+// set, like the view and item of the route, are not overridden.
+func queryVars(c *web.Context, vars map[string]string) map[string]string {
+	if c.Request.URL.RawQuery == "" {
+		return vars
+	}
+
+	m, err := url.ParseQuery(c.Request.URL.RawQuery)
+	if err != nil {
+		return vars
+	}
+
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+	for k, v := range m {
+		if _, exists := vars[k]; exists {
+			continue
+		}
+		vars[k] = v[0]
+	}
+
+	return vars
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/platform/db"
+)
+
+// TestCustomNull tests rejecting a null set posted for execution.
+func TestCustomNull(t *testing.T) {
+	t.Log("Given the need to execute a set provided by the caller.")
+	{
+		t.Log("\tWhen posting a null set")
+		{
+			for _, h := range []struct {
+				name string
+				fn   func(*web.Context) error
+			}{
+				{"Custom", Exec.Custom},
+				{"CustomOnView", Exec.CustomOnView},
+			} {
+				c := newContext("/v1/exec", nil)
+				c.Request = httptest.NewRequest("POST", "/v1/exec", strings.NewReader("null"))
+				c.Ctx["DB"] = (*db.DB)(nil)
+
+				if err := h.fn(c); err != web.ErrValidation {
+					t.Fatalf("\t%s\tShould reject the set with %s : %v", tests.Failed, h.name, err)
+				}
+			}
+			t.Logf("\t%s\tShould reject the set.", tests.Success)
+		}
+	}
+}
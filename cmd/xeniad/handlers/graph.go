@@ -0,0 +1,154 @@
+// Package handlers contains the handler logic for processing requests.
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/sponge/item"
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/coralproject/shelf/internal/xenia"
+)
+
+// graphHandle maintains the set of handlers for the graph api.
+type graphHandle struct{}
+
+// Graph fronts the access to the graph service functionality.
+var Graph graphHandle
+
+//==============================================================================
+
+// Neighbors returns the items adjacent to the specified item grouped by
+// predicate. The predicate, direction and depth query parameters limit the
+// relationships followed and hydrate=true includes the items, masked like
+// the results of a query on the items collection.
+// 200 Success, 400 Bad Request, 500 Internal
+func (graphHandle) Neighbors(c *web.Context) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	qs := c.Request.URL.Query()
+	params := wire.NeighborParams{
+		ItemKey:   c.Params["item"],
+		Predicate: qs.Get("predicate"),
+		Direction: qs.Get("direction"),
+		Hydrate:   qs.Get("hydrate") == "true",
+	}
+
+	if s := qs.Get("depth"); s != "" {
+		depth, err := strconv.Atoi(s)
+		if err != nil || depth < 1 {
+			return web.ErrValidation
+		}
+		params.Depth = depth
+	}
+
+	graphDB, err := db.GraphHandle(c.SessionID)
+	if err != nil {
+		return err
+	}
+
+	n, err := wire.GetNeighbors(c.SessionID, db, graphDB, &params)
+	if err != nil {
+		if err == wire.ErrDirection {
+			return web.ErrValidation
+		}
+		return err
+	}
+
+	if err := xenia.ProcessMasks(c.SessionID, db, item.Collection, n.Items); err != nil {
+		return err
+	}
+
+	c.Respond(n, http.StatusOK)
+	return nil
+}
+
+// ShortestPath returns the shortest path between the specified items. The
+// predicate query parameter limits the relationships followed and
+// hydrate=true includes the items along the path, masked like the results
+// of a query on the items collection.
+// 200 Success, 404 Not Found, 500 Internal
+func (graphHandle) ShortestPath(c *web.Context) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	qs := c.Request.URL.Query()
+
+	graphDB, err := db.GraphHandle(c.SessionID)
+	if err != nil {
+		return err
+	}
+
+	gp, err := wire.ShortestPath(c.SessionID, db, graphDB, c.Params["item"], c.Params["to"], qs.Get("predicate"), qs.Get("hydrate") == "true")
+	if err != nil {
+		if err == wire.ErrNoPath {
+			return web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := xenia.ProcessMasks(c.SessionID, db, item.Collection, gp.Items); err != nil {
+		return err
+	}
+
+	c.Respond(gp, http.StatusOK)
+	return nil
+}
+
+//==============================================================================
+
+// Check compares the graph with the quads expected from the items and their
+// patterns and reports the missing, extra and dangling quads.
+// 200 Success, 500 Internal
+func (graphHandle) Check(c *web.Context) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	graphDB, err := db.GraphHandle(c.SessionID)
+	if err != nil {
+		return err
+	}
+
+	r, err := wire.CheckGraph(c.SessionID, db, graphDB)
+	if err != nil {
+		return err
+	}
+
+	c.Respond(r, http.StatusOK)
+	return nil
+}
+
+//==============================================================================
+
+// Stats returns the counts of relationships per predicate and per triple of
+// subject type, predicate and object type, the counts of items and orphans
+// per type, and the items with the most relationships. The relationships
+// derived by rules are only counted apart. The top query parameter sets the
+// number of items returned. Every item and relationship is loaded to count
+// them, so the call is costly on a large graph.
+// 200 Success, 400 Bad Request, 500 Internal
+func (graphHandle) Stats(c *web.Context) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	var top int
+	if s := c.Request.URL.Query().Get("top"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return web.ErrValidation
+		}
+		top = n
+	}
+
+	graphDB, err := db.GraphHandle(c.SessionID)
+	if err != nil {
+		return err
+	}
+
+	s, err := wire.Stats(c.SessionID, db, graphDB, top)
+	if err != nil {
+		return err
+	}
+
+	c.Respond(s, http.StatusOK)
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/platform/auth"
 	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/coralproject/shelf/internal/xenia/mask"
 )
@@ -17,7 +18,7 @@ var Mask maskHandle
 
 //==============================================================================
 
-// List returns all the existing mask in the system.
+// List returns all the existing mask in the system the caller can read.
 // 200 Success, 404 Not Found, 500 Internal
 func (maskHandle) List(c *web.Context) error {
 	masks, err := mask.GetAll(c.SessionID, c.Ctx["DB"].(*db.DB), nil)
@@ -28,12 +29,12 @@ func (maskHandle) List(c *web.Context) error {
 		return err
 	}
 
-	c.Respond(masks, http.StatusOK)
+	c.Respond(readableMasks(c, masks), http.StatusOK)
 	return nil
 }
 
 // Retrieve returns the specified mask from the system.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (maskHandle) Retrieve(c *web.Context) error {
 	collection := c.Params["collection"]
 	field := c.Params["field"]
@@ -51,7 +52,7 @@ func (maskHandle) Retrieve(c *web.Context) error {
 			return err
 		}
 
-		c.Respond(masks, http.StatusOK)
+		c.Respond(readableMasks(c, masks), http.StatusOK)
 		return nil
 	}
 
@@ -63,6 +64,10 @@ func (maskHandle) Retrieve(c *web.Context) error {
 		return err
 	}
 
+	if err := authorize(c, msk.ACL, auth.OpRead, "mask "+msk.Collection+"."+msk.Field); err != nil {
+		return err
+	}
+
 	c.Respond(msk, http.StatusOK)
 	return nil
 }
@@ -70,14 +75,28 @@ func (maskHandle) Retrieve(c *web.Context) error {
 //==============================================================================
 
 // Upsert inserts or updates the posted mask document into the database.
-// 204 SuccessNoContent, 400 Bad Request, 404 Not Found, 500 Internal
+// 204 SuccessNoContent, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (maskHandle) Upsert(c *web.Context) error {
 	var msk mask.Mask
 	if err := json.NewDecoder(c.Request.Body).Decode(&msk); err != nil {
 		return err
 	}
 
-	if err := mask.Upsert(c.SessionID, c.Ctx["DB"].(*db.DB), msk); err != nil {
+	db := c.Ctx["DB"].(*db.DB)
+
+	// Updating an existing mask requires write access to it.
+	cur, err := mask.GetByName(c.SessionID, db, msk.Collection, msk.Field)
+	if err != nil && err != mask.ErrNotFound {
+		return err
+	}
+
+	if err == nil {
+		if err := authorize(c, cur.ACL, auth.OpWrite, "mask "+cur.Collection+"."+cur.Field); err != nil {
+			return err
+		}
+	}
+
+	if err := mask.Upsert(c.SessionID, db, msk); err != nil {
 		return err
 	}
 
@@ -88,9 +107,23 @@ func (maskHandle) Upsert(c *web.Context) error {
 //==============================================================================
 
 // Delete removes the specified mask from the system.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (maskHandle) Delete(c *web.Context) error {
-	if err := mask.Delete(c.SessionID, c.Ctx["DB"].(*db.DB), c.Params["collection"], c.Params["field"]); err != nil {
+	db := c.Ctx["DB"].(*db.DB)
+
+	msk, err := mask.GetByName(c.SessionID, db, c.Params["collection"], c.Params["field"])
+	if err != nil {
+		if err == mask.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authorize(c, msk.ACL, auth.OpWrite, "mask "+msk.Collection+"."+msk.Field); err != nil {
+		return err
+	}
+
+	if err := mask.Delete(c.SessionID, db, c.Params["collection"], c.Params["field"]); err != nil {
 		if err == mask.ErrNotFound {
 			err = web.ErrNotFound
 		}
@@ -100,3 +133,17 @@ func (maskHandle) Delete(c *web.Context) error {
 	c.Respond(nil, http.StatusNoContent)
 	return nil
 }
+
+//==============================================================================
+
+// readableMasks returns the masks the caller is allowed to read.
+func readableMasks(c *web.Context, masks map[string]mask.Mask) map[string]mask.Mask {
+	readable := make(map[string]mask.Mask, len(masks))
+	for fld, msk := range masks {
+		if allowed(c, msk.ACL, auth.OpRead) {
+			readable[fld] = msk
+		}
+	}
+
+	return readable
+}
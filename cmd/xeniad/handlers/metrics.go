@@ -0,0 +1,27 @@
+// Package handlers contains the handler logic for processing requests.
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/xenia/metrics"
+)
+
+// metricsHandle maintains the set of handlers for the metrics api.
+type metricsHandle struct{}
+
+// Metrics fronts the access to the metrics service functionality.
+var Metrics metricsHandle
+
+//==============================================================================
+
+// List returns the execution metrics in the Prometheus text format.
+// 200 Success, 500 Internal
+func (metricsHandle) List(c *web.Context) error {
+	c.Status = http.StatusOK
+	c.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	c.WriteHeader(http.StatusOK)
+
+	return metrics.Write(c)
+}
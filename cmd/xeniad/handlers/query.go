@@ -6,7 +6,9 @@ import (
 	"net/http"
 
 	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/platform/auth"
 	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/xenia/policy"
 	"github.com/coralproject/shelf/internal/xenia/query"
 )
 
@@ -18,7 +20,7 @@ var Query queryHandle
 
 //==============================================================================
 
-// List returns all the existing Set names in the system.
+// List returns all the existing Set names in the system the caller can read.
 // 200 Success, 404 Not Found, 500 Internal
 func (queryHandle) List(c *web.Context) error {
 	sets, err := query.GetAll(c.SessionID, c.Ctx["DB"].(*db.DB), nil)
@@ -29,12 +31,20 @@ func (queryHandle) List(c *web.Context) error {
 		return err
 	}
 
-	c.Respond(sets, http.StatusOK)
+	// Filter out the sets the caller is not allowed to read.
+	readable := make([]query.Set, 0, len(sets))
+	for _, set := range sets {
+		if allowed(c, set.ACL, auth.OpRead) {
+			readable = append(readable, set)
+		}
+	}
+
+	c.Respond(readable, http.StatusOK)
 	return nil
 }
 
 // Retrieve returns the specified Set from the system.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (queryHandle) Retrieve(c *web.Context) error {
 	set, err := query.GetByName(c.SessionID, c.Ctx["DB"].(*db.DB), c.Params["name"])
 	if err != nil {
@@ -44,6 +54,10 @@ func (queryHandle) Retrieve(c *web.Context) error {
 		return err
 	}
 
+	if err := authorize(c, set.ACL, auth.OpRead, "set "+set.Name); err != nil {
+		return err
+	}
+
 	c.Respond(set, http.StatusOK)
 	return nil
 }
@@ -51,14 +65,32 @@ func (queryHandle) Retrieve(c *web.Context) error {
 //==============================================================================
 
 // Upsert inserts or updates the posted Set document into the database.
-// 204 SuccessNoContent, 400 Bad Request, 404 Not Found, 500 Internal
+// 204 SuccessNoContent, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (queryHandle) Upsert(c *web.Context) error {
 	var set query.Set
 	if err := json.NewDecoder(c.Request.Body).Decode(&set); err != nil {
 		return err
 	}
 
-	if err := query.Upsert(c.SessionID, c.Ctx["DB"].(*db.DB), &set); err != nil {
+	db := c.Ctx["DB"].(*db.DB)
+
+	// Updating an existing set requires write access to it.
+	cur, err := query.GetByName(c.SessionID, db, set.Name)
+	if err != nil && err != query.ErrNotFound {
+		return err
+	}
+
+	if cur != nil {
+		if err := authorize(c, cur.ACL, auth.OpWrite, "set "+cur.Name); err != nil {
+			return err
+		}
+	}
+
+	if err := query.Upsert(c.SessionID, db, &set); err != nil {
+		if _, ok := err.(*policy.Error); ok {
+			c.RespondError(err.Error(), http.StatusBadRequest)
+			return nil
+		}
 		return err
 	}
 
@@ -67,7 +99,7 @@ func (queryHandle) Upsert(c *web.Context) error {
 }
 
 // EnsureIndexes makes sure indexes for the specified set exist.
-// 204 SuccessNoContent, 400 Bad Request, 404 Not Found, 500 Internal
+// 204 SuccessNoContent, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (queryHandle) EnsureIndexes(c *web.Context) error {
 	db := c.Ctx["DB"].(*db.DB)
 
@@ -79,6 +111,10 @@ func (queryHandle) EnsureIndexes(c *web.Context) error {
 		return err
 	}
 
+	if err := authorize(c, set.ACL, auth.OpWrite, "set "+set.Name); err != nil {
+		return err
+	}
+
 	if err := query.EnsureIndexes(c.SessionID, db, set); err != nil {
 		return err
 	}
@@ -90,9 +126,23 @@ func (queryHandle) EnsureIndexes(c *web.Context) error {
 //==============================================================================
 
 // Delete removes the specified Set from the system.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (queryHandle) Delete(c *web.Context) error {
-	if err := query.Delete(c.SessionID, c.Ctx["DB"].(*db.DB), c.Params["name"]); err != nil {
+	db := c.Ctx["DB"].(*db.DB)
+
+	set, err := query.GetByName(c.SessionID, db, c.Params["name"])
+	if err != nil {
+		if err == query.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authorize(c, set.ACL, auth.OpWrite, "set "+set.Name); err != nil {
+		return err
+	}
+
+	if err := query.Delete(c.SessionID, db, c.Params["name"]); err != nil {
 		if err == query.ErrNotFound {
 			err = web.ErrNotFound
 		}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/platform/auth"
 	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/coralproject/shelf/internal/xenia/script"
 )
@@ -17,7 +18,7 @@ var Script scriptHandle
 
 //==============================================================================
 
-// List returns all the existing scripts in the system.
+// List returns all the existing scripts in the system the caller can read.
 // 200 Success, 404 Not Found, 500 Internal
 func (scriptHandle) List(c *web.Context) error {
 	scrs, err := script.GetAll(c.SessionID, c.Ctx["DB"].(*db.DB), nil)
@@ -28,12 +29,20 @@ func (scriptHandle) List(c *web.Context) error {
 		return err
 	}
 
-	c.Respond(scrs, http.StatusOK)
+	// Filter out the scripts the caller is not allowed to read.
+	readable := make([]script.Script, 0, len(scrs))
+	for _, scr := range scrs {
+		if allowed(c, scr.ACL, auth.OpRead) {
+			readable = append(readable, scr)
+		}
+	}
+
+	c.Respond(readable, http.StatusOK)
 	return nil
 }
 
 // Retrieve returns the specified script from the system.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (scriptHandle) Retrieve(c *web.Context) error {
 	scr, err := script.GetByName(c.SessionID, c.Ctx["DB"].(*db.DB), c.Params["name"])
 	if err != nil {
@@ -43,6 +52,10 @@ func (scriptHandle) Retrieve(c *web.Context) error {
 		return err
 	}
 
+	if err := authorize(c, scr.ACL, auth.OpRead, "script "+scr.Name); err != nil {
+		return err
+	}
+
 	c.Respond(scr, http.StatusOK)
 	return nil
 }
@@ -50,14 +63,28 @@ func (scriptHandle) Retrieve(c *web.Context) error {
 //==============================================================================
 
 // Upsert inserts or updates the posted Script document into the database.
-// 204 SuccessNoContent, 400 Bad Request, 404 Not Found, 500 Internal
+// 204 SuccessNoContent, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (scriptHandle) Upsert(c *web.Context) error {
 	var scr script.Script
 	if err := json.NewDecoder(c.Request.Body).Decode(&scr); err != nil {
 		return err
 	}
 
-	if err := script.Upsert(c.SessionID, c.Ctx["DB"].(*db.DB), scr); err != nil {
+	db := c.Ctx["DB"].(*db.DB)
+
+	// Updating an existing script requires write access to it.
+	cur, err := script.GetByName(c.SessionID, db, scr.Name)
+	if err != nil && err != script.ErrNotFound {
+		return err
+	}
+
+	if err == nil {
+		if err := authorize(c, cur.ACL, auth.OpWrite, "script "+cur.Name); err != nil {
+			return err
+		}
+	}
+
+	if err := script.Upsert(c.SessionID, db, scr); err != nil {
 		return err
 	}
 
@@ -68,9 +95,23 @@ func (scriptHandle) Upsert(c *web.Context) error {
 //==============================================================================
 
 // Delete removes the specified Script from the system.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (scriptHandle) Delete(c *web.Context) error {
-	if err := script.Delete(c.SessionID, c.Ctx["DB"].(*db.DB), c.Params["name"]); err != nil {
+	db := c.Ctx["DB"].(*db.DB)
+
+	scr, err := script.GetByName(c.SessionID, db, c.Params["name"])
+	if err != nil {
+		if err == script.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authorize(c, scr.ACL, auth.OpWrite, "script "+scr.Name); err != nil {
+		return err
+	}
+
+	if err := script.Delete(c.SessionID, db, c.Params["name"]); err != nil {
 		if err == script.ErrNotFound {
 			err = web.ErrNotFound
 		}
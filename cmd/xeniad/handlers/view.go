@@ -4,10 +4,17 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/platform/auth"
 	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/wire"
 	"github.com/coralproject/shelf/internal/wire/view"
+	"github.com/coralproject/shelf/internal/xenia"
+	"github.com/coralproject/shelf/internal/xenia/query"
 )
 
 // viewHandle maintains the set of handlers for the view api.
@@ -18,7 +25,7 @@ var View viewHandle
 
 //==============================================================================
 
-// List returns all the existing views in the system.
+// List returns all the existing views in the system the caller can read.
 // 200 Success, 404 Not Found, 500 Internal
 func (viewHandle) List(c *web.Context) error {
 	views, err := view.GetAll(c.SessionID, c.Ctx["DB"].(*db.DB))
@@ -29,12 +36,20 @@ func (viewHandle) List(c *web.Context) error {
 		return err
 	}
 
-	c.Respond(views, http.StatusOK)
+	// Filter out the views the caller is not allowed to read.
+	readable := make([]view.View, 0, len(views))
+	for _, v := range views {
+		if allowed(c, v.ACL, auth.OpRead) {
+			readable = append(readable, v)
+		}
+	}
+
+	c.Respond(readable, http.StatusOK)
 	return nil
 }
 
 // Retrieve returns the specified View from the system.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (viewHandle) Retrieve(c *web.Context) error {
 	v, err := view.GetByName(c.SessionID, c.Ctx["DB"].(*db.DB), c.Params["name"])
 	if err != nil {
@@ -44,6 +59,10 @@ func (viewHandle) Retrieve(c *web.Context) error {
 		return err
 	}
 
+	if err := authorize(c, v.ACL, auth.OpRead, "view "+v.Name); err != nil {
+		return err
+	}
+
 	c.Respond(v, http.StatusOK)
 	return nil
 }
@@ -51,14 +70,33 @@ func (viewHandle) Retrieve(c *web.Context) error {
 //==============================================================================
 
 // Upsert inserts or updates the posted View document into the database.
-// 204 SuccessNoContent, 400 Bad Request, 404 Not Found, 500 Internal
+// 204 SuccessNoContent, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (viewHandle) Upsert(c *web.Context) error {
 	var v view.View
 	if err := json.NewDecoder(c.Request.Body).Decode(&v); err != nil {
 		return err
 	}
 
-	if err := view.Upsert(c.SessionID, c.Ctx["DB"].(*db.DB), &v); err != nil {
+	db := c.Ctx["DB"].(*db.DB)
+
+	// Updating an existing view requires write access to it.
+	cur, err := view.GetByName(c.SessionID, db, v.Name)
+	if err != nil && err != view.ErrNotFound {
+		return err
+	}
+
+	if err == nil {
+		if err := authorize(c, cur.ACL, auth.OpWrite, "view "+cur.Name); err != nil {
+			return err
+		}
+	}
+
+	if err := view.Upsert(c.SessionID, db, &v); err != nil {
+		return err
+	}
+
+	// The materialized collections of the view follow its old definition.
+	if err := wire.InvalidateView(c.SessionID, db, v.Name); err != nil {
 		return err
 	}
 
@@ -69,15 +107,383 @@ func (viewHandle) Upsert(c *web.Context) error {
 //==============================================================================
 
 // Delete removes the specified View from the system.
-// 200 Success, 400 Bad Request, 404 Not Found, 500 Internal
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
 func (viewHandle) Delete(c *web.Context) error {
-	if err := view.Delete(c.SessionID, c.Ctx["DB"].(*db.DB), c.Params["name"]); err != nil {
+	db := c.Ctx["DB"].(*db.DB)
+
+	v, err := view.GetByName(c.SessionID, db, c.Params["name"])
+	if err != nil {
 		if err == view.ErrNotFound {
 			err = web.ErrNotFound
 		}
 		return err
 	}
 
+	if err := authorize(c, v.ACL, auth.OpWrite, "view "+v.Name); err != nil {
+		return err
+	}
+
+	if err := view.Delete(c.SessionID, db, c.Params["name"]); err != nil {
+		if err == view.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := wire.DropViews(c.SessionID, db, v.Name); err != nil {
+		return err
+	}
+
+	c.Respond(nil, http.StatusNoContent)
+	return nil
+}
+
+//==============================================================================
+
+// Exec returns the items in the specified view for the item, or for each of
+// the comma separated items with every item annotated with the roots that
+// reached it. The items can be paged through with the limit, offset, cursor
+// and sort query parameters. The as_of, from and to query parameters limit
+// the relationships followed to those created within a time window, or of
+// unknown time.
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
+func (viewHandle) Exec(c *web.Context) error {
+	viewParams := wire.ViewParams{
+		ViewName: c.Params["view"],
+		ItemKey:  c.Params["item"],
+	}
+
+	if keys := strings.Split(c.Params["item"], ","); len(keys) > 1 {
+		if len(keys) > maxRootKeys {
+			return web.ErrValidation
+		}
+		viewParams.ItemKey = ""
+		viewParams.ItemKeys = keys
+	}
+
+	return execView(c, &viewParams)
+}
+
+// maxRootKeys is the maximum number of item keys a view can be executed from.
+const maxRootKeys = 100
+
+// rootsRequest is the document posted to execute a view from several items.
+type rootsRequest struct {
+	ItemKeys []string          `json:"item_keys"`
+	Set      string            `json:"set"`   // Query set yielding more item keys.
+	Field    string            `json:"field"` // Field of the set documents holding the keys.
+	Vars     map[string]string `json:"vars"`  // Variables of the set.
+}
+
+// ExecRoots returns the items in the specified view for the posted item keys
+// and the keys yielded by the posted query set, with every item annotated
+// with the roots that reached it. The keys are read from the item_id field
+// of the documents of the set unless another field is posted. The query
+// parameters are the same as for Exec. A set run on a view also needs the
+// caller to execute that view, and at most maxRootKeys keys are accepted.
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
+func (viewHandle) ExecRoots(c *web.Context) error {
+	var req rootsRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	db := c.Ctx["DB"].(*db.DB)
+
+	keys := req.ItemKeys
+	if req.Set != "" {
+		set, err := query.GetByName(c.SessionID, db, req.Set)
+		if err != nil {
+			if err == query.ErrNotFound {
+				err = web.ErrNotFound
+			}
+			return err
+		}
+
+		if err := authorizeExec(c, db, set); err != nil {
+			return err
+		}
+
+		if req.Vars["view"] != "" {
+			if err := authorizeView(c, db, req.Vars["view"]); err != nil {
+				return err
+			}
+		}
+
+		field := req.Field
+		if field == "" {
+			field = "item_id"
+		}
+
+		ids, err := xenia.ExecIDs(c.SessionID, db, set, req.Vars, field)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, ids...)
+	}
+
+	if len(keys) == 0 || len(keys) > maxRootKeys {
+		return web.ErrValidation
+	}
+
+	viewParams := wire.ViewParams{
+		ViewName: c.Params["view"],
+		ItemKeys: keys,
+	}
+
+	return execView(c, &viewParams)
+}
+
+// execView executes the view with the paging and time window query
+// parameters of the request.
+func execView(c *web.Context, viewParams *wire.ViewParams) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	v, err := view.GetByName(c.SessionID, db, viewParams.ViewName)
+	if err != nil {
+		if err == view.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authorize(c, v.ACL, auth.OpExec, "view "+v.Name); err != nil {
+		return err
+	}
+
+	qs := c.Request.URL.Query()
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &viewParams.Limit}, {"offset", &viewParams.Offset}} {
+		if s := qs.Get(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return web.ErrValidation
+			}
+			*p.dst = n
+		}
+	}
+	viewParams.Cursor = qs.Get("cursor")
+	viewParams.Sort = qs.Get("sort")
+
+	if err := viewWindow(c, viewParams); err != nil {
+		return err
+	}
+
+	graphDB, err := db.GraphHandle(c.SessionID)
+	if err != nil {
+		return err
+	}
+
+	result, err := wire.Execute(c.SessionID, db, graphDB, viewParams)
+	if err != nil {
+		if err == wire.ErrInvalidCursor || err == wire.ErrInvalidSort {
+			return web.ErrValidation
+		}
+		return err
+	}
+
+	c.Respond(result, http.StatusOK)
+	return nil
+}
+
+// viewWindow limits the relationships followed by the view to the time window
+// in the query parameters of the request, if any.
+func viewWindow(c *web.Context, viewParams *wire.ViewParams) error {
+	qs := c.Request.URL.Query()
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"as_of", &viewParams.AsOf}, {"from", &viewParams.From}, {"to", &viewParams.To}} {
+		if s := qs.Get(p.name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return web.ErrValidation
+			}
+			*p.dst = &t
+		}
+	}
+
+	return nil
+}
+
+// Explain returns how the specified view would be executed from the specified
+// item, or comma separated items: the graph path of each path of the view,
+// the items reached and tagged by each segment, how the tagged items are
+// embedded, and warnings about predicates and start types. Nothing is saved.
+// The as_of, from and to query parameters limit the relationships followed.
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 500 Internal
+func (viewHandle) Explain(c *web.Context) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	v, err := view.GetByName(c.SessionID, db, c.Params["name"])
+	if err != nil {
+		if err == view.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authorize(c, v.ACL, auth.OpExec, "view "+v.Name); err != nil {
+		return err
+	}
+
+	viewParams := wire.ViewParams{
+		ViewName: v.Name,
+		ItemKey:  c.Params["item"],
+	}
+
+	if keys := strings.Split(c.Params["item"], ","); len(keys) > 1 {
+		if len(keys) > maxRootKeys {
+			return web.ErrValidation
+		}
+		viewParams.ItemKey = ""
+		viewParams.ItemKeys = keys
+	}
+
+	if err := viewWindow(c, &viewParams); err != nil {
+		return err
+	}
+
+	graphDB, err := db.GraphHandle(c.SessionID)
+	if err != nil {
+		return err
+	}
+
+	ve, err := wire.Explain(c.SessionID, db, graphDB, &viewParams)
+	if err != nil {
+		return err
+	}
+
+	c.Respond(ve, http.StatusOK)
+	return nil
+}
+
+//==============================================================================
+
+// Persist registers the posted persistent view, which saves the view of an
+// item into a collection kept up to date as the graph changes, and saves it.
+// The collection must be prefixed with persist_ and not already persist
+// another view or item.
+// 200 Success, 400 Bad Request, 401 Unauthorized, 404 Not Found, 409 Conflict, 500 Internal
+func (viewHandle) Persist(c *web.Context) error {
+	var pv wire.PersistentView
+	if err := json.NewDecoder(c.Request.Body).Decode(&pv); err != nil {
+		return err
+	}
+
+	db := c.Ctx["DB"].(*db.DB)
+
+	v, err := view.GetByName(c.SessionID, db, pv.ViewName)
+	if err != nil {
+		if err == view.ErrNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authorize(c, v.ACL, auth.OpExec, "view "+v.Name); err != nil {
+		return err
+	}
+
+	graphDB, err := db.GraphHandle(c.SessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := wire.PersistView(c.SessionID, db, graphDB, &pv); err != nil {
+		switch err {
+		case wire.ErrPersistParams:
+			return web.ErrValidation
+		case wire.ErrPersistConflict:
+			c.RespondError(err.Error(), http.StatusConflict)
+			return nil
+		}
+		return err
+	}
+
+	c.Respond(pv, http.StatusOK)
+	return nil
+}
+
+// PersistStatus returns the persistent views the caller can execute along
+// with their freshness.
+// 200 Success, 404 Not Found, 500 Internal
+func (viewHandle) PersistStatus(c *web.Context) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	pvs, err := wire.GetPersistentViews(c.SessionID, db)
+	if err != nil {
+		if err == wire.ErrPersistNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	// Filter out the views the caller is not allowed to execute.
+	readable := make([]wire.PersistentView, 0, len(pvs))
+	for _, pv := range pvs {
+		if err := authorizeView(c, db, pv.ViewName); err != nil {
+			if err == web.ErrNotAuthorized {
+				continue
+			}
+			return err
+		}
+		readable = append(readable, pv)
+	}
+
+	c.Respond(readable, http.StatusOK)
+	return nil
+}
+
+// PersistRetrieve returns the persistent view saved into the specified
+// collection along with its freshness.
+// 200 Success, 401 Unauthorized, 404 Not Found, 500 Internal
+func (viewHandle) PersistRetrieve(c *web.Context) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	pv, err := wire.GetPersistentView(c.SessionID, db, c.Params["collection"])
+	if err != nil {
+		if err == wire.ErrPersistNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authorizeView(c, db, pv.ViewName); err != nil {
+		return err
+	}
+
+	c.Respond(pv, http.StatusOK)
+	return nil
+}
+
+// PersistDelete removes the persistent view saved into the specified
+// collection along with the collection.
+// 204 SuccessNoContent, 401 Unauthorized, 404 Not Found, 500 Internal
+func (viewHandle) PersistDelete(c *web.Context) error {
+	db := c.Ctx["DB"].(*db.DB)
+
+	pv, err := wire.GetPersistentView(c.SessionID, db, c.Params["collection"])
+	if err != nil {
+		if err == wire.ErrPersistNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authorizeView(c, db, pv.ViewName); err != nil {
+		return err
+	}
+
+	if err := wire.DeletePersistentView(c.SessionID, db, pv.Collection); err != nil {
+		if err == wire.ErrPersistNotFound {
+			err = web.ErrNotFound
+		}
+		return err
+	}
+
 	c.Respond(nil, http.StatusNoContent)
 	return nil
 }
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/ardanlabs/kit/web"
+	"github.com/coralproject/shelf/internal/platform/db"
+)
+
+// TestExecRootsKeys tests limiting the number of item keys a view is
+// executed from.
+func TestExecRootsKeys(t *testing.T) {
+	t.Log("Given the need to execute a view from several items.")
+	{
+		t.Log("\tWhen posting more keys than allowed")
+		{
+			keys := make([]string, maxRootKeys+1)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("%q", fmt.Sprintf("key%d", i))
+			}
+			body := `{"item_keys":[` + strings.Join(keys, ",") + `]}`
+
+			c := newContext("/v1/exec/view/thread", nil)
+			c.Request = httptest.NewRequest("POST", "/v1/exec/view/thread", strings.NewReader(body))
+			c.Ctx["DB"] = (*db.DB)(nil)
+
+			if err := View.ExecRoots(c); err != web.ErrValidation {
+				t.Fatalf("\t%s\tShould reject the keys : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould reject the keys.", tests.Success)
+		}
+
+		t.Log("\tWhen requesting more comma separated keys than allowed")
+		{
+			keys := make([]string, maxRootKeys+1)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key%d", i)
+			}
+
+			c := newContext("/v1/exec/view/thread", nil)
+			c.Params["view"] = "thread"
+			c.Params["item"] = strings.Join(keys, ",")
+
+			if err := View.Exec(c); err != web.ErrValidation {
+				t.Fatalf("\t%s\tShould reject the keys : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould reject the keys.", tests.Success)
+		}
+	}
+}
@@ -16,6 +16,10 @@ import (
 	errorm "github.com/coralproject/shelf/internal/platform/midware/error"
 	logm "github.com/coralproject/shelf/internal/platform/midware/log"
 	"github.com/coralproject/shelf/internal/platform/midware/mongo"
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/coralproject/shelf/internal/xenia"
+	"github.com/coralproject/shelf/internal/xenia/alert"
+	"github.com/coralproject/shelf/internal/xenia/policy"
 )
 
 const (
@@ -33,6 +37,48 @@ const (
 
 	// cfgEnableCORS is set the key to the state for CORS on the service.
 	cfgEnableCORS = "ENABLE_CORS"
+
+	// cfgViewCacheTTL is the key for how long a materialized view is served
+	// before it is refreshed in the background.
+	cfgViewCacheTTL = "VIEW_CACHE_TTL"
+
+	// cfgViewCacheMaxAge is the key for how long a materialized view can be
+	// served before requests must wait on a refresh.
+	cfgViewCacheMaxAge = "VIEW_CACHE_MAX_AGE"
+
+	// cfgViewCacheRetention is the key for how long a materialized view is
+	// kept without being used.
+	cfgViewCacheRetention = "VIEW_CACHE_RETENTION"
+
+	// cfgPolicyFile is the key for the JSON file containing the policy
+	// pipelines must follow to be saved or executed.
+	cfgPolicyFile = "POLICY_FILE"
+
+	// cfgSlowQueryThreshold is the key for how long a query can run before
+	// it is saved into the slow query log.
+	cfgSlowQueryThreshold = "SLOW_QUERY_THRESHOLD"
+
+	// cfgBatchWorkers is the key for the number of sets of a batch that
+	// are executed at the same time.
+	cfgBatchWorkers = "BATCH_WORKERS"
+
+	// cfgAlertTick is the key for how often alerts are checked to see if
+	// they are due to be evaluated. Alerts are disabled if not provided.
+	cfgAlertTick = "ALERT_TICK"
+
+	// cfgAlertPrivateWebhooks is the key for allowing the webhooks of alerts
+	// to point to loopback, private or link local addresses.
+	cfgAlertPrivateWebhooks = "ALERT_PRIVATE_WEBHOOKS"
+
+	// cfgGraphBackend is the key for the backend holding the graph of
+	// relationships: mongo, memstore or bolt. Defaults to mongo. The bolt
+	// backend keeps the whole graph in memory and only persists it to a file,
+	// so the graph must fit in RAM.
+	cfgGraphBackend = "GRAPH_BACKEND"
+
+	// cfgGraphPath is the key for the file holding the graph with the bolt
+	// backend.
+	cfgGraphPath = "GRAPH_PATH"
 )
 
 func init() {
@@ -56,6 +102,16 @@ func API() http.Handler {
 		os.Exit(1)
 	}
 
+	// Configure the backend holding the graph of relationships.
+	if backend, err := cfg.String(cfgGraphBackend); err == nil && backend != "" {
+		path, _ := cfg.String(cfgGraphPath)
+		if err := db.SetGraphBackend(backend, path); err != nil {
+			log.Error("startup", "Init", err, "Initializing Graph Backend")
+			os.Exit(1)
+		}
+		log.Dev("startup", "Init", "Graph Backend : Backend[%s] Path[%s]", backend, path)
+	}
+
 	w := web.New(logm.Midware, errorm.Midware)
 
 	publicKey, err := cfg.String(cfgAuthPublicKey)
@@ -88,6 +144,58 @@ func API() http.Handler {
 		log.Dev("startup", "Init", "CORS Disabled")
 	}
 
+	// Configure the policy for materialized views if one is provided.
+	if ttl, err := cfg.Duration(cfgViewCacheTTL); err == nil {
+		maxAge, err := cfg.Duration(cfgViewCacheMaxAge)
+		if err != nil {
+			maxAge = ttl
+		}
+
+		log.Dev("startup", "Init", "View Cache : TTL[%v] MaxAge[%v]", ttl, maxAge)
+		wire.SetCachePolicy(ttl, maxAge)
+	}
+
+	if retention, err := cfg.Duration(cfgViewCacheRetention); err == nil {
+		log.Dev("startup", "Init", "View Cache : Retention[%v]", retention)
+		wire.SetCacheRetention(retention)
+	}
+
+	// Replace the default pipeline policy if one is provided.
+	if file, err := cfg.String(cfgPolicyFile); err == nil && file != "" {
+		p, err := policy.Load(file)
+		if err != nil {
+			log.Error("startup", "Init", err, "Loading Policy")
+			os.Exit(1)
+		}
+
+		log.Dev("startup", "Init", "Policy : File[%s]", file)
+		policy.Set(p)
+	}
+
+	// Enable the slow query log if a threshold is provided.
+	if threshold, err := cfg.Duration(cfgSlowQueryThreshold); err == nil {
+		log.Dev("startup", "Init", "Slow Query Log : Threshold[%v]", threshold)
+		xenia.SetSlowThreshold(threshold)
+	}
+
+	// Set the concurrency of batch executions if one is provided.
+	if workers, err := cfg.Int(cfgBatchWorkers); err == nil {
+		log.Dev("startup", "Init", "Batch : Workers[%d]", workers)
+		xenia.SetBatchWorkers(workers)
+	}
+
+	// Allow webhooks on the internal network if configured.
+	if private, err := cfg.Bool(cfgAlertPrivateWebhooks); err == nil {
+		log.Dev("startup", "Init", "Alerts : Private Webhooks[%v]", private)
+		alert.AllowPrivateWebhooks(private)
+	}
+
+	// Start evaluating alerts if a tick is provided.
+	if tick, err := cfg.Duration(cfgAlertTick); err == nil && tick > 0 {
+		log.Dev("startup", "Init", "Alerts : Tick[%v]", tick)
+		alert.Start("alerts", mongoURI.Path, tick)
+	}
+
 	log.Dev("startup", "Init", "Initalizing routes")
 	routes(w)
 
@@ -97,6 +205,7 @@ func API() http.Handler {
 // routes manages the handling of the API endpoints.
 func routes(w *web.Web) {
 	w.Handle("GET", "/v1/version", handlers.Version.List)
+	w.Handle("GET", "/metrics", handlers.Metrics.List)
 
 	w.Handle("GET", "/v1/script", handlers.Script.List)
 	w.Handle("PUT", "/v1/script", handlers.Script.Upsert)
@@ -122,6 +231,7 @@ func routes(w *web.Web) {
 	w.Handle("DELETE", "/v1/mask/:collection/:field", handlers.Mask.Delete)
 
 	w.Handle("POST", "/v1/exec", handlers.Exec.Custom)
+	w.Handle("GET", "/v1/exec/slowlog", handlers.Exec.SlowLog)
 	w.Handle("GET", "/v1/exec/:name", handlers.Exec.Name)
 
 	// Create the Cayley middleware which will only be binded to specific
@@ -131,6 +241,21 @@ func routes(w *web.Web) {
 	// These endpoints require Cayley, we will add the middleware onto the routes.
 	w.Handle("GET", "/v1/exec/:name/view/:view/:item", handlers.Exec.NameOnView, cayleym)
 	w.Handle("POST", "/v1/exec/view/:view/:item", handlers.Exec.CustomOnView, cayleym)
+	w.Handle("POST", "/v1/exec/batch", handlers.Exec.Batch, cayleym)
+	w.Handle("GET", "/v1/exec/view/:view/:item", handlers.View.Exec, cayleym)
+	w.Handle("POST", "/v1/exec/view/:view", handlers.View.ExecRoots, cayleym)
+	w.Handle("GET", "/v1/graph/check", handlers.Graph.Check, cayleym)
+	w.Handle("GET", "/v1/graph/stats", handlers.Graph.Stats, cayleym)
+	w.Handle("PUT", "/v1/persist", handlers.View.Persist, cayleym)
+	w.Handle("GET", "/v1/view/:name/explain/:item", handlers.View.Explain, cayleym)
+	w.Handle("GET", "/v1/graph/:item/neighbors", handlers.Graph.Neighbors, cayleym)
+	w.Handle("GET", "/v1/graph/:item/shortest_path/:to", handlers.Graph.ShortestPath, cayleym)
+
+	w.Handle("GET", "/v1/alert", handlers.Alert.List)
+	w.Handle("PUT", "/v1/alert", handlers.Alert.Upsert)
+	w.Handle("GET", "/v1/alert/:name", handlers.Alert.Retrieve)
+	w.Handle("GET", "/v1/alert/:name/history", handlers.Alert.History)
+	w.Handle("DELETE", "/v1/alert/:name", handlers.Alert.Delete)
 
 	w.Handle("GET", "/v1/relationship", handlers.Relationship.List)
 	w.Handle("PUT", "/v1/relationship", handlers.Relationship.Upsert)
@@ -141,4 +266,8 @@ func routes(w *web.Web) {
 	w.Handle("PUT", "/v1/view", handlers.View.Upsert)
 	w.Handle("GET", "/v1/view/:name", handlers.View.Retrieve)
 	w.Handle("DELETE", "/v1/view/:name", handlers.View.Delete)
+
+	w.Handle("GET", "/v1/persist", handlers.View.PersistStatus)
+	w.Handle("GET", "/v1/persist/:collection", handlers.View.PersistRetrieve)
+	w.Handle("DELETE", "/v1/persist/:collection", handlers.View.PersistDelete)
 }
@@ -265,3 +265,38 @@ func TestExecExplain(t *testing.T) {
 		}
 	}
 }
+
+// TestExecBatch tests the execution of a batch of queries.
+func TestExecBatch(t *testing.T) {
+	tests.ResetLog()
+	defer tests.DisplayLog()
+
+	t.Log("Given the need to execute a batch of queries.")
+	{
+		url := "/v1/exec/batch"
+		body := `{"sets":[{"name":"` + qPrefix + `_basic","vars":{"station_id":"42021"}},{"name":"` + qPrefix + `_missing"}]}`
+
+		r := httptest.NewRequest("POST", url, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		a.ServeHTTP(w, r)
+
+		t.Logf("\tWhen calling url : %s", url)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tShould be able to execute the batch : %v", tests.Failed, w.Code)
+			}
+			t.Logf("\t%s\tShould be able to execute the batch.", tests.Success)
+
+			recv := tests.IndentJSON(w.Body.String())
+			resp := tests.IndentJSON(`{"` + qPrefix + `_basic":{"results":[{"Name":"Basic","Docs":[{"name":"C14 - Pasco County Buoy, FL"}]}]},"` + qPrefix + `_missing":{"results":{"error":"Set Not found"}}}`)
+
+			if resp != recv {
+				t.Log(resp)
+				t.Log(recv)
+				t.Fatalf("\t%s\tShould get the expected result.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould get the expected result.", tests.Success)
+		}
+	}
+}
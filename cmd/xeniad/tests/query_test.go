@@ -4,6 +4,7 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
@@ -91,6 +92,36 @@ func TestQueryByName(t *testing.T) {
 	}
 }
 
+// TestQueryACL tests that sets restricted by an ACL are not available to
+// callers without the required roles.
+func TestQueryACL(t *testing.T) {
+	tests.ResetLog()
+	defer tests.DisplayLog()
+
+	t.Log("Given the need to protect a set with an ACL.")
+	{
+		urls := []string{
+			"/v1/query/" + qPrefix + "_basic_acl",
+			"/v1/exec/" + qPrefix + "_basic_acl",
+		}
+
+		for _, url := range urls {
+			r := httptest.NewRequest("GET", url, nil)
+			w := httptest.NewRecorder()
+
+			a.ServeHTTP(w, r)
+
+			t.Logf("\tWhen calling url without the required roles : %s", url)
+			{
+				if w.Code != http.StatusUnauthorized {
+					t.Fatalf("\t%s\tShould be denied access to the set : %v", tests.Failed, w.Code)
+				}
+				t.Logf("\t%s\tShould be denied access to the set.", tests.Success)
+			}
+		}
+	}
+}
+
 // TestQueryUpsert tests the insert and update of a set.
 func TestQueryUpsert(t *testing.T) {
 	tests.ResetLog()
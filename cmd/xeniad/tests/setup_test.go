@@ -86,6 +86,10 @@ func runTest(m *testing.M) int {
 		fmt.Println("Could not load queries in basic_var.json")
 		return 1
 	}
+	if err := loadQuery(db, "basic_acl.json"); err != nil {
+		fmt.Println("Could not load queries in basic_acl.json")
+		return 1
+	}
 	defer qfix.Remove(db, "QTEST_O")
 
 	if err := loadScript(db, "basic_script_pre.json"); err != nil {
@@ -225,7 +229,7 @@ func loadItems(context interface{}, db *db.DB, store *cayley.Handle) error {
 	}
 
 	for _, itm := range items {
-		if err := sponge.Import(context, db, store, &itm); err != nil {
+		if _, err := sponge.Import(context, db, store, &itm); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,84 @@
+package auth
+
+import "strings"
+
+// Set of operations an ACL controls access to.
+const (
+	OpRead  = "read"
+	OpExec  = "exec"
+	OpWrite = "write"
+)
+
+// grantClaims are the claims inspected for the roles and scopes granted to
+// the caller. Each claim can be a space separated string or an array of strings.
+var grantClaims = []string{"roles", "role", "scopes", "scope"}
+
+// ACL describes the roles or scopes a caller must hold to perform each
+// operation against a resource. A caller needs to hold any one of the listed
+// values. An empty list or a nil ACL leaves the operation open to every caller.
+type ACL struct {
+	Read  []string `bson:"read,omitempty" json:"read,omitempty"`
+	Exec  []string `bson:"exec,omitempty" json:"exec,omitempty"`
+	Write []string `bson:"write,omitempty" json:"write,omitempty"`
+}
+
+// Allowed reports if the claims grant the caller the operation.
+func (a *ACL) Allowed(claims map[string]interface{}, op string) bool {
+	if a == nil {
+		return true
+	}
+
+	var required []string
+	switch op {
+	case OpRead:
+		required = a.Read
+	case OpExec:
+		required = a.Exec
+	case OpWrite:
+		required = a.Write
+	default:
+		return false
+	}
+
+	// An empty list of requirements is open to everyone.
+	if len(required) == 0 {
+		return true
+	}
+
+	grants := Grants(claims)
+	for _, r := range required {
+		if grants[r] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Grants returns the set of roles and scopes found in the claims.
+func Grants(claims map[string]interface{}) map[string]bool {
+	grants := make(map[string]bool)
+
+	for _, key := range grantClaims {
+		switch v := claims[key].(type) {
+		case string:
+			for _, g := range strings.Fields(v) {
+				grants[g] = true
+			}
+
+		case []string:
+			for _, g := range v {
+				grants[g] = true
+			}
+
+		case []interface{}:
+			for _, g := range v {
+				if s, ok := g.(string); ok {
+					grants[s] = true
+				}
+			}
+		}
+	}
+
+	return grants
+}
@@ -3,13 +3,130 @@ package db
 import (
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/graph"
 	cayleykit "github.com/coralproject/shelf/internal/platform/db/cayley"
+	mgo "gopkg.in/mgo.v2"
 )
 
-// ErrGraphHandle is returned when a graph handle is not initialized.
-var ErrGraphHandle = errors.New("Graph handle not initialized.")
+// Set of graph backends a Cayley handle can be created with.
+const (
+	GraphMongo  = "mongo"    // Quads stored in MongoDB alongside the items.
+	GraphMemory = "memstore" // Quads held in memory, shared by the process.
+	GraphBolt   = "bolt"     // Quads held in memory and persisted to a BoltDB file.
+)
+
+var (
+	// ErrGraphHandle is returned when a graph handle is not initialized.
+	ErrGraphHandle = errors.New("Graph handle not initialized.")
+
+	// ErrGraphBackend is returned when a graph backend is not supported.
+	ErrGraphBackend = errors.New("Graph backend not supported.")
+)
+
+// graphBackend holds the backend used to create Cayley handles. The
+// in-memory and bolt stores are created on first use and shared by every DB
+// value.
+var graphBackend = struct {
+	sync.RWMutex
+	name string
+	path string
+	mem  *cayley.Handle
+	bolt *cayley.Handle
+}{
+	name: GraphMongo,
+}
+
+// sharedStore is a quad store shared by every DB value, which is left open
+// when their handles are closed.
+type sharedStore struct {
+	graph.QuadStore
+}
+
+// Close does nothing since the store is shared by the process.
+func (sharedStore) Close() {}
+
+// SetGraphBackend selects the backend used for the Cayley handles created
+// after the call. The path is the BoltDB file of the bolt backend and is
+// ignored by the others. The in-memory backend is meant for tests and small
+// single process deployments since its quads are lost when the process exits.
+// The bolt backend also holds the whole graph in memory.
+func SetGraphBackend(name, path string) error {
+	switch name {
+	case GraphMongo, GraphMemory:
+
+	case GraphBolt:
+		if path == "" {
+			return fmt.Errorf("%v : %q needs the path of a file", ErrGraphBackend, name)
+		}
+
+	default:
+		return fmt.Errorf("%v : %q", ErrGraphBackend, name)
+	}
+
+	graphBackend.Lock()
+	{
+		graphBackend.name = name
+		graphBackend.path = path
+	}
+	graphBackend.Unlock()
+
+	return nil
+}
+
+// GraphBackend returns the backend used to create Cayley handles.
+func GraphBackend() string {
+	graphBackend.RLock()
+	defer graphBackend.RUnlock()
+
+	return graphBackend.name
+}
+
+// newGraphHandle creates a Cayley handle for the configured backend. The
+// mongo backend works on its own copy of the provided session.
+func newGraphHandle(ses *mgo.Session) (*cayley.Handle, error) {
+	graphBackend.Lock()
+	defer graphBackend.Unlock()
+
+	switch graphBackend.name {
+	case GraphMemory:
+
+		// Closing a memstore handle is a no-op so the same handle can be
+		// given to every DB value.
+		if graphBackend.mem == nil {
+			store, err := cayley.NewMemoryGraph()
+			if err != nil {
+				return nil, err
+			}
+
+			graphBackend.mem = store
+		}
+
+		return graphBackend.mem, nil
+
+	case GraphBolt:
+
+		// A bolt file can only be opened once, so its handle is shared and
+		// kept open for the life of the process.
+		if graphBackend.bolt == nil {
+			store, err := cayleykit.NewBolt(graphBackend.path)
+			if err != nil {
+				return nil, err
+			}
+
+			graphBackend.bolt = &cayley.Handle{
+				QuadStore:  sharedStore{store.QuadStore},
+				QuadWriter: store.QuadWriter,
+			}
+		}
+
+		return graphBackend.bolt, nil
+	}
+
+	return cayleykit.New("", ses.Copy())
+}
 
 //==============================================================================
 
@@ -28,8 +145,7 @@ func (db *DB) NewCayley(context interface{}, name string) error {
 		return fmt.Errorf("Master sesssion %q does not exist", name)
 	}
 
-	ses := masterDB.ses.Copy()
-	store, err := cayleykit.New("", ses)
+	store, err := newGraphHandle(masterDB.ses)
 	if err != nil {
 		return err
 	}
@@ -51,7 +167,10 @@ func (db *DB) GraphHandle(context interface{}) (*cayley.Handle, error) {
 	return nil, ErrGraphHandle
 }
 
-// CloseCayley closes a graph handle value.
+// CloseCayley closes a graph handle value. A copy of a DB value that had no
+// graph handle has nothing to close.
 func (db *DB) CloseCayley(context interface{}) {
-	db.graphHandle.Close()
+	if db.graphHandle != nil {
+		db.graphHandle.Close()
+	}
 }
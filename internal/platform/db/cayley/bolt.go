@@ -0,0 +1,240 @@
+package cayley
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	bolt "go.etcd.io/bbolt"
+
+	// The quads of a bolt file are served by an in-memory quad store.
+	_ "github.com/cayleygraph/cayley/graph/memstore"
+)
+
+// quadsBucket is the bolt bucket holding the quads, keyed by their encoding.
+var quadsBucket = []byte("quads")
+
+// boltValue is the encoding of a quad value, which keeps its kind so the value
+// is read back as it was written.
+type boltValue struct {
+	Kind  string `json:"k"`
+	Value string `json:"v"`
+}
+
+// encodeQuad returns the key of the quad in the file.
+func encodeQuad(q quad.Quad) ([]byte, error) {
+	var vs [4]*boltValue
+	for i, v := range []quad.Value{q.Subject, q.Predicate, q.Object, q.Label} {
+		switch v := v.(type) {
+		case nil:
+		case quad.String:
+			vs[i] = &boltValue{Kind: "string", Value: string(v)}
+		case quad.IRI:
+			vs[i] = &boltValue{Kind: "iri", Value: string(v)}
+		case quad.BNode:
+			vs[i] = &boltValue{Kind: "bnode", Value: string(v)}
+		case quad.Raw:
+			vs[i] = &boltValue{Kind: "raw", Value: string(v)}
+		default:
+			return nil, fmt.Errorf("unsupported quad value %T", v)
+		}
+	}
+
+	return json.Marshal(vs)
+}
+
+// decodeQuad returns the quad stored under the key.
+func decodeQuad(k []byte) (quad.Quad, error) {
+	var vs [4]*boltValue
+	if err := json.Unmarshal(k, &vs); err != nil {
+		return quad.Quad{}, err
+	}
+
+	var qv [4]quad.Value
+	for i, v := range vs {
+		if v == nil {
+			continue
+		}
+
+		switch v.Kind {
+		case "string":
+			qv[i] = quad.String(v.Value)
+		case "iri":
+			qv[i] = quad.IRI(v.Value)
+		case "bnode":
+			qv[i] = quad.BNode(v.Value)
+		case "raw":
+			qv[i] = quad.Raw(v.Value)
+		default:
+			return quad.Quad{}, fmt.Errorf("unsupported quad value kind %q", v.Kind)
+		}
+	}
+
+	return quad.Quad{Subject: qv[0], Predicate: qv[1], Object: qv[2], Label: qv[3]}, nil
+}
+
+// boltStore serves the quads of a BoltDB file from memory. Every change is
+// applied in memory, which checks it, and then written to the file.
+type boltStore struct {
+	graph.QuadStore
+	mu sync.Mutex
+	db *bolt.DB
+}
+
+// NewBolt creates a cayley handle for the quads stored in the BoltDB file at
+// the path, creating the file if it doesn't exist. The file only persists the
+// quads: every quad is loaded into an in-memory store when the file is opened
+// and queries are served from memory, so the whole graph must fit in RAM. A
+// file can only be opened by one handle at a time.
+func NewBolt(path string) (*cayley.Handle, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	mem, err := graph.NewQuadStore("memstore", "", nil)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Load the quads of the file.
+	var deltas []graph.Delta
+	f := func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(quadsBucket)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return b.ForEach(func(k, v []byte) error {
+			q, err := decodeQuad(k)
+			if err != nil {
+				return err
+			}
+
+			deltas = append(deltas, graph.Delta{
+				ID:        graph.NewSequentialKey(int64(len(deltas) + 1)),
+				Quad:      q,
+				Action:    graph.Add,
+				Timestamp: now,
+			})
+			return nil
+		})
+	}
+
+	if err := db.Update(f); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := mem.ApplyDeltas(deltas, graph.IgnoreOpts{IgnoreDup: true}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	qs := boltStore{
+		QuadStore: mem,
+		db:        db,
+	}
+
+	qw, err := graph.NewQuadWriter("single", &qs, nil)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &cayley.Handle{QuadStore: &qs, QuadWriter: qw}, nil
+}
+
+// ApplyDeltas applies the changes in memory and writes them to the file. The
+// changes are undone in memory if they can't be written.
+func (qs *boltStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	// Only the changes actually made in memory are written or undone.
+	var applied []int
+	for i := range deltas {
+		if (deltas[i].Action == graph.Add) != qs.exists(deltas[i].Quad) {
+			applied = append(applied, i)
+		}
+	}
+
+	if err := qs.QuadStore.ApplyDeltas(deltas, ignoreOpts); err != nil {
+		return err
+	}
+
+	f := func(tx *bolt.Tx) error {
+		b := tx.Bucket(quadsBucket)
+		for _, i := range applied {
+			k, err := encodeQuad(deltas[i].Quad)
+			if err != nil {
+				return err
+			}
+
+			if deltas[i].Action == graph.Add {
+				err = b.Put(k, nil)
+			} else {
+				err = b.Delete(k)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := qs.db.Update(f); err != nil {
+		undo := make([]graph.Delta, 0, len(applied))
+		for _, i := range applied {
+			action := graph.Delete
+			if deltas[i].Action == graph.Delete {
+				action = graph.Add
+			}
+			undo = append(undo, graph.Delta{
+				ID:        graph.NewSequentialKey(deltas[i].ID.Int()),
+				Quad:      deltas[i].Quad,
+				Action:    action,
+				Timestamp: deltas[i].Timestamp,
+			})
+		}
+		qs.QuadStore.ApplyDeltas(undo, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true})
+		return err
+	}
+
+	return nil
+}
+
+// exists reports if the quad is in memory.
+func (qs *boltStore) exists(q quad.Quad) bool {
+	v := qs.QuadStore.ValueOf(q.Subject)
+	if v == nil {
+		return false
+	}
+
+	it := qs.QuadStore.QuadIterator(quad.Subject, v)
+	defer it.Close()
+
+	for it.Next() {
+		if qs.QuadStore.Quad(it.Result()) == q {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Close releases the file.
+func (qs *boltStore) Close() {
+	qs.db.Close()
+}
+
+// Type returns the type of the quad store.
+func (qs *boltStore) Type() string {
+	return "bolt"
+}
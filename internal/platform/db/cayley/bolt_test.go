@@ -0,0 +1,79 @@
+package cayley_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	cayleykit "github.com/coralproject/shelf/internal/platform/db/cayley"
+)
+
+// TestBolt tests storing the quads of a graph in a BoltDB file.
+func TestBolt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolt")
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a directory : %v", tests.Failed, err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "graph.db")
+	kept := quad.Make("bilbo", "authored", "comment", "")
+	removed := quad.Make("gandalf", "authored", "comment", "")
+
+	t.Log("Given the need to store the graph in a BoltDB file.")
+	{
+		t.Log("\tWhen changing the quads of a new file")
+		{
+			store, err := cayleykit.NewBolt(path)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to open the file : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to open the file.", tests.Success)
+
+			for _, q := range []quad.Quad{kept, removed} {
+				if err := store.AddQuad(q); err != nil {
+					t.Fatalf("\t%s\tShould be able to add a quad : %v", tests.Failed, err)
+				}
+			}
+			t.Logf("\t%s\tShould be able to add quads.", tests.Success)
+
+			if err := store.AddQuad(kept); !graph.IsQuadExist(err) {
+				t.Fatalf("\t%s\tShould not be able to add a quad twice : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould not be able to add a quad twice.", tests.Success)
+
+			if err := store.RemoveQuad(removed); err != nil {
+				t.Fatalf("\t%s\tShould be able to remove a quad : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to remove a quad.", tests.Success)
+
+			store.Close()
+		}
+
+		t.Log("\tWhen opening the file again")
+		{
+			store, err := cayleykit.NewBolt(path)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to open the file again : %v", tests.Failed, err)
+			}
+			defer store.Close()
+			t.Logf("\t%s\tShould be able to open the file again.", tests.Success)
+
+			var quads []quad.Quad
+			it := store.QuadsAllIterator()
+			for it.Next() {
+				quads = append(quads, store.Quad(it.Result()))
+			}
+			it.Close()
+
+			if len(quads) != 1 || quads[0] != kept {
+				t.Fatalf("\t%s\tShould get back the quads left in the file : %v", tests.Failed, quads)
+			}
+			t.Logf("\t%s\tShould get back the quads left in the file.", tests.Success)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-// Package cayley provides support for the cayley Graph DB with a Mongo backend.
+// Package cayley provides support for the cayley Graph DB with a Mongo or BoltDB backend.
 package cayley
 
 import (
@@ -19,3 +19,34 @@ type DB struct {
 	// Cayley support
 	graphHandle *cayley.Handle
 }
+
+//==============================================================================
+
+// Copy returns a new DB value with its own copy of the MongoDB session and,
+// if graph support was added, its own Cayley handle. This is used for work
+// that must outlive the request that owns the original DB value. The caller
+// is responsible for closing the new value.
+func (db *DB) Copy(context interface{}) (*DB, error) {
+	if db == nil || db.session == nil {
+		return nil, ErrInvalidDBProvided
+	}
+
+	ses := db.session.Copy()
+
+	dbOut := DB{
+		database: ses.DB(db.database.Name),
+		session:  ses,
+	}
+
+	if db.graphHandle != nil {
+		store, err := newGraphHandle(ses)
+		if err != nil {
+			ses.Close()
+			return nil, err
+		}
+
+		dbOut.graphHandle = store
+	}
+
+	return &dbOut, nil
+}
@@ -153,6 +153,22 @@ func (db *DB) CollectionMGO(context interface{}, colName string) (*mgo.Collectio
 	return db.database.C(colName), nil
 }
 
+// RenameCollectionMGO atomically renames a collection, replacing the target
+// collection if it already exists.
+func (db *DB) RenameCollectionMGO(context interface{}, from string, to string) error {
+	if db == nil || db.session == nil {
+		return ErrInvalidDBProvided
+	}
+
+	cmd := bson.D{
+		{Name: "renameCollection", Value: db.database.Name + "." + from},
+		{Name: "to", Value: db.database.Name + "." + to},
+		{Name: "dropTarget", Value: true},
+	}
+
+	return db.session.Run(cmd, nil)
+}
+
 // CollectionMGOTimeout is used to get a collection value with a timeout.
 func (db *DB) CollectionMGOTimeout(context interface{}, timeout time.Duration, colName string) (*mgo.Collection, error) {
 	if db == nil || db.session == nil {
@@ -0,0 +1,94 @@
+package mongo
+
+import (
+	"strings"
+)
+
+// PrepareForInsert walks the document preprocessing keys for insert.
+//
+// MongoDB will not let us save field names with '$' in the beginning or
+// using dot (name.name) notation. We need to change that out to save.
+func PrepareForInsert(doc map[string]interface{}) {
+	for key, value := range doc {
+
+		// Test for the type of value we have.
+		switch sub := value.(type) {
+
+		// We have another document.
+		case map[string]interface{}:
+			PrepareForInsert(sub)
+
+		// We have an array of values.
+		case []interface{}:
+
+			// Iterate over the array of values.
+			for _, subDoc := range sub {
+
+				// I only care about documents because we are looking for keys.
+				if cmd, ok := subDoc.(map[string]interface{}); ok {
+					PrepareForInsert(cmd)
+				}
+			}
+		}
+
+		if strings.HasPrefix(key, "$") {
+
+			// Replace any key we find starts with $.
+			delete(doc, key)
+			doc["_"+key] = value
+
+		} else {
+
+			// Replace any key we find that has dot notation.
+			if strings.Contains(key, ".") {
+				delete(doc, key)
+				doc[strings.Replace(key, ".", "*", -1)] = value
+			}
+		}
+	}
+}
+
+// PrepareForUse walks the document preprocessing keys for use.
+//
+// MongoDB will not let us save field names with '$' in the beginning or
+// using dot (name.name) notation. We need to change that out to save. But
+// when we get the document back, we need to replace things back.
+func PrepareForUse(doc map[string]interface{}) {
+	for key, value := range doc {
+
+		// Test for the type of value we have.
+		switch sub := value.(type) {
+
+		// We have another document.
+		case map[string]interface{}:
+			PrepareForUse(sub)
+
+		// We have an array of values.
+		case []interface{}:
+
+			// Iterate over the array of values.
+			for _, subDoc := range sub {
+
+				// I only care about documents because we are looking for keys.
+				if cmd, ok := subDoc.(map[string]interface{}); ok {
+					PrepareForUse(cmd)
+				}
+			}
+		}
+
+		if strings.HasPrefix(key, "_$") {
+
+			// Replace any key we find starts with _$.
+			delete(doc, key)
+			doc[key[1:]] = value
+
+		} else {
+
+			// Replace any key we find that has *.
+			if strings.Contains(key, "*") {
+				delete(doc, key)
+				doc[strings.Replace(key, "*", ".", -1)] = value
+			}
+		}
+	}
+}
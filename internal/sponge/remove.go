@@ -0,0 +1,246 @@
+package sponge
+
+import (
+	"fmt"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/cayleygraph/cayley"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/sponge/item"
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/coralproject/shelf/internal/wire/relationship"
+)
+
+// RemovePlan describes the changes made by removing an item according to the
+// on delete policies of the relationships other items hold to it.
+type RemovePlan struct {
+	Removed    []string         `json:"removed"`    // Items removed, the item first.
+	Detached   []wire.Dependent `json:"detached"`   // Relationships removed from the graph.
+	Kept       []wire.Dependent `json:"kept"`       // Relationships left in the graph.
+	Restricted []wire.Dependent `json:"restricted"` // Relationships preventing the removal.
+}
+
+// RestrictError is returned when an item can't be removed because other
+// items hold relationships to it with a restrict policy.
+type RestrictError struct {
+	ItemID     string
+	Dependents []wire.Dependent
+}
+
+// Error implements the error interface.
+func (e *RestrictError) Error() string {
+	return fmt.Sprintf("Item %q can't be removed, %d relationships restrict its removal", e.ItemID, len(e.Dependents))
+}
+
+//==============================================================================
+
+// PlanRemove returns the changes Remove would make to remove an item without
+// making them.
+func PlanRemove(context interface{}, db *db.DB, graph *cayley.Handle, itemID string) (*RemovePlan, error) {
+	log.Dev(context, "PlanRemove", "Started : ID[%s]", itemID)
+
+	plan, _, err := planRemove(context, db, graph, itemID)
+	if err != nil {
+		log.Error(context, "PlanRemove", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "PlanRemove", "Completed : Removed[%d] Detached[%d] Restricted[%d]", len(plan.Removed), len(plan.Detached), len(plan.Restricted))
+	return plan, nil
+}
+
+// Remove removes an item from the items collection and its quads from the
+// graph database, applying the on delete policies of the relationships other
+// items hold to it. Nothing is changed and a *RestrictError is returned if
+// any of them restricts the removal. The graph and the items collection are
+// restored if the removal fails part way.
+func Remove(context interface{}, db *db.DB, graph *cayley.Handle, itemID string) error {
+	log.Dev(context, "Remove", "Started : ID[%s]", itemID)
+
+	plan, items, err := planRemove(context, db, graph, itemID)
+	if err != nil {
+		log.Error(context, "Remove", err, "Completed")
+		return err
+	}
+
+	if len(plan.Restricted) > 0 {
+		err := &RestrictError{ItemID: itemID, Dependents: plan.Restricted}
+		log.Error(context, "Remove", err, "Completed")
+		return err
+	}
+
+	if err := applyRemove(context, db, graph, plan, items); err != nil {
+		log.Error(context, "Remove", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "Remove", "Completed : Removed[%d] Detached[%d]", len(plan.Removed), len(plan.Detached))
+	return nil
+}
+
+//==============================================================================
+
+// planRemove follows the relationships held to the item, and to the items
+// removed with it, to find the changes needed to remove it. The items to
+// remove are returned in the order of the plan.
+func planRemove(context interface{}, db *db.DB, graph *cayley.Handle, itemID string) (*RemovePlan, []item.Item, error) {
+	itm, err := item.GetByID(context, db, itemID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var items []item.Item
+	var deps []wire.Dependent
+	removing := map[string]bool{itemID: true}
+
+	// Removing an item may cascade to the items holding relationships to it.
+	queue := []item.Item{itm}
+	for len(queue) > 0 {
+		itm := queue[0]
+		queue = queue[1:]
+		items = append(items, itm)
+
+		ds, err := wire.Dependents(context, db, graph, itemMap(&itm))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, d := range ds {
+			if d.OnDelete != relationship.OnDeleteCascade || removing[d.ItemID] {
+				deps = append(deps, d)
+				continue
+			}
+
+			dep, err := item.GetByID(context, db, d.ItemID)
+			if err != nil {
+				if err != item.ErrNotFound {
+					return nil, nil, err
+				}
+
+				// Only the graph knows the item, so detach it instead.
+				d.OnDelete = relationship.OnDeleteDetach
+				deps = append(deps, d)
+				continue
+			}
+
+			removing[d.ItemID] = true
+			queue = append(queue, dep)
+		}
+	}
+
+	plan := RemovePlan{
+		Removed:    make([]string, 0, len(items)),
+		Detached:   []wire.Dependent{},
+		Kept:       []wire.Dependent{},
+		Restricted: []wire.Dependent{},
+	}
+
+	for _, itm := range items {
+		plan.Removed = append(plan.Removed, itm.ID)
+	}
+
+	// The relationships held by removed items go away with them.
+	for _, d := range deps {
+		if removing[d.ItemID] {
+			continue
+		}
+
+		switch d.OnDelete {
+		case relationship.OnDeleteRestrict:
+			plan.Restricted = append(plan.Restricted, d)
+		case relationship.OnDeleteDetach:
+			plan.Detached = append(plan.Detached, d)
+		default:
+			plan.Kept = append(plan.Kept, d)
+		}
+	}
+
+	return &plan, items, nil
+}
+
+// applyRemove makes the changes of a plan. The graph is changed before the
+// items collection, and both are restored if a change fails.
+func applyRemove(context interface{}, db *db.DB, graph *cayley.Handle, plan *RemovePlan, items []item.Item) error {
+	var ungraphed, deleted []item.Item
+	var detached bool
+
+	// restore adds back the relationships and items already removed.
+	restore := func(cause error) error {
+		for i := range deleted {
+			if err := item.Upsert(context, db, &deleted[i]); err != nil {
+				log.Error(context, "applyRemove", err, "Restoring item %s", deleted[i].ID)
+			}
+		}
+
+		if detached {
+			holders, err := detachedHolders(context, db, plan.Detached)
+			if err != nil {
+				log.Error(context, "applyRemove", err, "Restoring detached relationships")
+			}
+			ungraphed = append(ungraphed, holders...)
+		}
+
+		for i := range ungraphed {
+			if _, err := wire.AddToGraph(context, db, graph, itemMap(&ungraphed[i])); err != nil {
+				log.Error(context, "applyRemove", err, "Restoring relationships of item %s", ungraphed[i].ID)
+			}
+		}
+
+		return cause
+	}
+
+	// Remove the relationships of the removed items from the graph.
+	for _, itm := range items {
+		if _, err := wire.RemoveFromGraph(context, db, graph, itemMap(&itm)); err != nil {
+			return restore(err)
+		}
+		ungraphed = append(ungraphed, itm)
+	}
+
+	// Detach the relationships of the items that are kept.
+	if len(plan.Detached) > 0 {
+		edges := make([]wire.Edge, 0, len(plan.Detached))
+		for _, d := range plan.Detached {
+			edges = append(edges, d.Edge)
+		}
+
+		detached = true
+		if _, err := wire.DetachFromGraph(context, db, graph, edges); err != nil {
+			return restore(err)
+		}
+	}
+
+	// Delete the items.
+	for _, itm := range items {
+		if err := item.Delete(context, db, itm.ID); err != nil {
+			return restore(err)
+		}
+		deleted = append(deleted, itm)
+	}
+
+	return nil
+}
+
+// detachedHolders returns the items holding the detached relationships.
+func detachedHolders(context interface{}, db *db.DB, deps []wire.Dependent) ([]item.Item, error) {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, d := range deps {
+		if !seen[d.ItemID] {
+			seen[d.ItemID] = true
+			ids = append(ids, d.ItemID)
+		}
+	}
+
+	return item.GetByIDs(context, db, ids)
+}
+
+// itemMap returns the generic item data map of an item.
+func itemMap(itm *item.Item) map[string]interface{} {
+	return map[string]interface{}{
+		"item_id": itm.ID,
+		"type":    itm.Type,
+		"version": itm.Version,
+		"data":    itm.Data,
+	}
+}
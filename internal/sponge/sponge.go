@@ -12,9 +12,29 @@ import (
 )
 
 // Import imports an item into the items collections and into the graph database.
-func Import(context interface{}, db *db.DB, graph *cayley.Handle, itm *item.Item) error {
+// An item whose required inferences don't produce a relationship returns a
+// *wire.RequiredError and is not imported. The relationships added and removed,
+// the inferences skipped and the relationships between types not declared by
+// the relationship are reported.
+func Import(context interface{}, db *db.DB, graph *cayley.Handle, itm *item.Item) (*wire.InferenceReport, error) {
 	log.Dev(context, "Import", "Started : ID[%s]", itm.ID)
 
+	// Make sure the required relationships can be inferred before changing
+	// anything. The item may not have an ID yet.
+	reqMap := map[string]interface{}{
+		"item_id": itm.ID,
+		"type":    itm.Type,
+		"version": itm.Version,
+		"data":    itm.Data,
+	}
+
+	if err := wire.CheckRequired(context, db, reqMap); err != nil {
+		log.Error(context, "Import", err, "Completed")
+		return nil, err
+	}
+
+	var removed []wire.Edge
+
 	// If the item exists and is different than the provided item,
 	// we need to remove existing relationships.
 	if itm.ID != "" {
@@ -24,7 +44,7 @@ func Import(context interface{}, db *db.DB, graph *cayley.Handle, itm *item.Item
 		if err != nil {
 			if err != item.ErrNotFound {
 				log.Error(context, "Import", err, "Completed")
-				return err
+				return nil, err
 			}
 		}
 
@@ -36,7 +56,7 @@ func Import(context interface{}, db *db.DB, graph *cayley.Handle, itm *item.Item
 			// If the item is identical, we don't have to do anything.
 			if reflect.DeepEqual(itmOrig, itm) {
 				log.Dev(context, "Import", "Completed")
-				return nil
+				return &wire.InferenceReport{Added: []wire.Edge{}, Removed: []wire.Edge{}, Skipped: []wire.Skip{}}, nil
 			}
 
 			// If the item is not identical, remove the stale relationships by
@@ -49,20 +69,22 @@ func Import(context interface{}, db *db.DB, graph *cayley.Handle, itm *item.Item
 			}
 
 			// Remove the corresponding relationships from the graph.
-			if err := wire.RemoveFromGraph(context, db, graph, itmMap); err != nil {
+			report, err := wire.RemoveFromGraph(context, db, graph, itmMap)
+			if err != nil {
 				log.Error(context, "Import", err, "Completed")
-				return err
+				return nil, err
 			}
+			removed = report.Removed
 		}
 	}
 
 	// Add the item to the items collection.
 	if err := item.Upsert(context, db, itm); err != nil {
 		log.Error(context, "Import", err, "Completed")
-		return err
+		return nil, err
 	}
 
-	// Prepare the generic item data map.
+	// Prepare the generic item data map now the item has an ID.
 	itmMap := map[string]interface{}{
 		"item_id": itm.ID,
 		"type":    itm.Type,
@@ -71,47 +93,45 @@ func Import(context interface{}, db *db.DB, graph *cayley.Handle, itm *item.Item
 	}
 
 	// Infer relationships and add them to the graph.
-	if err := wire.AddToGraph(context, db, graph, itmMap); err != nil {
+	report, err := wire.AddToGraph(context, db, graph, itmMap)
+	if err != nil {
 		log.Error(context, "Import", err, "Completed")
-		return err
+		return nil, err
 	}
 
-	log.Dev(context, "Import", "Completed")
-	return nil
-}
+	// Relationships held by both versions of the item are unchanged.
+	report.Added, report.Removed = netEdges(report.Added, removed)
 
-// Remove removes an item into the items collection and remove any
-// corresponding quads from the graph database.
-func Remove(context interface{}, db *db.DB, graph *cayley.Handle, itemID string) error {
-	log.Dev(context, "Remove", "Started : ID[%s]", itemID)
+	log.Dev(context, "Import", "Completed : Added[%d] Removed[%d] Skipped[%d]", len(report.Added), len(report.Removed), len(report.Skipped))
+	return report, nil
+}
 
-	// Get the item from the items collection.
-	items, err := item.GetByIDs(context, db, []string{itemID})
-	if err != nil {
-		log.Error(context, "Remove", err, "Completed")
-		return err
+// netEdges returns the added relationships that weren't removed and the
+// removed relationships that weren't added back.
+func netEdges(added, removed []wire.Edge) ([]wire.Edge, []wire.Edge) {
+	inAdded := make(map[wire.Edge]bool)
+	for _, e := range added {
+		inAdded[e] = true
 	}
 
-	// Prepare the item map data.
-	itmMap := map[string]interface{}{
-		"item_id": items[0].ID,
-		"type":    items[0].Type,
-		"version": items[0].Version,
-		"data":    items[0].Data,
+	inRemoved := make(map[wire.Edge]bool)
+	for _, e := range removed {
+		inRemoved[e] = true
 	}
 
-	// Remove the corresponding relationships from the graph.
-	if err := wire.RemoveFromGraph(context, db, graph, itmMap); err != nil {
-		log.Error(context, "Remove", err, "Completed")
-		return err
+	netAdded := []wire.Edge{}
+	for _, e := range added {
+		if !inRemoved[e] {
+			netAdded = append(netAdded, e)
+		}
 	}
 
-	// Delete the item.
-	if err := item.Delete(context, db, itemID); err != nil {
-		log.Error(context, "Remove", err, "Completed")
-		return err
+	netRemoved := []wire.Edge{}
+	for _, e := range removed {
+		if !inAdded[e] {
+			netRemoved = append(netRemoved, e)
+		}
 	}
 
-	log.Dev(context, "Remove", "Completed")
-	return nil
+	return netAdded, netRemoved
 }
@@ -12,8 +12,11 @@ import (
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/coralproject/shelf/internal/sponge"
+	"github.com/coralproject/shelf/internal/sponge/item"
 	"github.com/coralproject/shelf/internal/sponge/item/itemfix"
+	"github.com/coralproject/shelf/internal/wire/pattern"
 	"github.com/coralproject/shelf/internal/wire/pattern/patternfix"
+	"github.com/coralproject/shelf/internal/wire/relationship"
 )
 
 const (
@@ -40,6 +43,13 @@ func TestMain(m *testing.M) {
 // the proper return code if the test fails or succeeds.
 func runTest(m *testing.M) int {
 
+	// Keep the graph in memory so the tests don't depend on the quads
+	// stored in MongoDB.
+	if err := db.SetGraphBackend(db.GraphMemory, ""); err != nil {
+		fmt.Println("Can't use the in-memory graph: " + err.Error())
+		return 1
+	}
+
 	// Initialize MongoDB using the `tests.TestSession` as the name of the
 	// master session.
 	if err := db.RegMasterSession(tests.Context, tests.TestSession, cfg.MustURL("MONGO_URI").String(), 0); err != nil {
@@ -125,7 +135,7 @@ func TestImportRemoveItem(t *testing.T) {
 		//----------------------------------------------------------------------
 		// Import the Item.
 
-		if err := sponge.Import(tests.Context, db, store, &items[0]); err != nil {
+		if _, err := sponge.Import(tests.Context, db, store, &items[0]); err != nil {
 			t.Fatalf("\t%s\tShould be able to import an item : %s", tests.Failed, err)
 		}
 		t.Logf("\t%s\tShould be able to import an item", tests.Success)
@@ -152,7 +162,7 @@ func TestImportRemoveItem(t *testing.T) {
 		//----------------------------------------------------------------------
 		// Import the Item again to test for duplicate imports.
 
-		if err := sponge.Import(tests.Context, db, store, &items[0]); err != nil {
+		if _, err := sponge.Import(tests.Context, db, store, &items[0]); err != nil {
 			t.Fatalf("\t%s\tShould be able to import a duplicate item : %s", tests.Failed, err)
 		}
 		t.Logf("\t%s\tShould be able to import a duplicate item", tests.Success)
@@ -184,5 +194,130 @@ func TestImportRemoveItem(t *testing.T) {
 			t.Fatalf("\t%s\tShould be able to confirm removed relationships.", tests.Failed)
 		}
 		t.Logf("\t%s\tShould be able to confirm removed relationships.", tests.Success)
+
+		//----------------------------------------------------------------------
+		// Import an item without an ID.
+
+		noID := items[0]
+		noID.ID = ""
+
+		report, err := sponge.Import(tests.Context, db, store, &noID)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to import an item without an ID : %s", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould be able to import an item without an ID.", tests.Success)
+
+		if noID.ID == "" || len(report.Added) == 0 {
+			t.Fatalf("\t%s\tShould infer the relationships of the new item : %+v", tests.Failed, report)
+		}
+		t.Logf("\t%s\tShould infer the relationships of the new item.", tests.Success)
+
+		if err := sponge.Remove(tests.Context, db, store, noID.ID); err != nil {
+			t.Fatalf("\t%s\tShould be able to remove the new item : %s", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould be able to remove the new item.", tests.Success)
+	}
+}
+
+// TestRemovePolicies tests applying the on delete policies of relationships
+// when removing items.
+func TestRemovePolicies(t *testing.T) {
+	db, store := setup(t)
+	defer teardown(t, db, store)
+
+	pat := pattern.Pattern{
+		Type: patternPrefix + "policy_comment",
+		Inferences: []pattern.Inference{
+			{RelIDField: "parent", Predicate: patternPrefix + "reply_to", Direction: "out"},
+			{RelIDField: "asset", Predicate: patternPrefix + "about", Direction: "out"},
+		},
+	}
+
+	if err := pattern.Upsert(tests.Context, db, &pat); err != nil {
+		t.Fatalf("\t%s\tShould be able to add the pattern : %s", tests.Failed, err)
+	}
+	defer pattern.Delete(tests.Context, db, pat.Type)
+
+	rels := []relationship.Relationship{
+		{
+			SubjectTypes: []string{pat.Type},
+			Predicate:    patternPrefix + "reply_to",
+			ObjectTypes:  []string{pat.Type},
+			OnDelete:     relationship.OnDeleteCascade,
+		},
+		{
+			SubjectTypes: []string{pat.Type},
+			Predicate:    patternPrefix + "about",
+			ObjectTypes:  []string{patternPrefix + "policy_asset"},
+			OnDelete:     relationship.OnDeleteRestrict,
+		},
+	}
+
+	for i := range rels {
+		if err := relationship.Upsert(tests.Context, db, &rels[i]); err != nil {
+			t.Fatalf("\t%s\tShould be able to add the relationship : %s", tests.Failed, err)
+		}
+		defer relationship.Delete(tests.Context, db, rels[i].Predicate)
+	}
+
+	asset := item.Item{ID: itemPrefix + "policy_asset", Type: patternPrefix + "policy_asset", Version: 1, Data: map[string]interface{}{}}
+	comment := item.Item{ID: itemPrefix + "policy_comment", Type: pat.Type, Version: 1, Data: map[string]interface{}{"asset": asset.ID}}
+	reply := item.Item{ID: itemPrefix + "policy_reply", Type: pat.Type, Version: 1, Data: map[string]interface{}{"asset": asset.ID, "parent": comment.ID}}
+
+	for _, itm := range []*item.Item{&asset, &comment, &reply} {
+		if _, err := sponge.Import(tests.Context, db, store, itm); err != nil {
+			t.Fatalf("\t%s\tShould be able to import an item : %s", tests.Failed, err)
+		}
+	}
+	defer sponge.Remove(tests.Context, db, store, asset.ID)
+
+	t.Log("Given the need to remove items holding relationships to each other.")
+	{
+		t.Log("\tWhen comments restrict the removal of their asset")
+		{
+			plan, err := sponge.PlanRemove(tests.Context, db, store, asset.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to plan the removal : %s", tests.Failed, err)
+			}
+
+			if len(plan.Restricted) != 2 {
+				t.Fatalf("\t%s\tShould have 2 relationships restricting the removal : %+v", tests.Failed, plan)
+			}
+			t.Logf("\t%s\tShould have 2 relationships restricting the removal.", tests.Success)
+
+			err = sponge.Remove(tests.Context, db, store, asset.ID)
+			if _, ok := err.(*sponge.RestrictError); !ok {
+				t.Fatalf("\t%s\tShould not be able to remove the asset : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould not be able to remove the asset.", tests.Success)
+		}
+
+		t.Log("\tWhen removing a comment cascades to its replies")
+		{
+			plan, err := sponge.PlanRemove(tests.Context, db, store, comment.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to plan the removal : %s", tests.Failed, err)
+			}
+
+			if len(plan.Removed) != 2 || plan.Removed[0] != comment.ID || plan.Removed[1] != reply.ID {
+				t.Fatalf("\t%s\tShould plan to remove the comment and its reply : %+v", tests.Failed, plan.Removed)
+			}
+			t.Logf("\t%s\tShould plan to remove the comment and its reply.", tests.Success)
+
+			if _, err := item.GetByID(tests.Context, db, reply.ID); err != nil {
+				t.Fatalf("\t%s\tShould keep the reply on a dry run : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould keep the reply on a dry run.", tests.Success)
+
+			if err := sponge.Remove(tests.Context, db, store, comment.ID); err != nil {
+				t.Fatalf("\t%s\tShould be able to remove the comment : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to remove the comment.", tests.Success)
+
+			if _, err := item.GetByID(tests.Context, db, reply.ID); err != item.ErrNotFound {
+				t.Fatalf("\t%s\tShould remove the reply with the comment : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould remove the reply with the comment.", tests.Success)
+		}
 	}
 }
@@ -0,0 +1,522 @@
+package wire
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/cayleygraph/cayley"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
+	"github.com/coralproject/shelf/internal/wire/view"
+	"github.com/pborman/uuid"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CacheCollection is the Mongo collection containing the metadata about
+// materialized view collections.
+const CacheCollection = "view_cache"
+
+// cachePrefix is the prefix used for the names of materialized view collections.
+const cachePrefix = "view_"
+
+// expiryInterval is how often the materialized views are checked for the
+// ones unused for longer than the retention.
+const expiryInterval = 10 * time.Minute
+
+// cachedView contains the metadata about a materialized view collection. The
+// version is increased by every invalidation, so a refresh only clears the
+// stale mark when the view was not invalidated while it was running.
+type cachedView struct {
+	Collection  string    `bson:"collection"`
+	ViewName    string    `bson:"view_name"`
+	ItemKey     string    `bson:"item_key"`
+	ItemIDs     []string  `bson:"item_ids"`
+	Stale       bool      `bson:"stale"`
+	Version     int       `bson:"version"`
+	RefreshedAt time.Time `bson:"refreshed_at"`
+}
+
+// cachePolicy controls how long a materialized view is served before it is
+// refreshed. Views younger than ttl are served as they are. Views older than
+// ttl but younger than maxAge are served and refreshed in the background. Older
+// or invalidated views are refreshed before they are served. Views that were
+// not refreshed for longer than the retention are no longer used and are
+// dropped.
+var cachePolicy = struct {
+	sync.RWMutex
+	ttl       time.Duration
+	maxAge    time.Duration
+	retention time.Duration
+}{
+	ttl:       time.Minute,
+	maxAge:    15 * time.Minute,
+	retention: 24 * time.Hour,
+}
+
+// SetCachePolicy sets the ttl and maximum age of materialized views.
+func SetCachePolicy(ttl time.Duration, maxAge time.Duration) {
+	if maxAge < ttl {
+		maxAge = ttl
+	}
+
+	cachePolicy.Lock()
+	{
+		cachePolicy.ttl = ttl
+		cachePolicy.maxAge = maxAge
+	}
+	cachePolicy.Unlock()
+}
+
+// SetCacheRetention sets how long a materialized view is kept without being
+// used. It can't be shorter than the maximum age of the views.
+func SetCacheRetention(retention time.Duration) {
+	cachePolicy.Lock()
+	{
+		if retention < cachePolicy.maxAge {
+			retention = cachePolicy.maxAge
+		}
+		cachePolicy.retention = retention
+	}
+	cachePolicy.Unlock()
+}
+
+// flight represents a refresh of a materialized view that is in progress.
+type flight struct {
+	wg  sync.WaitGroup
+	err error
+}
+
+// flights tracks the refreshes in progress so concurrent requests for
+// the same view and item share a single refresh.
+var flights = struct {
+	sync.Mutex
+	m map[string]*flight
+}{
+	m: make(map[string]*flight),
+}
+
+// expiry holds when the unused materialized views were last looked for.
+var expiry struct {
+	sync.Mutex
+	last time.Time
+}
+
+//==============================================================================
+
+// Materialize returns the name of a collection containing the items of the
+// view for the given item key. The collection is shared by all callers and is
+// refreshed according to the cache policy.
+func Materialize(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, viewName string, itemKey string) (string, error) {
+	log.Dev(context, "Materialize", "Started : Name[%s] Item[%s]", viewName, itemKey)
+
+	col := cacheCollectionName(viewName, itemKey)
+
+	expireBackground(context, mgoDB)
+
+	cv, err := getCachedView(context, mgoDB, col)
+	if err != nil {
+		log.Error(context, "Materialize", err, "Completed")
+		return "", err
+	}
+
+	cachePolicy.RLock()
+	ttl, maxAge := cachePolicy.ttl, cachePolicy.maxAge
+	cachePolicy.RUnlock()
+
+	// Serve the collection as it is if it is fresh enough.
+	if cv != nil && !cv.Stale {
+		age := time.Since(cv.RefreshedAt)
+
+		if age < ttl {
+			log.Dev(context, "Materialize", "Completed : CACHE : Collection[%s]", col)
+			return col, nil
+		}
+
+		if age < maxAge {
+			refreshBackground(context, mgoDB, viewName, itemKey, col)
+
+			log.Dev(context, "Materialize", "Completed : CACHE : Refreshing : Collection[%s]", col)
+			return col, nil
+		}
+	}
+
+	// Otherwise we need to wait on a refresh of the collection.
+	if err := refreshView(context, mgoDB, graphDB, viewName, itemKey, col); err != nil {
+		log.Error(context, "Materialize", err, "Completed")
+		return "", err
+	}
+
+	log.Dev(context, "Materialize", "Completed : Collection[%s]", col)
+	return col, nil
+}
+
+// InvalidateViews marks every materialized view containing any of the
+// provided item IDs as stale so it is refreshed on its next use. Views
+// already stale are marked again so refreshes in progress don't clear it.
+func InvalidateViews(context interface{}, mgoDB *db.DB, itemIDs []string) error {
+	log.Dev(context, "InvalidateViews", "Started : IDs[%d]", len(itemIDs))
+
+	if len(itemIDs) == 0 {
+		log.Dev(context, "InvalidateViews", "Completed")
+		return nil
+	}
+
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"item_ids": bson.M{"$in": itemIDs}}
+		u := bson.M{"$set": bson.M{"stale": true}, "$inc": bson.M{"version": 1}}
+		log.Dev(context, "InvalidateViews", "MGO : db.%s.update(%s, %s, {multi: true})", c.Name, mongo.Query(q), mongo.Query(u))
+		_, err := c.UpdateAll(q, u)
+		return err
+	}
+
+	if err := mgoDB.ExecuteMGO(context, CacheCollection, f); err != nil {
+		log.Error(context, "InvalidateViews", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "InvalidateViews", "Completed")
+	return nil
+}
+
+// InvalidateView marks every materialized collection of the named view as
+// stale so it is refreshed with the current definition of the view.
+func InvalidateView(context interface{}, mgoDB *db.DB, viewName string) error {
+	log.Dev(context, "InvalidateView", "Started : Name[%s]", viewName)
+
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"view_name": viewName}
+		u := bson.M{"$set": bson.M{"stale": true}, "$inc": bson.M{"version": 1}}
+		log.Dev(context, "InvalidateView", "MGO : db.%s.update(%s, %s, {multi: true})", c.Name, mongo.Query(q), mongo.Query(u))
+		_, err := c.UpdateAll(q, u)
+		return err
+	}
+
+	if err := mgoDB.ExecuteMGO(context, CacheCollection, f); err != nil {
+		log.Error(context, "InvalidateView", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "InvalidateView", "Completed")
+	return nil
+}
+
+// DropViews drops the materialized collections of the named view, which is
+// used when the view is removed.
+func DropViews(context interface{}, mgoDB *db.DB, viewName string) error {
+	log.Dev(context, "DropViews", "Started : Name[%s]", viewName)
+
+	n, err := dropCachedViews(context, mgoDB, bson.M{"view_name": viewName})
+	if err != nil {
+		log.Error(context, "DropViews", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "DropViews", "Completed : Collections[%d]", n)
+	return nil
+}
+
+// ExpireViews drops the materialized collections that were not refreshed for
+// longer than the retention. Views are refreshed when used past their ttl, so
+// those collections are no longer used.
+func ExpireViews(context interface{}, mgoDB *db.DB) error {
+	log.Dev(context, "ExpireViews", "Started")
+
+	cachePolicy.RLock()
+	retention := cachePolicy.retention
+	cachePolicy.RUnlock()
+
+	q := bson.M{"refreshed_at": bson.M{"$lt": time.Now().Add(-retention)}}
+	n, err := dropCachedViews(context, mgoDB, q)
+	if err != nil {
+		log.Error(context, "ExpireViews", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "ExpireViews", "Completed : Collections[%d]", n)
+	return nil
+}
+
+//==============================================================================
+
+// cacheCollectionName returns the deterministic collection name used for the
+// materialized view of an item.
+func cacheCollectionName(viewName string, itemKey string) string {
+	sum := sha1.Sum([]byte(viewName + "\x00" + itemKey))
+	return cachePrefix + hex.EncodeToString(sum[:])
+}
+
+// getCachedView retrieves the metadata for a materialized view collection. A
+// nil value is returned if the view has not been materialized.
+func getCachedView(context interface{}, mgoDB *db.DB, col string) (*cachedView, error) {
+	var cv cachedView
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"collection": col}
+		log.Dev(context, "getCachedView", "MGO : db.%s.findOne(%s)", c.Name, mongo.Query(q))
+		return c.Find(q).One(&cv)
+	}
+
+	if err := mgoDB.ExecuteMGO(context, CacheCollection, f); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &cv, nil
+}
+
+// refreshView rebuilds a materialized view collection. Concurrent refreshes of
+// the same collection wait on the one already in progress.
+func refreshView(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, viewName string, itemKey string, col string) error {
+	flights.Lock()
+	if f, exists := flights.m[col]; exists {
+		flights.Unlock()
+		f.wg.Wait()
+		return f.err
+	}
+
+	f := new(flight)
+	f.wg.Add(1)
+	flights.m[col] = f
+	flights.Unlock()
+
+	f.err = buildView(context, mgoDB, graphDB, viewName, itemKey, col)
+	f.wg.Done()
+
+	flights.Lock()
+	delete(flights.m, col)
+	flights.Unlock()
+
+	return f.err
+}
+
+// dropCachedViews drops the materialized collections matching the query along
+// with their metadata. A collection refreshed since it was found is kept. The
+// number of collections dropped is returned.
+func dropCachedViews(context interface{}, mgoDB *db.DB, q bson.M) (int, error) {
+	var cvs []cachedView
+	f := func(c *mgo.Collection) error {
+		log.Dev(context, "dropCachedViews", "MGO : db.%s.find(%s)", c.Name, mongo.Query(q))
+		return c.Find(q).Select(bson.M{"collection": 1, "refreshed_at": 1}).All(&cvs)
+	}
+
+	if err := mgoDB.ExecuteMGO(context, CacheCollection, f); err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, cv := range cvs {
+		f := func(c *mgo.Collection) error {
+			q := bson.M{"collection": cv.Collection, "refreshed_at": cv.RefreshedAt}
+			log.Dev(context, "dropCachedViews", "MGO : db.%s.remove(%s)", c.Name, mongo.Query(q))
+			return c.Remove(q)
+		}
+
+		if err := mgoDB.ExecuteMGO(context, CacheCollection, f); err != nil {
+			if err == mgo.ErrNotFound {
+				continue
+			}
+			return n, err
+		}
+
+		if err := dropCollection(context, mgoDB, cv.Collection); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// dropCollection drops the collection if it exists.
+func dropCollection(context interface{}, mgoDB *db.DB, col string) error {
+	f := func(c *mgo.Collection) error {
+		log.Dev(context, "dropCollection", "MGO : db.%s.drop()", c.Name)
+		if err := c.DropCollection(); err != nil && err.Error() != "ns not found" {
+			return err
+		}
+		return nil
+	}
+
+	return mgoDB.ExecuteMGO(context, col, f)
+}
+
+// expireBackground drops the unused materialized collections without blocking
+// the caller, at most once every expiryInterval.
+func expireBackground(context interface{}, mgoDB *db.DB) {
+	expiry.Lock()
+	due := time.Since(expiry.last) >= expiryInterval
+	if due {
+		expiry.last = time.Now()
+	}
+	expiry.Unlock()
+
+	if !due {
+		return
+	}
+
+	bgDB, err := mgoDB.Copy(context)
+	if err != nil {
+		log.Error(context, "expireBackground", err, "Copying sessions")
+		return
+	}
+
+	go func() {
+		defer bgDB.CloseMGO(context)
+		defer bgDB.CloseCayley(context)
+
+		if err := ExpireViews(context, bgDB); err != nil {
+			log.Error(context, "expireBackground", err, "Completed")
+		}
+	}()
+}
+
+// refreshBackground refreshes a materialized view collection without blocking
+// the caller. The refresh uses its own sessions since the caller's sessions
+// are closed when its request completes.
+func refreshBackground(context interface{}, mgoDB *db.DB, viewName string, itemKey string, col string) {
+
+	// If a refresh is already in progress there is nothing to do.
+	flights.Lock()
+	_, exists := flights.m[col]
+	flights.Unlock()
+
+	if exists {
+		return
+	}
+
+	bgDB, err := mgoDB.Copy(context)
+	if err != nil {
+		log.Error(context, "refreshBackground", err, "Copying sessions")
+		return
+	}
+
+	go func() {
+		defer bgDB.CloseMGO(context)
+		defer bgDB.CloseCayley(context)
+
+		graphDB, err := bgDB.GraphHandle(context)
+		if err != nil {
+			log.Error(context, "refreshBackground", err, "Completed")
+			return
+		}
+
+		if err := refreshView(context, bgDB, graphDB, viewName, itemKey, col); err != nil {
+			log.Error(context, "refreshBackground", err, "Completed")
+			return
+		}
+
+		log.Dev(context, "refreshBackground", "Completed : Collection[%s]", col)
+	}()
+}
+
+// buildView executes the view into its materialized view collection and
+// records the items it contains. The view is left stale if it was invalidated
+// while it was built.
+func buildView(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, viewName string, itemKey string, col string) error {
+	log.Dev(context, "buildView", "Started : Collection[%s]", col)
+
+	// Keep the version the refresh starts from.
+	var version int
+	cur, err := getCachedView(context, mgoDB, col)
+	if err != nil {
+		log.Error(context, "buildView", err, "Completed")
+		return err
+	}
+	if cur != nil {
+		version = cur.Version
+	}
+
+	_, ids, err := swapView(context, mgoDB, graphDB, viewName, itemKey, col)
+	if err != nil {
+		log.Error(context, "buildView", err, "Completed")
+		return err
+	}
+
+	// The root item is tracked with the view items so changes to it
+	// invalidate the view as well.
+	set := bson.M{
+		"view_name":    viewName,
+		"item_key":     itemKey,
+		"item_ids":     append(ids, itemKey),
+		"refreshed_at": time.Now(),
+	}
+
+	f := func(c *mgo.Collection) error {
+		if err := c.EnsureIndexKey("item_ids"); err != nil {
+			return err
+		}
+
+		q := bson.M{"collection": col}
+		u := bson.M{"$set": set, "$setOnInsert": bson.M{"stale": true, "version": 0}}
+		log.Dev(context, "buildView", "MGO : db.%s.upsert(%s, %s)", c.Name, mongo.Query(q), mongo.Query(u))
+		if _, err := c.Upsert(q, u); err != nil {
+			return err
+		}
+
+		// Metadata saved before versions were kept has none.
+		q["version"] = version
+		if version == 0 {
+			q["version"] = bson.M{"$in": []interface{}{0, nil}}
+		}
+
+		u = bson.M{"$set": bson.M{"stale": false}}
+		log.Dev(context, "buildView", "MGO : db.%s.update(%s, %s)", c.Name, mongo.Query(q), mongo.Query(u))
+		if err := c.Update(q, u); err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+		return nil
+	}
+
+	if err := mgoDB.ExecuteMGO(context, CacheCollection, f); err != nil {
+		log.Error(context, "buildView", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "buildView", "Completed : Items[%d]", len(ids))
+	return nil
+}
+
+// swapView executes the view into a temporary collection and swaps it in
+// place of the collection, so readers never see a partially written view.
+// The view and the IDs of the items it contains are returned.
+func swapView(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, viewName string, itemKey string, col string) (*view.View, []string, error) {
+	viewParams := ViewParams{
+		ViewName:          viewName,
+		ItemKey:           itemKey,
+		ResultsCollection: col + "_" + uuid.New(),
+	}
+
+	v, ids, embeds, _, err := resolveView(context, mgoDB, graphDB, &viewParams)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// An empty view has no collection to swap in, so just drop the old one.
+	if len(ids) == 0 {
+		if err := dropCollection(context, mgoDB, col); err != nil {
+			return nil, nil, err
+		}
+
+		return v, ids, nil
+	}
+
+	_, err = viewSave(context, mgoDB, v, &viewParams, ids, embeds, nil)
+	if err == nil {
+		err = mgoDB.RenameCollectionMGO(context, viewParams.ResultsCollection, col)
+	}
+
+	// Don't leave the temporary collection behind when it wasn't swapped in.
+	if err != nil {
+		if derr := dropCollection(context, mgoDB, viewParams.ResultsCollection); derr != nil {
+			log.Error(context, "swapView", derr, "Dropping %s", viewParams.ResultsCollection)
+		}
+		return nil, nil, err
+	}
+
+	return v, ids, nil
+}
@@ -0,0 +1,141 @@
+package wire_test
+
+import (
+	"testing"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/wire"
+)
+
+// TestMaterializeView tests the caching and invalidation of materialized views.
+func TestMaterializeView(t *testing.T) {
+	db, store := setup(t)
+	defer teardown(t, db, store)
+
+	viewName := wirePrefix + "thread"
+	itemKey := wirePrefix + "c1b2bbfe-af9f-4903-8777-bd47c4d5b20a"
+
+	t.Log("Given the need to materialize a view for use by queries.")
+	{
+		t.Logf("\tWhen using the view named %s", viewName)
+		{
+			col, err := wire.Materialize(tests.Context, db, store, viewName, itemKey)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to materialize the view : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to materialize the view.", tests.Success)
+
+			defer func() {
+				f := func(c *mgo.Collection) error {
+					return c.DropCollection()
+				}
+				db.ExecuteMGO(tests.Context, col, f)
+
+				f = func(c *mgo.Collection) error {
+					_, err := c.RemoveAll(bson.M{"collection": col})
+					return err
+				}
+				db.ExecuteMGO(tests.Context, wire.CacheCollection, f)
+			}()
+
+			var count int
+			f := func(c *mgo.Collection) error {
+				var err error
+				count, err = c.Count()
+				return err
+			}
+
+			if err := db.ExecuteMGO(tests.Context, col, f); err != nil {
+				t.Fatalf("\t%s\tShould be able to count the view items : %s", tests.Failed, err)
+			}
+
+			if count != 5 {
+				t.Fatalf("\t%s\tShould have 5 items in the view collection : %d", tests.Failed, count)
+			}
+			t.Logf("\t%s\tShould have 5 items in the view collection.", tests.Success)
+
+			col2, err := wire.Materialize(tests.Context, db, store, viewName, itemKey)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to materialize the view again : %s", tests.Failed, err)
+			}
+
+			if col2 != col {
+				t.Fatalf("\t%s\tShould reuse the same collection : %s != %s", tests.Failed, col2, col)
+			}
+			t.Logf("\t%s\tShould reuse the same collection.", tests.Success)
+
+			if err := wire.InvalidateViews(tests.Context, db, []string{wirePrefix + "d1dfa366-d2f7-4a4a-a64f-af89d4c97d82"}); err != nil {
+				t.Fatalf("\t%s\tShould be able to invalidate the view : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to invalidate the view.", tests.Success)
+
+			var meta bson.M
+			f = func(c *mgo.Collection) error {
+				return c.Find(bson.M{"collection": col}).One(&meta)
+			}
+
+			if err := db.ExecuteMGO(tests.Context, wire.CacheCollection, f); err != nil {
+				t.Fatalf("\t%s\tShould be able to get the view metadata : %s", tests.Failed, err)
+			}
+
+			if stale, _ := meta["stale"].(bool); !stale {
+				t.Fatalf("\t%s\tShould have marked the view as stale.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould have marked the view as stale.", tests.Success)
+
+			if _, err := wire.Materialize(tests.Context, db, store, viewName, itemKey); err != nil {
+				t.Fatalf("\t%s\tShould be able to refresh the view : %s", tests.Failed, err)
+			}
+
+			if err := db.ExecuteMGO(tests.Context, wire.CacheCollection, f); err != nil {
+				t.Fatalf("\t%s\tShould be able to get the view metadata : %s", tests.Failed, err)
+			}
+
+			if stale, _ := meta["stale"].(bool); stale {
+				t.Fatalf("\t%s\tShould have refreshed the view.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould have refreshed the view.", tests.Success)
+
+			if err := wire.InvalidateView(tests.Context, db, viewName); err != nil {
+				t.Fatalf("\t%s\tShould be able to invalidate the view by name : %s", tests.Failed, err)
+			}
+
+			if err := db.ExecuteMGO(tests.Context, wire.CacheCollection, f); err != nil {
+				t.Fatalf("\t%s\tShould be able to get the view metadata : %s", tests.Failed, err)
+			}
+
+			if stale, _ := meta["stale"].(bool); !stale {
+				t.Fatalf("\t%s\tShould have marked the view as stale by name.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould have marked the view as stale by name.", tests.Success)
+
+			if err := wire.DropViews(tests.Context, db, viewName); err != nil {
+				t.Fatalf("\t%s\tShould be able to drop the view collections : %s", tests.Failed, err)
+			}
+
+			if err := db.ExecuteMGO(tests.Context, wire.CacheCollection, f); err != mgo.ErrNotFound {
+				t.Fatalf("\t%s\tShould have removed the view metadata : %v", tests.Failed, err)
+			}
+
+			var names []string
+			f = func(c *mgo.Collection) error {
+				var err error
+				names, err = c.Database.CollectionNames()
+				return err
+			}
+
+			if err := db.ExecuteMGO(tests.Context, col, f); err != nil {
+				t.Fatalf("\t%s\tShould be able to list the collections : %s", tests.Failed, err)
+			}
+			for _, name := range names {
+				if name == col {
+					t.Fatalf("\t%s\tShould have dropped the view collection.", tests.Failed)
+				}
+			}
+			t.Logf("\t%s\tShould have dropped the view collection.", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package wire
+
+import (
+	"time"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/sponge/item"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// DefaultBatchSize is the number of quads applied in each transaction when
+// the graph is rebuilt.
+const DefaultBatchSize = 1000
+
+// CheckReport describes the differences between the graph and the quads
+// expected from the items and their patterns.
+type CheckReport struct {
+	Items    int    `json:"items"`    // Number of items checked.
+	Expected int    `json:"expected"` // Number of quads expected from the items.
+	Quads    int    `json:"quads"`    // Number of quads in the graph.
+	Missing  []Edge `json:"missing"`  // Expected quads not in the graph.
+	Extra    []Edge `json:"extra"`    // Unexpected quads between existing items.
+	Dangling []Edge `json:"dangling"` // Unexpected quads referencing items that don't exist.
+
+	// Number of quads added or removed by a rebuild. Quads added or removed
+	// concurrently since the check are not counted.
+	Reconciled int `json:"reconciled,omitempty"`
+}
+
+// Consistent reports if the graph matches the items.
+func (r *CheckReport) Consistent() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Dangling) == 0
+}
+
+// Progress is called as the graph is rebuilt with the number of quads
+// reconciled so far and the number to reconcile.
+type Progress func(done, total int)
+
+// CheckGraph recomputes the quads expected from every item and its current
+// pattern and compares them with the quads in the graph. The quads derived
+// by rules are not checked.
+func CheckGraph(context interface{}, db *db.DB, store *cayley.Handle) (*CheckReport, error) {
+	log.Dev(context, "CheckGraph", "Started")
+
+	r, _, err := checkGraph(context, db, store)
+	if err != nil {
+		log.Error(context, "CheckGraph", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "CheckGraph", "Completed : Missing[%d] Extra[%d] Dangling[%d]", len(r.Missing), len(r.Extra), len(r.Dangling))
+	return r, nil
+}
+
+// checkGraph compares the graph with the quads expected from the items, and
+// returns the metadata of the expected quads. A quad is created at the time
+// held by the field named by its inference, or else when its item was.
+func checkGraph(context interface{}, db *db.DB, store *cayley.Handle) (*CheckReport, []RelMeta, error) {
+	r := CheckReport{
+		Missing:  []Edge{},
+		Extra:    []Edge{},
+		Dangling: []Edge{},
+	}
+
+	// Compute the expected quads from the items.
+	ids := make(map[string]bool)
+	expected := make(map[Edge]bool)
+	var metas []RelMeta
+	now := time.Now().UTC()
+
+	f := func(c *mgo.Collection) error {
+		var itm item.Item
+		iter := c.Find(nil).Iter()
+		for iter.Next(&itm) {
+			ids[itm.ID] = true
+			r.Items++
+
+			itmMap := map[string]interface{}{
+				"item_id": itm.ID,
+				"type":    itm.Type,
+				"version": itm.Version,
+				"data":    itm.Data,
+			}
+
+			quadParams, err := inferRelationships(context, db, itmMap)
+			if err != nil {
+				iter.Close()
+				return err
+			}
+
+			quadParams, _, err = checkTypes(context, db, quadParams)
+			if err != nil {
+				iter.Close()
+				return err
+			}
+
+			for _, qp := range quadParams {
+				e := Edge{Subject: qp.Subject, Predicate: qp.Predicate, Object: qp.Object}
+				if expected[e] {
+					continue
+				}
+				expected[e] = true
+
+				created := qp.Time
+				if created.IsZero() {
+					created = itm.CreatedAt.UTC()
+				}
+				if created.IsZero() {
+					created = now
+				}
+
+				metas = append(metas, RelMeta{
+					Subject:   qp.Subject,
+					Predicate: qp.Predicate,
+					Object:    qp.Object,
+					Label:     qp.Label,
+					Time:      created,
+					ItemID:    itm.ID,
+					Version:   itm.Version,
+				})
+			}
+
+			itm = item.Item{}
+		}
+		return iter.Close()
+	}
+
+	if err := db.ExecuteMGO(context, item.Collection, f); err != nil {
+		return nil, nil, err
+	}
+	r.Expected = len(expected)
+
+	// Compare the quads in the graph.
+	found := make(map[Edge]bool)
+	it := store.QuadsAllIterator()
+	for it.Next() {
+		q := store.Quad(it.Result())
+
+		// Derived quads are maintained by DeriveGraph.
+		if isDerived(q) {
+			continue
+		}
+
+		e := Edge{
+			Subject:   nativeString(q.Subject),
+			Predicate: nativeString(q.Predicate),
+			Object:    nativeString(q.Object),
+		}
+		found[e] = true
+		r.Quads++
+
+		if expected[e] {
+			continue
+		}
+
+		if !ids[e.Subject] || !ids[e.Object] {
+			r.Dangling = append(r.Dangling, e)
+			continue
+		}
+		r.Extra = append(r.Extra, e)
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for e := range expected {
+		if !found[e] {
+			r.Missing = append(r.Missing, e)
+		}
+	}
+
+	return &r, metas, nil
+}
+
+// RebuildGraph reconciles the graph with the items by adding the missing
+// quads and removing the extra and dangling ones, batchSize quads at a time.
+// The metadata of the expected quads that have none, like the quads added
+// before the metadata was recorded, is backfilled so views executed within
+// a time window follow them. The report of the check done before the rebuild
+// is returned.
+func RebuildGraph(context interface{}, db *db.DB, store *cayley.Handle, batchSize int, progress Progress) (*CheckReport, error) {
+	log.Dev(context, "RebuildGraph", "Started : BatchSize[%d]", batchSize)
+
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	r, metas, err := checkGraph(context, db, store)
+	if err != nil {
+		log.Error(context, "RebuildGraph", err, "Completed")
+		return nil, err
+	}
+
+	total := len(r.Missing) + len(r.Extra) + len(r.Dangling)
+
+	var done int
+	tx := cayley.NewTransaction()
+	var queued int
+
+	// apply applies the queued changes to the graph. A quad added or removed
+	// since the check is skipped without rejecting the rest of the batch.
+	apply := func() error {
+		if queued == 0 {
+			return nil
+		}
+
+		applied, err := applyEach(store, tx)
+		r.Reconciled += applied
+		if err != nil {
+			return err
+		}
+
+		done += queued
+		if progress != nil {
+			progress(done, total)
+		}
+
+		tx = cayley.NewTransaction()
+		queued = 0
+		return nil
+	}
+
+	for _, e := range r.Missing {
+		tx.AddQuad(quad.Make(e.Subject, e.Predicate, e.Object, ""))
+		if queued++; queued == batchSize {
+			if err := apply(); err != nil {
+				log.Error(context, "RebuildGraph", err, "Completed")
+				return nil, err
+			}
+		}
+	}
+
+	for _, edges := range [][]Edge{r.Extra, r.Dangling} {
+		for _, e := range edges {
+			tx.RemoveQuad(quad.Make(e.Subject, e.Predicate, e.Object, ""))
+			if queued++; queued == batchSize {
+				if err := apply(); err != nil {
+					log.Error(context, "RebuildGraph", err, "Completed")
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if err := apply(); err != nil {
+		log.Error(context, "RebuildGraph", err, "Completed")
+		return nil, err
+	}
+
+	// Reconcile the metadata of the relationships.
+	removed := append(edgeParams(r.Extra), edgeParams(r.Dangling)...)
+
+	if err := backfillMeta(context, db, metas); err != nil {
+		log.Error(context, "RebuildGraph", err, "Completed")
+		return nil, err
+	}
+
+	if err := removeMeta(context, db, removed); err != nil {
+		log.Error(context, "RebuildGraph", err, "Completed")
+		return nil, err
+	}
+
+	// Invalidate any materialized views touched by the reconciled quads.
+	var touched []string
+	for _, edges := range [][]Edge{r.Missing, r.Extra, r.Dangling} {
+		for _, e := range edges {
+			touched = append(touched, e.Subject, e.Object)
+		}
+	}
+
+	if err := InvalidateViews(context, db, touched); err != nil {
+		log.Error(context, "RebuildGraph", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "RebuildGraph", "Completed : Reconciled[%d]", r.Reconciled)
+	return r, nil
+}
+
+// edgeParams converts relationships into quad parameters.
+func edgeParams(edges []Edge) []QuadParam {
+	qps := make([]QuadParam, 0, len(edges))
+	for _, e := range edges {
+		qps = append(qps, QuadParam{Subject: e.Subject, Predicate: e.Predicate, Object: e.Object})
+	}
+	return qps
+}
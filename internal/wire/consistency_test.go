@@ -0,0 +1,77 @@
+package wire_test
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/coralproject/shelf/internal/wire"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// TestRebuildGraph tests checking and rebuilding the graph from the items.
+func TestRebuildGraph(t *testing.T) {
+	db, store, _ := setupGraph(t)
+	defer tests.DisplayLog()
+
+	// A relationship of an item that does not exist.
+	missingID := wirePrefix + "0d7b9c3e-6f4a-4c1e-9a55-3f1b2d8e7c60"
+	dangling := quad.Make(missingID, wirePrefix+"authored", wirePrefix+"d1dfa366-d2f7-4a4a-a64f-af89d4c97d82", "")
+	if err := store.AddQuad(dangling); err != nil {
+		t.Fatalf("\t%s\tShould be able to add a dangling quad : %s", tests.Failed, err)
+	}
+
+	t.Log("Given the need to reconcile the graph with the items.")
+	{
+		t.Log("\tWhen starting from a graph with only a dangling quad")
+		{
+			r, err := wire.CheckGraph(tests.Context, db, store)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to check the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to check the graph.", tests.Success)
+
+			if len(r.Dangling) != 1 || r.Dangling[0].Subject != missingID {
+				t.Fatalf("\t%s\tShould report the dangling quad : %+v", tests.Failed, r.Dangling)
+			}
+			t.Logf("\t%s\tShould report the dangling quad.", tests.Success)
+
+			if len(r.Missing) == 0 {
+				t.Fatalf("\t%s\tShould report the missing quads", tests.Failed)
+			}
+			t.Logf("\t%s\tShould report the missing quads.", tests.Success)
+			missing := r.Missing[0]
+
+			var calls int
+			if _, err := wire.RebuildGraph(tests.Context, db, store, 2, func(done, total int) { calls++ }); err != nil {
+				t.Fatalf("\t%s\tShould be able to rebuild the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to rebuild the graph.", tests.Success)
+
+			if calls < 2 {
+				t.Fatalf("\t%s\tShould report the progress in batches : %d", tests.Failed, calls)
+			}
+			t.Logf("\t%s\tShould report the progress in batches.", tests.Success)
+
+			r, err = wire.CheckGraph(tests.Context, db, store)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to check the graph again : %s", tests.Failed, err)
+			}
+
+			if !r.Consistent() {
+				t.Fatalf("\t%s\tShould have a consistent graph : %+v", tests.Failed, r)
+			}
+			t.Logf("\t%s\tShould have a consistent graph.", tests.Success)
+
+			var m wire.RelMeta
+			f := func(c *mgo.Collection) error {
+				return c.Find(bson.M{"subject": missing.Subject, "predicate": missing.Predicate, "object": missing.Object}).One(&m)
+			}
+			if err := db.ExecuteMGO(tests.Context, wire.MetaCollection, f); err != nil || m.ItemID == "" || m.Time.IsZero() {
+				t.Fatalf("\t%s\tShould backfill the metadata of the added quads : %+v : %v", tests.Failed, m, err)
+			}
+			t.Logf("\t%s\tShould backfill the metadata of the added quads.", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package wire
+
+import (
+	"github.com/ardanlabs/kit/log"
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/wire/relationship"
+)
+
+// Dependent is a relationship another item holds to an item, along with the
+// policy applied to it when the item is removed.
+type Dependent struct {
+	Edge
+	ItemID   string `json:"item_id"`   // Item holding the relationship.
+	OnDelete string `json:"on_delete"` // Policy of the relationship.
+}
+
+// Dependents returns the relationships in the graph to or from the item
+// that were not inferred from the item itself, so other items hold them.
+// Relationships derived by rules are not included.
+func Dependents(context interface{}, db *db.DB, store *cayley.Handle, item map[string]interface{}) ([]Dependent, error) {
+	log.Dev(context, "Dependents", "Started : %v", item)
+
+	itemID, _ := item["item_id"].(string)
+
+	// The relationships inferred from the item are its own.
+	quadParams, err := itemQuads(context, db, item)
+	if err != nil {
+		log.Error(context, "Dependents", err, "Completed")
+		return nil, err
+	}
+
+	own := make(map[Edge]bool)
+	for _, qp := range quadParams {
+		own[Edge{Subject: qp.Subject, Predicate: qp.Predicate, Object: qp.Object}] = true
+	}
+
+	deps := []Dependent{}
+	policies := make(map[string]string)
+
+	v := store.ValueOf(quad.String(itemID))
+	if v == nil {
+		log.Dev(context, "Dependents", "Completed : Dependents[0]")
+		return deps, nil
+	}
+
+	for _, dir := range []quad.Direction{quad.Subject, quad.Object} {
+		it := store.QuadIterator(dir, v)
+		for it.Next() {
+			q := store.Quad(it.Result())
+			if isDerived(q) {
+				continue
+			}
+
+			e := Edge{
+				Subject:   nativeString(q.Subject),
+				Predicate: nativeString(q.Predicate),
+				Object:    nativeString(q.Object),
+			}
+			if own[e] {
+				continue
+			}
+
+			policy, ok := policies[e.Predicate]
+			if !ok {
+				if policy, err = onDelete(context, db, e.Predicate); err != nil {
+					it.Close()
+					log.Error(context, "Dependents", err, "Completed")
+					return nil, err
+				}
+				policies[e.Predicate] = policy
+			}
+
+			dep := Dependent{
+				Edge:     e,
+				ItemID:   e.Subject,
+				OnDelete: policy,
+			}
+			if dep.ItemID == itemID {
+				dep.ItemID = e.Object
+			}
+
+			deps = append(deps, dep)
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			log.Error(context, "Dependents", err, "Completed")
+			return nil, err
+		}
+	}
+
+	log.Dev(context, "Dependents", "Completed : Dependents[%d]", len(deps))
+	return deps, nil
+}
+
+// DetachFromGraph removes the relationships from the graph without removing
+// the items holding them. The removed relationships are reported.
+func DetachFromGraph(context interface{}, db *db.DB, store *cayley.Handle, edges []Edge) (*InferenceReport, error) {
+	log.Dev(context, "DetachFromGraph", "Started : Edges[%d]", len(edges))
+
+	report, err := removeQuads(context, db, store, "", edgeParams(edges))
+	if err != nil {
+		log.Error(context, "DetachFromGraph", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "DetachFromGraph", "Completed : Removed[%d]", len(report.Removed))
+	return report, nil
+}
+
+// onDelete returns the on delete policy of a predicate, keeping the
+// relationships of undeclared predicates.
+func onDelete(context interface{}, db *db.DB, predicate string) (string, error) {
+	rel, err := relationship.GetByPredicate(context, db, predicate)
+	if err != nil {
+		if err == relationship.ErrNotFound {
+			return relationship.OnDeleteKeep, nil
+		}
+		return "", err
+	}
+
+	if rel.OnDelete == "" {
+		return relationship.OnDeleteKeep, nil
+	}
+
+	return rel.OnDelete, nil
+}
@@ -0,0 +1,311 @@
+package wire
+
+import (
+	"strings"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/wire/rule"
+)
+
+// derivedPrefix prefixes the name of the rule used as the label of the
+// quads it derived. Quads inferred from items have no label.
+const derivedPrefix = "rule:"
+
+// DeriveReport describes the changes made to the relationships derived by
+// the rules.
+type DeriveReport struct {
+	Rules   int    `json:"rules"`
+	Derived int    `json:"derived"`
+	Added   []Edge `json:"added"`
+	Removed []Edge `json:"removed"`
+}
+
+// derivedLabel returns the label of the quads derived by a rule.
+func derivedLabel(name string) string {
+	return derivedPrefix + name
+}
+
+// quadLabel returns the label of a quad.
+func quadLabel(q quad.Quad) string {
+	if q.Label == nil {
+		return ""
+	}
+	return nativeString(q.Label)
+}
+
+// isDerived reports if a quad was derived by a rule.
+func isDerived(q quad.Quad) bool {
+	return strings.HasPrefix(quadLabel(q), derivedPrefix)
+}
+
+// getRules returns the rules, none if there aren't any.
+func getRules(context interface{}, db *db.DB) ([]rule.Rule, error) {
+	rules, err := rule.GetAll(context, db)
+	if err != nil && err != rule.ErrNotFound {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// DeriveGraph recomputes the relationships derived by every rule, adding the
+// missing ones and removing the ones no longer derived, including those of
+// rules that were deleted.
+func DeriveGraph(context interface{}, db *db.DB, store *cayley.Handle) (*DeriveReport, error) {
+	log.Dev(context, "DeriveGraph", "Started")
+
+	rules, err := getRules(context, db)
+	if err != nil {
+		log.Error(context, "DeriveGraph", err, "Completed")
+		return nil, err
+	}
+
+	// Compute the relationships derived from the current graph.
+	expected := make(map[string]map[Edge]bool)
+	for _, r := range rules {
+		edges := make(map[Edge]bool)
+		for start := range chainStarts(store, r.Chain[0]) {
+			for _, e := range derive(store, &r, start) {
+				edges[e] = true
+			}
+		}
+		expected[derivedLabel(r.Name)] = edges
+	}
+
+	// Remove the derived quads no longer expected.
+	r := DeriveReport{
+		Rules:   len(rules),
+		Added:   []Edge{},
+		Removed: []Edge{},
+	}
+
+	tx := cayley.NewTransaction()
+	found := make(map[string]map[Edge]bool)
+
+	it := store.QuadsAllIterator()
+	for it.Next() {
+		q := store.Quad(it.Result())
+		if !isDerived(q) {
+			continue
+		}
+
+		label := quadLabel(q)
+		e := Edge{
+			Subject:   nativeString(q.Subject),
+			Predicate: nativeString(q.Predicate),
+			Object:    nativeString(q.Object),
+		}
+
+		if expected[label][e] {
+			if found[label] == nil {
+				found[label] = make(map[Edge]bool)
+			}
+			found[label][e] = true
+			continue
+		}
+
+		tx.RemoveQuad(quad.Make(e.Subject, e.Predicate, e.Object, label))
+		r.Removed = append(r.Removed, e)
+	}
+	err = it.Err()
+	it.Close()
+	if err != nil {
+		log.Error(context, "DeriveGraph", err, "Completed")
+		return nil, err
+	}
+
+	// Add the expected quads not found.
+	for label, edges := range expected {
+		r.Derived += len(edges)
+		for e := range edges {
+			if found[label][e] {
+				continue
+			}
+
+			tx.AddQuad(quad.Make(e.Subject, e.Predicate, e.Object, label))
+			r.Added = append(r.Added, e)
+		}
+	}
+
+	if err := applyDerived(store, tx); err != nil {
+		log.Error(context, "DeriveGraph", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "DeriveGraph", "Completed : Added[%d] Removed[%d]", len(r.Added), len(r.Removed))
+	return &r, nil
+}
+
+// derivationStarts adds to starts, for each rule, the items whose derived
+// relationships may depend on a relationship of one of the items.
+func derivationStarts(starts map[string]map[string]bool, store *cayley.Handle, rules []rule.Rule, ids []string) {
+	for _, r := range rules {
+		s := starts[r.Name]
+		if s == nil {
+			s = make(map[string]bool)
+			starts[r.Name] = s
+		}
+
+		// An item may be at any position of the chain, so walk back to the
+		// start of the chain from every position.
+		for _, id := range ids {
+			for pos := 0; pos <= len(r.Chain); pos++ {
+				nodes := map[string]bool{id: true}
+				for i := pos - 1; i >= 0 && len(nodes) > 0; i-- {
+					nodes = followStep(store, nodes, r.Chain[i], true)
+				}
+				for n := range nodes {
+					s[n] = true
+				}
+			}
+		}
+	}
+}
+
+// updateDerived recomputes the relationships derived by the rules from the
+// given items, returning the ones added and removed.
+func updateDerived(store *cayley.Handle, rules []rule.Rule, starts map[string]map[string]bool) ([]Edge, []Edge, error) {
+	var added, removed []Edge
+	tx := cayley.NewTransaction()
+
+	for _, r := range rules {
+		label := derivedLabel(r.Name)
+
+		for start := range starts[r.Name] {
+			expected := make(map[Edge]bool)
+			for _, e := range derive(store, &r, start) {
+				expected[e] = true
+			}
+
+			// Compare with the relationships currently derived.
+			current := make(map[Edge]bool)
+			if v := store.ValueOf(quad.String(start)); v != nil {
+				it := store.QuadIterator(quad.Subject, v)
+				for it.Next() {
+					q := store.Quad(it.Result())
+					if quadLabel(q) != label {
+						continue
+					}
+					current[Edge{Subject: start, Predicate: nativeString(q.Predicate), Object: nativeString(q.Object)}] = true
+				}
+				it.Close()
+			}
+
+			for e := range current {
+				if !expected[e] {
+					tx.RemoveQuad(quad.Make(e.Subject, e.Predicate, e.Object, label))
+					removed = append(removed, e)
+				}
+			}
+
+			for e := range expected {
+				if !current[e] {
+					tx.AddQuad(quad.Make(e.Subject, e.Predicate, e.Object, label))
+					added = append(added, e)
+				}
+			}
+		}
+	}
+
+	if err := applyDerived(store, tx); err != nil {
+		return nil, nil, err
+	}
+
+	return added, removed, nil
+}
+
+// applyDerived applies the changes to the derived quads. A quad already
+// added or removed by a concurrent derivation is skipped without rejecting
+// the other changes.
+func applyDerived(store *cayley.Handle, tx *graph.Transaction) error {
+	if len(tx.Deltas) == 0 {
+		return nil
+	}
+
+	_, err := applyEach(store, tx)
+	return err
+}
+
+// derive returns the relationships a rule derives from an item.
+func derive(store *cayley.Handle, r *rule.Rule, start string) []Edge {
+	nodes := map[string]bool{start: true}
+	for _, s := range r.Chain {
+		if nodes = followStep(store, nodes, s, false); len(nodes) == 0 {
+			return nil
+		}
+	}
+
+	var edges []Edge
+	for n := range nodes {
+		if n == start {
+			continue
+		}
+		edges = append(edges, Edge{Subject: start, Predicate: r.Predicate, Object: n})
+	}
+	return edges
+}
+
+// chainStarts returns the items a chain can start from.
+func chainStarts(store *cayley.Handle, s rule.Step) map[string]bool {
+	starts := make(map[string]bool)
+
+	v := store.ValueOf(quad.String(s.Predicate))
+	if v == nil {
+		return starts
+	}
+
+	it := store.QuadIterator(quad.Predicate, v)
+	defer it.Close()
+	for it.Next() {
+		q := store.Quad(it.Result())
+		if quadLabel(q) != "" {
+			continue
+		}
+
+		if s.Direction == inString {
+			starts[nativeString(q.Object)] = true
+			continue
+		}
+		starts[nativeString(q.Subject)] = true
+	}
+
+	return starts
+}
+
+// followStep returns the items reached from the nodes by following a step
+// of a chain, or by following it backwards. Only the relationships inferred
+// from items are followed.
+func followStep(store *cayley.Handle, nodes map[string]bool, s rule.Step, backwards bool) map[string]bool {
+	out := s.Direction != inString
+	if backwards {
+		out = !out
+	}
+
+	from, to := quad.Subject, quad.Object
+	if !out {
+		from, to = quad.Object, quad.Subject
+	}
+
+	next := make(map[string]bool)
+	for id := range nodes {
+		v := store.ValueOf(quad.String(id))
+		if v == nil {
+			continue
+		}
+
+		it := store.QuadIterator(from, v)
+		for it.Next() {
+			q := store.Quad(it.Result())
+			if quadLabel(q) != "" || nativeString(q.Predicate) != s.Predicate {
+				continue
+			}
+			next[nativeString(q.Get(to))] = true
+		}
+		it.Close()
+	}
+
+	return next
+}
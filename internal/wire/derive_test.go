@@ -0,0 +1,84 @@
+package wire_test
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/coralproject/shelf/internal/wire/rule"
+)
+
+// TestDeriveGraph tests deriving relationships from the rules as items are
+// added to and removed from the graph.
+func TestDeriveGraph(t *testing.T) {
+	db, store, items := setupGraph(t)
+	defer tests.DisplayLog()
+
+	r := rule.Rule{
+		Name: "WTEST_participated",
+		Chain: []rule.Step{
+			{Predicate: "WTEST_authored", Direction: "out"},
+			{Predicate: "WTEST_on", Direction: "out"},
+		},
+		Predicate: "WTEST_participated_in",
+	}
+
+	if err := rule.Upsert(tests.Context, db, &r); err != nil {
+		t.Fatalf("\t%s\tShould be able to add the rule : %s", tests.Failed, err)
+	}
+	defer rule.Delete(tests.Context, db, r.Name)
+
+	derived := wire.Edge{
+		Subject:   "WTEST_80aa936a-f618-4234-a7be-df59a14cf8de",
+		Predicate: "WTEST_participated_in",
+		Object:    "WTEST_c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+	}
+
+	hasEdge := func(edges []wire.Edge) bool {
+		for _, e := range edges {
+			if e == derived {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Log("Given the need to derive relationships from rules.")
+	{
+		t.Log("\tWhen a comment links its author to an asset")
+		{
+			report, err := wire.AddToGraph(tests.Context, db, store, items[0])
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to add relationships to the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to add relationships to the graph.", tests.Success)
+
+			if !hasEdge(report.Added) {
+				t.Fatalf("\t%s\tShould derive the author participated in the asset : %+v", tests.Failed, report.Added)
+			}
+			t.Logf("\t%s\tShould derive the author participated in the asset.", tests.Success)
+
+			dr, err := wire.DeriveGraph(tests.Context, db, store)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to derive the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to derive the graph.", tests.Success)
+
+			if len(dr.Added) != 0 || len(dr.Removed) != 0 || dr.Derived != 1 {
+				t.Fatalf("\t%s\tShould find the derived relationship up to date : %+v", tests.Failed, dr)
+			}
+			t.Logf("\t%s\tShould find the derived relationship up to date.", tests.Success)
+
+			report, err = wire.RemoveFromGraph(tests.Context, db, store, items[0])
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to remove relationships from the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to remove relationships from the graph.", tests.Success)
+
+			if !hasEdge(report.Removed) {
+				t.Fatalf("\t%s\tShould remove the derived relationship : %+v", tests.Failed, report.Removed)
+			}
+			t.Logf("\t%s\tShould remove the derived relationship.", tests.Success)
+		}
+	}
+}
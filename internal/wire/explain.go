@@ -0,0 +1,268 @@
+package wire
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/wire/relationship"
+	"github.com/coralproject/shelf/internal/wire/view"
+)
+
+// rootEmbed is the item in which the items of a tagged segment are embedded
+// when no earlier segment of the path is tagged.
+const rootEmbed = "root"
+
+// SegmentExplain describes how a path segment is followed from an item key.
+type SegmentExplain struct {
+	Level     int      `json:"level"`
+	Predicate string   `json:"predicate"`
+	Direction string   `json:"direction"`
+	MinHops   int      `json:"min_hops"`
+	MaxHops   int      `json:"max_hops"`
+	Tags      []string `json:"tags,omitempty"`     // Tags of the items reached in the graph path.
+	Filtered  bool     `json:"filtered"`           // The items reached are checked against a filter.
+	Count     int      `json:"count"`              // Distinct items reached, before filters and windows.
+	EmbedAs   string   `json:"embed_as,omitempty"` // Field the items are embedded under.
+	EmbedIn   string   `json:"embed_in,omitempty"` // Tag of the items they are embedded in, or root.
+}
+
+// PathExplain describes how a path of a view is followed from an item key.
+type PathExplain struct {
+	Path       int              `json:"path"`
+	StrictPath bool             `json:"strict_path"`
+	Query      string           `json:"query"`
+	Segments   []SegmentExplain `json:"segments"`
+}
+
+// RootExplain describes how a view is executed from an item key.
+type RootExplain struct {
+	ItemKey  string             `json:"item_key"`
+	Items    int                `json:"items"`    // Items in the view, after filters and windows.
+	Iterator *graph.Description `json:"iterator"` // Optimized iterator of the graph path.
+	Paths    []PathExplain      `json:"paths"`
+}
+
+// ViewExplain describes how a view is executed without executing it.
+type ViewExplain struct {
+	ViewName   string        `json:"view_name"`
+	StartType  string        `json:"start_type"`
+	ReturnRoot bool          `json:"return_root"`
+	Warnings   []string      `json:"warnings"`
+	Roots      []RootExplain `json:"roots"`
+}
+
+// Explain describes how a view would be executed from its item keys: the
+// graph path built for each path of the view, the items reached and the tags
+// set by each segment, and how the tagged items are embedded in one another.
+// Problems that would make the view fail or come back empty, like unknown
+// predicates or a start type not matching the first relationship, are
+// reported as warnings. No results collection is written.
+func Explain(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, viewParams *ViewParams) (*ViewExplain, error) {
+	log.Dev(context, "Explain", "Started : Name[%s]", viewParams.ViewName)
+
+	v, err := view.GetByName(context, mgoDB, viewParams.ViewName)
+	if err != nil {
+		log.Error(context, "Explain", err, "Completed")
+		return nil, err
+	}
+
+	warnings, err := viewWarnings(context, mgoDB, graphDB, v)
+	if err != nil {
+		log.Error(context, "Explain", err, "Completed")
+		return nil, err
+	}
+
+	ve := ViewExplain{
+		ViewName:   v.Name,
+		StartType:  v.StartType,
+		ReturnRoot: v.ReturnRoot,
+		Warnings:   warnings,
+		Roots:      []RootExplain{},
+	}
+
+	win := viewParams.window()
+
+	for _, key := range viewParams.rootKeys() {
+		re := RootExplain{
+			ItemKey: key,
+			Paths:   explainPaths(v, key, win != nil, graphDB),
+		}
+
+		// Translate the view path as Execute does and walk it.
+		graphPath, err := viewPathToGraphPath(v, key, win != nil, graphDB)
+		if err != nil {
+			log.Error(context, "Explain", err, "Completed")
+			return nil, err
+		}
+
+		if graphPath != nil {
+			it := graphPath.BuildIterator()
+			it, _ = it.Optimize()
+			d := it.Describe()
+			it.Close()
+			re.Iterator = &d
+
+			ids, _, err := viewIDs(context, mgoDB, v, graphPath, key, win, graphDB)
+			if err != nil {
+				log.Error(context, "Explain", err, "Completed")
+				return nil, err
+			}
+			re.Items = len(ids)
+		}
+
+		ve.Roots = append(ve.Roots, re)
+	}
+
+	log.Dev(context, "Explain", "Completed : Roots[%d] Warnings[%d]", len(ve.Roots), len(ve.Warnings))
+	return &ve, nil
+}
+
+//==============================================================================
+
+// viewWarnings returns the problems found in the paths of a view: predicates
+// without a relationship, predicates missing from the graph and a start type
+// not matching the first relationship of a path.
+func viewWarnings(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, v *view.View) ([]string, error) {
+	warnings := []string{}
+
+	for _, predicate := range viewPredicates(v) {
+		if _, err := relationship.GetByPredicate(context, mgoDB, predicate); err != nil {
+			if err != relationship.ErrNotFound {
+				return nil, err
+			}
+			warnings = append(warnings, fmt.Sprintf("Unknown predicate %s", predicate))
+		}
+
+		if graphDB.ValueOf(quad.String(predicate)) == nil {
+			warnings = append(warnings, fmt.Sprintf("Predicate %s has no relationships in the graph", predicate))
+		}
+	}
+
+	// An unknown first predicate is already reported.
+	if err := validateStartType(context, mgoDB, v); err != nil && err != relationship.ErrNotFound {
+		warnings = append(warnings, err.Error())
+	}
+
+	return warnings, nil
+}
+
+// explainPaths describes the paths of a view followed from an item key,
+// counting the items reached by each segment.
+func explainPaths(v *view.View, key string, tagLevels bool, graphDB *cayley.Handle) []PathExplain {
+	var pes []PathExplain
+
+	for idx, pth := range v.Paths {
+		alias := strconv.Itoa(idx+1) + "_"
+		sort.Sort(pth.Segments)
+
+		pe := PathExplain{
+			Path:       idx + 1,
+			StrictPath: pth.StrictPath,
+		}
+
+		query := fmt.Sprintf("g.V(%q)", key)
+		graphPath := cayley.StartPath(graphDB, quad.String(key))
+		embedIn := rootEmbed
+
+		for _, segment := range pth.Segments {
+			min, max := segmentHops(segment)
+
+			se := SegmentExplain{
+				Level:     segment.Level,
+				Predicate: segment.Predicate,
+				Direction: segment.Direction,
+				MinHops:   min,
+				MaxHops:   max,
+				Filtered:  segment.Filter != nil,
+			}
+
+			// Collect the tags set on the items reached at each hop.
+			for hop := 1; hop <= max; hop++ {
+				if segment.Tag != "" && hop >= min {
+					se.Tags = append(se.Tags, hopTag(alias+segment.Tag, hop))
+				}
+				if segment.Filter != nil {
+					se.Tags = append(se.Tags, filterTag(alias, segment.Level+hop-1))
+				}
+				if tagLevels {
+					se.Tags = append(se.Tags, levelTag(alias, segment.Level+hop-1))
+				}
+			}
+
+			// The items of a tagged segment are embedded in the items of the
+			// closest tagged segment before it.
+			if segment.Tag != "" {
+				se.EmbedAs = segment.Tag
+				se.EmbedIn = embedIn
+				embedIn = segment.Tag
+			}
+
+			// Follow the segment, each hop of a variable depth segment
+			// reaching items of the view.
+			step := fmt.Sprintf(".%s(%q)", directionStep(segment.Direction), segment.Predicate)
+			if segment.Variable() {
+				hopPaths := variableGraphPaths(graphPath, segment, alias, key, false, graphDB)
+				se.Count = countItems(graphDB, hopPaths...)
+				query += fmt.Sprintf("%s.Repeat(%d, %d)", step, min, max)
+			} else {
+				graphPath = segmentPath(graphPath, segment)
+				se.Count = countItems(graphDB, graphPath)
+				query += step
+			}
+
+			if segment.Tag != "" {
+				query += fmt.Sprintf(".Tag(%q)", alias+segment.Tag)
+			}
+
+			pe.Segments = append(pe.Segments, se)
+		}
+
+		pe.Query = query
+		pes = append(pes, pe)
+	}
+
+	return pes
+}
+
+// segmentPath follows a path segment once from the graph path.
+func segmentPath(graphPath *path.Path, segment view.PathSegment) *path.Path {
+	switch segment.Direction {
+	case inString:
+		return graphPath.Clone().In(quad.String(segment.Predicate))
+	case outString:
+		return graphPath.Clone().Out(quad.String(segment.Predicate))
+	}
+
+	return graphPath
+}
+
+// directionStep returns the graph path step following a direction.
+func directionStep(direction string) string {
+	if direction == inString {
+		return "In"
+	}
+
+	return "Out"
+}
+
+// countItems returns the number of distinct items reached by the graph paths.
+func countItems(graphDB *cayley.Handle, graphPaths ...*path.Path) int {
+	found := make(map[string]bool)
+	for _, graphPath := range graphPaths {
+		it := graphPath.BuildIterator()
+		it, _ = it.Optimize()
+		for it.Next() {
+			found[nativeString(graphDB.NameOf(it.Result()))] = true
+		}
+		it.Close()
+	}
+
+	return len(found)
+}
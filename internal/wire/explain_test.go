@@ -0,0 +1,86 @@
+package wire_test
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/wire"
+)
+
+// TestExplain tests explaining how a view would be executed.
+func TestExplain(t *testing.T) {
+	db, store := setup(t)
+	defer teardown(t, db, store)
+
+	bilbo := wirePrefix + "80aa936a-f618-4234-a7be-df59a14cf8de"
+
+	t.Log("Given the need to explain how a view would be executed.")
+	{
+		t.Log("\tWhen explaining the comments of a user")
+		{
+			viewParams := wire.ViewParams{
+				ViewName: wirePrefix + "user comments",
+				ItemKey:  bilbo,
+			}
+
+			ve, err := wire.Explain(tests.Context, db, store, &viewParams)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to explain the view : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to explain the view.", tests.Success)
+
+			if len(ve.Warnings) != 0 {
+				t.Fatalf("\t%s\tShould get no warnings : %v", tests.Failed, ve.Warnings)
+			}
+			t.Logf("\t%s\tShould get no warnings.", tests.Success)
+
+			if len(ve.Roots) != 1 || len(ve.Roots[0].Paths) != 1 || len(ve.Roots[0].Paths[0].Segments) != 1 {
+				t.Fatalf("\t%s\tShould get the single segment of the view : %+v", tests.Failed, ve.Roots)
+			}
+			t.Logf("\t%s\tShould get the single segment of the view.", tests.Success)
+
+			root := ve.Roots[0]
+			if root.Items != 2 || root.Iterator == nil {
+				t.Fatalf("\t%s\tShould find 2 items in the view : %d", tests.Failed, root.Items)
+			}
+			t.Logf("\t%s\tShould find 2 items in the view.", tests.Success)
+
+			se := root.Paths[0].Segments[0]
+			if se.Count != 2 || se.EmbedAs != "comment" || se.EmbedIn != "root" {
+				t.Fatalf("\t%s\tShould reach 2 comments embedded in the root : %+v", tests.Failed, se)
+			}
+			t.Logf("\t%s\tShould reach 2 comments embedded in the root.", tests.Success)
+		}
+
+		t.Log("\tWhen explaining a view following an unknown predicate")
+		{
+			viewParams := wire.ViewParams{
+				ViewName: wirePrefix + "user comments with unfulfilled full path",
+				ItemKey:  bilbo,
+			}
+
+			ve, err := wire.Explain(tests.Context, db, store, &viewParams)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to explain the view : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to explain the view.", tests.Success)
+
+			var found bool
+			for _, w := range ve.Warnings {
+				if w == "Unknown predicate blah" {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("\t%s\tShould warn about the unknown predicate : %v", tests.Failed, ve.Warnings)
+			}
+			t.Logf("\t%s\tShould warn about the unknown predicate.", tests.Success)
+
+			se := ve.Roots[0].Paths[0].Segments[1]
+			if se.Count != 0 || se.EmbedIn != "comment" {
+				t.Fatalf("\t%s\tShould reach no items to embed in the comments : %+v", tests.Failed, se)
+			}
+			t.Logf("\t%s\tShould reach no items to embed in the comments.", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package wire
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/sponge/item"
+	"github.com/coralproject/shelf/internal/wire/relationship"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Set of formats the graph can be exported in.
+const (
+	FormatNQuads = "nquads"
+	FormatJSONLD = "jsonld"
+	FormatDOT    = "dot"
+)
+
+// ErrFormat is returned when a graph format is not supported.
+var ErrFormat = errors.New("Unsupported graph format")
+
+// ExportFilter limits the quads that are exported. A quad is exported if its
+// predicate is one of the predicates and if its subject or object is an item
+// of one of the types. Empty lists don't filter the quads.
+type ExportFilter struct {
+	Predicates []string
+	Types      []string
+}
+
+// ImportReport describes the quads loaded into the graph.
+type ImportReport struct {
+	Quads      int         `json:"quads"`      // Number of quads read.
+	Added      int         `json:"added"`      // Number of quads added to the graph.
+	Existing   int         `json:"existing"`   // Number of quads already in the graph.
+	Violations []Violation `json:"violations"` // Quads between items of types not declared by the relationship.
+}
+
+// ExportGraph writes the quads in the graph in the given format, leaving out
+// the ones derived by rules. The number of quads written is returned.
+func ExportGraph(context interface{}, db *db.DB, store *cayley.Handle, w io.Writer, format string, filter ExportFilter) (int, error) {
+	log.Dev(context, "ExportGraph", "Started : Format[%s] Predicates%v Types%v", format, filter.Predicates, filter.Types)
+
+	var enc quadEncoder
+	switch format {
+	case FormatNQuads:
+		enc = &nquadsEncoder{w: w}
+	case FormatJSONLD:
+		enc = &jsonldEncoder{w: w}
+	case FormatDOT:
+		enc = &dotEncoder{w: w}
+	default:
+		log.Error(context, "ExportGraph", ErrFormat, "Completed")
+		return 0, ErrFormat
+	}
+
+	predicates := make(map[string]bool, len(filter.Predicates))
+	for _, p := range filter.Predicates {
+		predicates[p] = true
+	}
+
+	// Find the items of the requested types.
+	var typed map[string]bool
+	if len(filter.Types) > 0 {
+		var err error
+		if typed, err = itemIDsOfTypes(context, db, filter.Types); err != nil {
+			log.Error(context, "ExportGraph", err, "Completed")
+			return 0, err
+		}
+	}
+
+	if err := enc.begin(); err != nil {
+		log.Error(context, "ExportGraph", err, "Completed")
+		return 0, err
+	}
+
+	var n int
+	it := store.QuadsAllIterator()
+	defer it.Close()
+	for it.Next() {
+		q := store.Quad(it.Result())
+
+		// Derived quads are recomputed from the rules after an import.
+		if isDerived(q) {
+			continue
+		}
+
+		e := Edge{
+			Subject:   nativeString(q.Subject),
+			Predicate: nativeString(q.Predicate),
+			Object:    nativeString(q.Object),
+		}
+
+		if len(predicates) > 0 && !predicates[e.Predicate] {
+			continue
+		}
+
+		if typed != nil && !typed[e.Subject] && !typed[e.Object] {
+			continue
+		}
+
+		if err := enc.encode(e); err != nil {
+			log.Error(context, "ExportGraph", err, "Completed")
+			return n, err
+		}
+		n++
+	}
+	if err := it.Err(); err != nil {
+		log.Error(context, "ExportGraph", err, "Completed")
+		return n, err
+	}
+
+	if err := enc.end(); err != nil {
+		log.Error(context, "ExportGraph", err, "Completed")
+		return n, err
+	}
+
+	log.Dev(context, "ExportGraph", "Completed : Quads[%d]", n)
+	return n, nil
+}
+
+// ImportGraph loads N-Quads into the graph, batchSize quads at a time. Every
+// predicate must have a relationship definition and the types of the items
+// are checked against it. Nothing is loaded if a quad is invalid.
+func ImportGraph(context interface{}, db *db.DB, store *cayley.Handle, r io.Reader, batchSize int) (*ImportReport, error) {
+	log.Dev(context, "ImportGraph", "Started : BatchSize[%d]", batchSize)
+
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	// Read and validate every quad before loading any.
+	rels := make(map[string]bool)
+	var quadParams []QuadParam
+
+	scanner := bufio.NewScanner(r)
+	var line int
+	for scanner.Scan() {
+		line++
+
+		e, ok, err := parseNQuad(scanner.Text())
+		if err != nil {
+			err = fmt.Errorf("Line %d : %v", line, err)
+			log.Error(context, "ImportGraph", err, "Completed")
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if _, found := rels[e.Predicate]; !found {
+			_, err := relationship.GetByPredicate(context, db, e.Predicate)
+			if err != nil && err != relationship.ErrNotFound {
+				log.Error(context, "ImportGraph", err, "Completed")
+				return nil, err
+			}
+			rels[e.Predicate] = err == nil
+		}
+
+		if !rels[e.Predicate] {
+			err := fmt.Errorf("Line %d : No relationship defined for predicate %s", line, e.Predicate)
+			log.Error(context, "ImportGraph", err, "Completed")
+			return nil, err
+		}
+
+		qp := QuadParam{
+			Subject:   e.Subject,
+			Predicate: e.Predicate,
+			Object:    e.Object,
+		}
+
+		if err := qp.Validate(); err != nil {
+			err = fmt.Errorf("Line %d : %v", line, err)
+			log.Error(context, "ImportGraph", err, "Completed")
+			return nil, err
+		}
+
+		quadParams = append(quadParams, qp)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Error(context, "ImportGraph", err, "Completed")
+		return nil, err
+	}
+
+	report := ImportReport{
+		Quads:      len(quadParams),
+		Violations: []Violation{},
+	}
+
+	// Check the types of the related items.
+	kept, violations, err := checkTypes(context, db, quadParams)
+	if err != nil {
+		log.Error(context, "ImportGraph", err, "Completed")
+		return nil, err
+	}
+	if violations != nil {
+		report.Violations = violations
+	}
+
+	// Load the quads in batches.
+	var touched []string
+	for start := 0; start < len(kept); start += batchSize {
+		end := start + batchSize
+		if end > len(kept) {
+			end = len(kept)
+		}
+
+		tx := cayley.NewTransaction()
+		for _, qp := range kept[start:end] {
+			tx.AddQuad(quad.Make(qp.Subject, qp.Predicate, qp.Object, ""))
+			touched = append(touched, qp.Subject, qp.Object)
+		}
+
+		// Quads already in the graph don't reject the rest of the batch.
+		added, err := applyEach(store, tx)
+		if err != nil {
+			log.Error(context, "ImportGraph", err, "Completed")
+			return nil, err
+		}
+		report.Added += added
+		report.Existing += end - start - added
+	}
+
+	// Record the metadata of the relationships.
+	if err := saveMeta(context, db, "", 0, kept); err != nil {
+		log.Error(context, "ImportGraph", err, "Completed")
+		return nil, err
+	}
+
+	// Invalidate any materialized views touched by the quads.
+	if err := InvalidateViews(context, db, touched); err != nil {
+		log.Error(context, "ImportGraph", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "ImportGraph", "Completed : Quads[%d] Added[%d] Existing[%d]", report.Quads, report.Added, report.Existing)
+	return &report, nil
+}
+
+// itemIDsOfTypes returns the IDs of the items of the given types.
+func itemIDsOfTypes(context interface{}, db *db.DB, types []string) (map[string]bool, error) {
+	ids := make(map[string]bool)
+
+	f := func(c *mgo.Collection) error {
+		var it struct {
+			ID string `bson:"item_id"`
+		}
+
+		iter := c.Find(bson.M{"type": bson.M{"$in": types}}).Select(bson.M{"item_id": 1}).Iter()
+		for iter.Next(&it) {
+			ids[it.ID] = true
+		}
+		return iter.Close()
+	}
+
+	if err := db.ExecuteMGO(context, item.Collection, f); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+//==============================================================================
+
+// quadEncoder writes quads in a graph format.
+type quadEncoder interface {
+	begin() error
+	encode(e Edge) error
+	end() error
+}
+
+// nquadsEncoder writes quads as N-Quads.
+type nquadsEncoder struct {
+	w io.Writer
+}
+
+func (enc *nquadsEncoder) begin() error { return nil }
+func (enc *nquadsEncoder) end() error   { return nil }
+
+func (enc *nquadsEncoder) encode(e Edge) error {
+	_, err := fmt.Fprintf(enc.w, "%s %s %s .\n", nquadTerm(e.Subject), nquadTerm(e.Predicate), nquadTerm(e.Object))
+	return err
+}
+
+// jsonldEncoder writes quads as a JSON-LD document with a node per quad.
+type jsonldEncoder struct {
+	w     io.Writer
+	first bool
+}
+
+func (enc *jsonldEncoder) begin() error {
+	enc.first = true
+	_, err := io.WriteString(enc.w, `{"@context":{"@vocab":"urn:shelf:predicate:","@base":"urn:shelf:item:"},"@graph":[`+"\n")
+	return err
+}
+
+func (enc *jsonldEncoder) encode(e Edge) error {
+	node := map[string]interface{}{
+		"@id":       e.Subject,
+		e.Predicate: map[string]string{"@id": e.Object},
+	}
+
+	data, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+
+	if !enc.first {
+		if _, err := io.WriteString(enc.w, ",\n"); err != nil {
+			return err
+		}
+	}
+	enc.first = false
+
+	_, err = enc.w.Write(data)
+	return err
+}
+
+func (enc *jsonldEncoder) end() error {
+	_, err := io.WriteString(enc.w, "\n]}\n")
+	return err
+}
+
+// dotEncoder writes quads as a Graphviz directed graph.
+type dotEncoder struct {
+	w io.Writer
+}
+
+func (enc *dotEncoder) begin() error {
+	_, err := io.WriteString(enc.w, "digraph shelf {\n")
+	return err
+}
+
+func (enc *dotEncoder) encode(e Edge) error {
+	_, err := fmt.Fprintf(enc.w, "\t%s -> %s [label=%s];\n", strconv.Quote(e.Subject), strconv.Quote(e.Object), strconv.Quote(e.Predicate))
+	return err
+}
+
+func (enc *dotEncoder) end() error {
+	_, err := io.WriteString(enc.w, "}\n")
+	return err
+}
+
+//==============================================================================
+
+// nquadTerm returns the N-Quads form of a value, an IRI when possible and
+// otherwise a literal.
+func nquadTerm(s string) string {
+	if s != "" && !strings.ContainsAny(s, "<>\"{}|^`\\ \t\r\n") {
+		return "<" + s + ">"
+	}
+
+	return strconv.Quote(s)
+}
+
+// parseNQuad parses a line of N-Quads. Blank lines and comments are
+// reported as not being a quad. The graph label is ignored.
+func parseNQuad(line string) (Edge, bool, error) {
+	rest := strings.TrimSpace(line)
+	if rest == "" || strings.HasPrefix(rest, "#") {
+		return Edge{}, false, nil
+	}
+
+	if !strings.HasSuffix(rest, ".") {
+		return Edge{}, false, errors.New("Quad must end with a period")
+	}
+	rest = strings.TrimSpace(strings.TrimSuffix(rest, "."))
+
+	var terms []string
+	for rest != "" {
+		term, tail, err := nquadNextTerm(rest)
+		if err != nil {
+			return Edge{}, false, err
+		}
+		terms = append(terms, term)
+		rest = strings.TrimSpace(tail)
+	}
+
+	if len(terms) != 3 && len(terms) != 4 {
+		return Edge{}, false, fmt.Errorf("Quad must have 3 or 4 terms, found %d", len(terms))
+	}
+
+	return Edge{Subject: terms[0], Predicate: terms[1], Object: terms[2]}, true, nil
+}
+
+// nquadNextTerm parses the IRI or literal at the start of the string.
+func nquadNextTerm(s string) (string, string, error) {
+	switch s[0] {
+	case '<':
+		end := strings.IndexByte(s, '>')
+		if end == -1 {
+			return "", "", errors.New("Unterminated IRI")
+		}
+		return s[1:end], s[end+1:], nil
+
+	case '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				term, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", "", err
+				}
+
+				// Skip any datatype or language of the literal.
+				tail := s[i+1:]
+				if end := strings.IndexAny(tail, " \t"); end != -1 && (strings.HasPrefix(tail, "^^") || strings.HasPrefix(tail, "@")) {
+					tail = tail[end:]
+				} else if strings.HasPrefix(tail, "^^") || strings.HasPrefix(tail, "@") {
+					tail = ""
+				}
+				return term, tail, nil
+			}
+		}
+		return "", "", errors.New("Unterminated literal")
+	}
+
+	return "", "", fmt.Errorf("Unexpected term %q", s)
+}
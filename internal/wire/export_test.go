@@ -0,0 +1,94 @@
+package wire_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/cayleygraph/cayley"
+	"github.com/coralproject/shelf/internal/wire"
+)
+
+// TestExportImportGraph tests moving the quads of a graph into another graph.
+func TestExportImportGraph(t *testing.T) {
+	db, store, items := setupGraph(t)
+	defer tests.DisplayLog()
+
+	if _, err := wire.AddToGraph(tests.Context, db, store, items[0]); err != nil {
+		t.Fatalf("\t%s\tShould be able to add relationships to the graph : %s", tests.Failed, err)
+	}
+
+	t.Log("Given the need to export and import the graph.")
+	{
+		t.Log("\tWhen exporting the quads of an item")
+		{
+			var buf bytes.Buffer
+			n, err := wire.ExportGraph(tests.Context, db, store, &buf, wire.FormatNQuads, wire.ExportFilter{})
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to export the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to export the graph.", tests.Success)
+
+			if n == 0 || strings.Count(buf.String(), "\n") != n {
+				t.Fatalf("\t%s\tShould write a line per quad : %d\n%s", tests.Failed, n, buf.String())
+			}
+			t.Logf("\t%s\tShould write a line per quad.", tests.Success)
+
+			other, err := cayley.NewMemoryGraph()
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to create a new Cayley graph : %v", tests.Failed, err)
+			}
+
+			r, err := wire.ImportGraph(tests.Context, db, other, bytes.NewReader(buf.Bytes()), 2)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to import the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to import the graph.", tests.Success)
+
+			if r.Quads != n || r.Added != n {
+				t.Fatalf("\t%s\tShould import every quad : %+v", tests.Failed, r)
+			}
+			t.Logf("\t%s\tShould import every quad.", tests.Success)
+
+			partial, err := cayley.NewMemoryGraph()
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to create a new Cayley graph : %v", tests.Failed, err)
+			}
+
+			first := buf.String()[:strings.Index(buf.String(), "\n")+1]
+			if _, err := wire.ImportGraph(tests.Context, db, partial, strings.NewReader(first), 0); err != nil {
+				t.Fatalf("\t%s\tShould be able to import the first quad : %s", tests.Failed, err)
+			}
+
+			r, err = wire.ImportGraph(tests.Context, db, partial, bytes.NewReader(buf.Bytes()), 0)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to import into a graph holding a quad : %s", tests.Failed, err)
+			}
+
+			if r.Added != n-1 || r.Existing != 1 {
+				t.Fatalf("\t%s\tShould add every quad but the existing one : %+v", tests.Failed, r)
+			}
+			t.Logf("\t%s\tShould add every quad but the existing one.", tests.Success)
+
+			var again bytes.Buffer
+			if _, err := wire.ExportGraph(tests.Context, db, other, &again, wire.FormatDOT, wire.ExportFilter{Predicates: []string{"WTEST_on"}}); err != nil {
+				t.Fatalf("\t%s\tShould be able to export the imported graph : %s", tests.Failed, err)
+			}
+
+			if !strings.HasPrefix(again.String(), "digraph") || strings.Count(again.String(), "->") != 1 {
+				t.Fatalf("\t%s\tShould export the filtered graph as DOT : %s", tests.Failed, again.String())
+			}
+			t.Logf("\t%s\tShould export the filtered graph as DOT.", tests.Success)
+		}
+
+		t.Log("\tWhen importing a quad without a relationship definition")
+		{
+			nq := "<WTEST_80aa936a-f618-4234-a7be-df59a14cf8de> <WTEST_undefined> <WTEST_d1dfa366-d2f7-4a4a-a64f-af89d4c97d82> .\n"
+			if _, err := wire.ImportGraph(tests.Context, db, store, strings.NewReader(nq), 0); err == nil {
+				t.Fatalf("\t%s\tShould not be able to import the quad", tests.Failed)
+			}
+			t.Logf("\t%s\tShould not be able to import the quad.", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package wire
+
+import (
+	"strings"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
+	"github.com/coralproject/shelf/internal/sponge/item"
+	"github.com/coralproject/shelf/internal/wire/pattern"
+	"github.com/coralproject/shelf/internal/xenia/regex"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Set of reasons a content extraction inference is skipped.
+const (
+	SkipRegex    = "The regex of the inference does not exist"
+	SkipGroup    = "The regex has no group with the name of the inference"
+	SkipNoMatch  = "The regex matched nothing in the field"
+	SkipNoLookup = "No item holds the matched values"
+)
+
+// extractIDs applies the regex of an inference to the text of its field and
+// returns the related IDs, or the reason none were found.
+func extractIDs(context interface{}, db *db.DB, inf *pattern.Inference, data map[string]interface{}) ([]string, string, error) {
+	texts := fieldText(data, inf.RelIDField)
+	if len(texts) == 0 {
+		return nil, SkipNoValue, nil
+	}
+
+	rgx, err := regex.GetByName(context, db, inf.Regex)
+	if err != nil {
+		if err == regex.ErrNotFound {
+			log.User(context, "extractIDs", "Regex %q of the inference on %s does not exist", inf.Regex, inf.RelIDField)
+			return nil, SkipRegex, nil
+		}
+		return nil, "", err
+	}
+
+	// Use the named group, or the first group if the regex has any.
+	group := 0
+	if inf.Group != "" {
+		group = -1
+		for i, name := range rgx.Compile.SubexpNames() {
+			if name == inf.Group {
+				group = i
+				break
+			}
+		}
+		if group == -1 {
+			return nil, SkipGroup, nil
+		}
+	} else if rgx.Compile.NumSubexp() > 0 {
+		group = 1
+	}
+
+	// Collect the distinct values matched in every text.
+	seen := make(map[string]bool)
+	var values []string
+	for _, text := range texts {
+		for _, m := range rgx.Compile.FindAllStringSubmatch(text, -1) {
+			v := m[group]
+			if v == "" || seen[v] {
+				continue
+			}
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, SkipNoMatch, nil
+	}
+
+	if inf.LookupType == "" {
+		return values, "", nil
+	}
+
+	ids, err := lookupIDs(context, db, inf.LookupType, inf.LookupField, values)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(ids) == 0 {
+		return nil, SkipNoLookup, nil
+	}
+
+	return ids, "", nil
+}
+
+// lookupIDs returns the IDs of the items of a type holding any of the values
+// in a field of their data.
+func lookupIDs(context interface{}, db *db.DB, itemType, field string, values []string) ([]string, error) {
+	var items []item.Item
+	f := func(c *mgo.Collection) error {
+		q := bson.M{
+			"type":          itemType,
+			"data." + field: bson.M{"$in": values},
+		}
+		log.Dev(context, "lookupIDs", "MGO : db.%s.find(%s)", c.Name, mongo.Query(q))
+		return c.Find(q).Select(bson.M{"item_id": 1}).All(&items)
+	}
+
+	if err := db.ExecuteMGO(context, item.Collection, f); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(items))
+	for _, itm := range items {
+		ids = append(ids, itm.ID)
+	}
+
+	return ids, nil
+}
+
+// fieldText returns the strings held by a field of the data of an item,
+// following the same paths as the related ID field of an inference.
+func fieldText(data map[string]interface{}, path string) []string {
+	var texts []string
+	collectText(data, strings.Split(strings.TrimSuffix(path, "[]"), "."), &texts)
+	return texts
+}
+
+// collectText appends the strings found at the path of names under v.
+func collectText(v interface{}, names []string, texts *[]string) {
+	switch v := v.(type) {
+	case string:
+		if len(names) == 0 && v != "" {
+			*texts = append(*texts, v)
+		}
+
+	case []string:
+		if len(names) == 0 {
+			for _, s := range v {
+				collectText(s, nil, texts)
+			}
+		}
+
+	case map[string]interface{}:
+		if len(names) > 0 {
+			collectText(v[strings.TrimSuffix(names[0], "[]")], names[1:], texts)
+		}
+
+	case bson.M:
+		collectText(map[string]interface{}(v), names, texts)
+
+	case []interface{}:
+		for _, e := range v {
+			collectText(e, names, texts)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package wire_test
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/coralproject/shelf/internal/wire/pattern"
+	"github.com/coralproject/shelf/internal/xenia/regex"
+)
+
+// TestAddToGraphExtract tests inferring relationships from the text of an
+// item with regexes.
+func TestAddToGraphExtract(t *testing.T) {
+	db, store, _ := setupGraph(t)
+	defer tests.DisplayLog()
+
+	rgxs := []regex.Regex{
+		{Name: "WTEST_mention", Expr: `@(\w+)`},
+		{Name: "WTEST_asset_link", Expr: `https://example\.com/assets/(?P<asset>[\w-]+)`},
+	}
+
+	for _, rgx := range rgxs {
+		if err := regex.Upsert(tests.Context, db, rgx); err != nil {
+			t.Fatalf("\t%s\tShould be able to add the regex : %s", tests.Failed, err)
+		}
+		defer regex.Delete(tests.Context, db, rgx.Name)
+	}
+
+	pat := pattern.Pattern{
+		Type: "WTEST_post",
+		Inferences: []pattern.Inference{
+			{
+				RelIDField:  "body",
+				Predicate:   "WTEST_mentions",
+				Direction:   "out",
+				Regex:       "WTEST_mention",
+				LookupType:  "WTEST_user",
+				LookupField: "facebook_user",
+			},
+			{
+				RelIDField: "body",
+				Predicate:  "WTEST_links_to",
+				Direction:  "out",
+				Regex:      "WTEST_asset_link",
+				Group:      "asset",
+			},
+		},
+	}
+
+	if err := pattern.Upsert(tests.Context, db, &pat); err != nil {
+		t.Fatalf("\t%s\tShould be able to add the pattern : %s", tests.Failed, err)
+	}
+	defer pattern.Delete(tests.Context, db, pat.Type)
+
+	itMap := map[string]interface{}{
+		"item_id": "WTEST_0f3b6d0e-1c55-4b7e-8a3c-9d2e4f6a1b27",
+		"type":    "WTEST_post",
+		"version": 1,
+		"data": map[string]interface{}{
+			"body": "Thanks @whitewizard and @nobody, see https://example.com/assets/WTEST_c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+		},
+	}
+
+	t.Log("Given the need to infer relationships from the text of an item.")
+	{
+		t.Log("\tWhen the text mentions a user and links to an asset")
+		{
+			report, err := wire.AddToGraph(tests.Context, db, store, itMap)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to add relationships to the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to add relationships to the graph.", tests.Success)
+			defer wire.RemoveFromGraph(tests.Context, db, store, itMap)
+
+			expected := map[wire.Edge]bool{
+				{Subject: "WTEST_0f3b6d0e-1c55-4b7e-8a3c-9d2e4f6a1b27", Predicate: "WTEST_mentions", Object: "WTEST_a63af637-58af-472b-98c7-f5c00743bac6"}: true,
+				{Subject: "WTEST_0f3b6d0e-1c55-4b7e-8a3c-9d2e4f6a1b27", Predicate: "WTEST_links_to", Object: "WTEST_c1b2bbfe-af9f-4903-8777-bd47c4d5b20a"}: true,
+			}
+
+			if len(report.Added) != len(expected) {
+				t.Fatalf("\t%s\tShould add %d relationships : %+v", tests.Failed, len(expected), report.Added)
+			}
+			for _, e := range report.Added {
+				if !expected[e] {
+					t.Fatalf("\t%s\tShould only add the expected relationships : %+v", tests.Failed, e)
+				}
+			}
+			t.Logf("\t%s\tShould add the mentioned user and the linked asset.", tests.Success)
+		}
+
+		t.Log("\tWhen removing the post after its regex is deleted")
+		{
+			if err := regex.Delete(tests.Context, db, "WTEST_mention"); err != nil {
+				t.Fatalf("\t%s\tShould be able to delete the regex : %s", tests.Failed, err)
+			}
+
+			report, err := wire.RemoveFromGraph(tests.Context, db, store, itMap)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to remove relationships from the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to remove relationships from the graph.", tests.Success)
+
+			if len(report.Removed) != 2 {
+				t.Fatalf("\t%s\tShould remove the relationships added with the post : %+v", tests.Failed, report.Removed)
+			}
+			t.Logf("\t%s\tShould remove the relationships added with the post.", tests.Success)
+		}
+
+		t.Log("\tWhen a required regex inference matches nothing")
+		{
+			pat.Inferences[1].Required = true
+			if err := pattern.Upsert(tests.Context, db, &pat); err != nil {
+				t.Fatalf("\t%s\tShould be able to update the pattern : %s", tests.Failed, err)
+			}
+
+			noLink := map[string]interface{}{
+				"type":    "WTEST_post",
+				"version": 1,
+				"data": map[string]interface{}{
+					"body": "No link here",
+				},
+			}
+
+			err := wire.CheckRequired(tests.Context, db, noLink)
+			reqErr, ok := err.(*wire.RequiredError)
+			if !ok || len(reqErr.Skips) != 1 || reqErr.Skips[0].Reason != wire.SkipNoMatch {
+				t.Fatalf("\t%s\tShould fail the check of the required inference : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould fail the check of the required inference.", tests.Success)
+
+			noLink["item_id"] = "WTEST_7c1e2a9b-3d4f-4a6b-8c0d-1e2f3a4b5c6d"
+			if _, err := wire.AddToGraph(tests.Context, db, store, noLink); err == nil {
+				t.Fatalf("\t%s\tShould not be able to add the post.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould not be able to add the post.", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package wire
+
+import (
+	"strconv"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
+	"github.com/coralproject/shelf/internal/wire/view"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// filterTag returns the tag marking the items reached by a filtered path
+// segment. It can't collide with view tags since those never contain "$".
+func filterTag(alias string, level int) string {
+	return alias + "$filter" + strconv.Itoa(level)
+}
+
+// filterRows removes the graph results in which an item reached by a filtered
+// path segment is not allowed by the filter. The items reached are fetched
+// once per filter, so only the items found by the traversal are checked.
+func filterRows(context interface{}, mgoDB *db.DB, v *view.View, rows []map[string]string) ([]map[string]string, error) {
+
+	// Collect the filters by the tag marking their items.
+	filters := make(map[string]*view.Filter)
+	for idx, pth := range v.Paths {
+		alias := strconv.Itoa(idx+1) + "_"
+		for _, segment := range pth.Segments {
+			if segment.Filter == nil {
+				continue
+			}
+
+			// A variable depth segment filters the items of each hop.
+			_, max := segmentHops(segment)
+			for hop := 1; hop <= max; hop++ {
+				filters[filterTag(alias, segment.Level+hop-1)] = segment.Filter
+			}
+		}
+	}
+
+	if len(filters) == 0 || len(rows) == 0 {
+		return rows, nil
+	}
+
+	// Find the items allowed by each filter.
+	allowed := make(map[string]map[string]bool, len(filters))
+	for tag, filter := range filters {
+		found := make(map[string]bool)
+		var ids []string
+		for _, row := range rows {
+			if id, ok := row[tag]; ok && !found[id] {
+				found[id] = true
+				ids = append(ids, id)
+			}
+		}
+
+		allowed[tag] = make(map[string]bool)
+		if len(ids) == 0 {
+			continue
+		}
+
+		var items []struct {
+			ID string `bson:"item_id"`
+		}
+
+		f := func(c *mgo.Collection) error {
+			q := filter.Query()
+			q["item_id"] = bson.M{"$in": ids}
+			log.Dev(context, "filterRows", "MGO : db.%s.find(%s, {item_id: 1})", c.Name, mongo.Query(q))
+			return c.Find(q).Select(bson.M{"item_id": 1}).All(&items)
+		}
+
+		if err := mgoDB.ExecuteMGO(context, v.Collection, f); err != nil {
+			return nil, err
+		}
+
+		for _, it := range items {
+			allowed[tag][it.ID] = true
+		}
+	}
+
+	// Keep the results where every filtered item is allowed.
+	var kept []map[string]string
+next:
+	for _, row := range rows {
+		for tag := range filters {
+			if id, ok := row[tag]; ok && !allowed[tag][id] {
+				continue next
+			}
+		}
+		kept = append(kept, row)
+	}
+
+	return kept, nil
+}
@@ -0,0 +1,266 @@
+package wire
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/sponge/item"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+
+	// maxDepth is the maximum number of hops followed to find the neighbors
+	// of an item.
+	maxDepth = 5
+
+	// maxPathLength is the maximum number of relationships in a shortest
+	// path. Items farther apart are reported as not connected.
+	maxPathLength = 10
+)
+
+var (
+	// ErrNoPath is returned when no path connects two items.
+	ErrNoPath = errors.New("No path between the items")
+
+	// ErrDirection is returned when a direction is neither in nor out.
+	ErrDirection = errors.New("Direction must be in or out")
+)
+
+// Edge is a relationship between two items in the graph.
+type Edge struct {
+	Subject   string `json:"subject"`
+	Predicate string `json:"predicate"`
+	Object    string `json:"object"`
+}
+
+// NeighborParams contains the parameters used to find the neighbors of an
+// item. An empty predicate or direction follows every relationship.
+type NeighborParams struct {
+	ItemKey   string
+	Predicate string
+	Direction string
+	Depth     int
+	Hydrate   bool
+}
+
+// Neighbors contains the items reached from an item, grouped by the
+// predicate of the relationship they were reached through.
+type Neighbors struct {
+	Item       string              `json:"item"`
+	Predicates map[string][]string `json:"predicates"`
+	Items      []bson.M            `json:"items,omitempty"`
+}
+
+// GraphPath contains the relationships connecting two items.
+type GraphPath struct {
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+	Edges []Edge   `json:"edges"`
+	Items []bson.M `json:"items,omitempty"`
+}
+
+// GetNeighbors returns the items within the requested depth of an item.
+func GetNeighbors(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, params *NeighborParams) (*Neighbors, error) {
+	log.Dev(context, "GetNeighbors", "Started : Item[%s] Predicate[%s] Direction[%s] Depth[%d]", params.ItemKey, params.Predicate, params.Direction, params.Depth)
+
+	if params.Direction != "" && params.Direction != inString && params.Direction != outString {
+		log.Error(context, "GetNeighbors", ErrDirection, "Completed")
+		return nil, ErrDirection
+	}
+
+	depth := params.Depth
+	if depth < 1 {
+		depth = 1
+	}
+	if depth > maxDepth {
+		depth = maxDepth
+	}
+
+	n := Neighbors{
+		Item:       params.ItemKey,
+		Predicates: make(map[string][]string),
+	}
+
+	// Walk the graph one hop at a time from the item.
+	seen := map[string]bool{params.ItemKey: true}
+	frontier := []string{params.ItemKey}
+	var ids []string
+	for hop := 0; hop < depth && len(frontier) > 0; hop++ {
+		var next []string
+		for _, id := range frontier {
+			for _, e := range adjacent(graphDB, id, params.Predicate, params.Direction) {
+				other := e.Object
+				if other == id {
+					other = e.Subject
+				}
+
+				if seen[other] {
+					continue
+				}
+				seen[other] = true
+
+				n.Predicates[e.Predicate] = append(n.Predicates[e.Predicate], other)
+				ids = append(ids, other)
+				next = append(next, other)
+			}
+		}
+		frontier = next
+	}
+
+	if params.Hydrate && len(ids) > 0 {
+		items, err := hydrate(context, mgoDB, ids)
+		if err != nil {
+			log.Error(context, "GetNeighbors", err, "Completed")
+			return nil, err
+		}
+		n.Items = items
+	}
+
+	log.Dev(context, "GetNeighbors", "Completed : Neighbors[%d]", len(ids))
+	return &n, nil
+}
+
+// ShortestPath returns the shortest path between two items, following the
+// relationships in either direction. An empty predicate follows every
+// relationship. Paths longer than maxPathLength are not searched.
+func ShortestPath(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, from, to, predicate string, hydrateItems bool) (*GraphPath, error) {
+	log.Dev(context, "ShortestPath", "Started : From[%s] To[%s] Predicate[%s]", from, to, predicate)
+
+	gp := GraphPath{
+		From:  from,
+		To:    to,
+		Edges: []Edge{},
+	}
+
+	// Search breadth first, keeping the edge each item was reached through.
+	via := map[string]Edge{}
+	seen := map[string]bool{from: true}
+	frontier := []string{from}
+	found := from == to
+	for hop := 0; hop < maxPathLength && len(frontier) > 0 && !found; hop++ {
+		var next []string
+		for _, id := range frontier {
+			for _, e := range adjacent(graphDB, id, predicate, "") {
+				other := e.Object
+				if other == id {
+					other = e.Subject
+				}
+
+				if seen[other] {
+					continue
+				}
+				seen[other] = true
+				via[other] = e
+
+				if other == to {
+					found = true
+					break
+				}
+				next = append(next, other)
+			}
+			if found {
+				break
+			}
+		}
+		frontier = next
+	}
+
+	if !found {
+		log.Error(context, "ShortestPath", ErrNoPath, "Completed")
+		return nil, ErrNoPath
+	}
+
+	// Walk back from the destination to build the path in order.
+	ids := []string{to}
+	for id := to; id != from; {
+		e := via[id]
+		gp.Edges = append([]Edge{e}, gp.Edges...)
+
+		id = e.Subject
+		if id == ids[len(ids)-1] {
+			id = e.Object
+		}
+		ids = append(ids, id)
+	}
+
+	if hydrateItems {
+		items, err := hydrate(context, mgoDB, ids)
+		if err != nil {
+			log.Error(context, "ShortestPath", err, "Completed")
+			return nil, err
+		}
+		gp.Items = items
+	}
+
+	log.Dev(context, "ShortestPath", "Completed : Edges[%d]", len(gp.Edges))
+	return &gp, nil
+}
+
+// adjacent returns the relationships of an item. An item is the subject of
+// its out relationships and the object of its in relationships.
+func adjacent(graphDB *cayley.Handle, id, predicate, direction string) []Edge {
+	v := graphDB.ValueOf(quad.String(id))
+	if v == nil {
+		return nil
+	}
+
+	var dirs []quad.Direction
+	switch direction {
+	case outString:
+		dirs = []quad.Direction{quad.Subject}
+	case inString:
+		dirs = []quad.Direction{quad.Object}
+	default:
+		dirs = []quad.Direction{quad.Subject, quad.Object}
+	}
+
+	var edges []Edge
+	for _, d := range dirs {
+		it := graphDB.QuadIterator(d, v)
+		for it.Next() {
+			q := graphDB.Quad(it.Result())
+			e := Edge{
+				Subject:   nativeString(q.Subject),
+				Predicate: nativeString(q.Predicate),
+				Object:    nativeString(q.Object),
+			}
+
+			if predicate != "" && e.Predicate != predicate {
+				continue
+			}
+			edges = append(edges, e)
+		}
+		it.Close()
+	}
+
+	return edges
+}
+
+// nativeString returns the string form of a value stored in the graph.
+func nativeString(v quad.Value) string {
+	if s, ok := quad.NativeOf(v).(string); ok {
+		return s
+	}
+	return fmt.Sprint(quad.NativeOf(v))
+}
+
+// hydrate retrieves the items with the given IDs. The items are returned as
+// stored, callers serving them are left to apply the masks.
+func hydrate(context interface{}, mgoDB *db.DB, ids []string) ([]bson.M, error) {
+	items, err := item.GetByIDs(context, mgoDB, ids)
+	if err != nil && err != item.ErrNotFound {
+		return nil, err
+	}
+
+	docs := make([]bson.M, 0, len(items))
+	for _, it := range items {
+		docs = append(docs, itemDoc(it, nil))
+	}
+
+	return docs, nil
+}
@@ -0,0 +1,99 @@
+package wire_test
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/wire"
+)
+
+// TestGetNeighbors tests finding the items adjacent to an item.
+func TestGetNeighbors(t *testing.T) {
+	db, store := setup(t)
+	defer teardown(t, db, store)
+
+	t.Log("Given the need to find the neighbors of an item.")
+	{
+		t.Log("\tWhen finding the comments on an asset")
+		{
+			params := wire.NeighborParams{
+				ItemKey:   wirePrefix + "c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+				Predicate: wirePrefix + "on",
+				Direction: "in",
+				Hydrate:   true,
+			}
+
+			n, err := wire.GetNeighbors(tests.Context, db, store, &params)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to find the neighbors : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to find the neighbors.", tests.Success)
+
+			if len(n.Predicates[params.Predicate]) != 3 {
+				t.Fatalf("\t%s\tShould find 3 comments : %v", tests.Failed, n.Predicates)
+			}
+			t.Logf("\t%s\tShould find 3 comments.", tests.Success)
+
+			if len(n.Items) != 3 {
+				t.Fatalf("\t%s\tShould include the 3 comments : %d", tests.Failed, len(n.Items))
+			}
+			t.Logf("\t%s\tShould include the 3 comments.", tests.Success)
+		}
+
+		t.Log("\tWhen following the comments two hops out")
+		{
+			params := wire.NeighborParams{
+				ItemKey: wirePrefix + "c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+				Depth:   2,
+			}
+
+			n, err := wire.GetNeighbors(tests.Context, db, store, &params)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to find the neighbors : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to find the neighbors.", tests.Success)
+
+			if len(n.Predicates[wirePrefix+"authored"]) != 2 {
+				t.Fatalf("\t%s\tShould find the 2 authors : %v", tests.Failed, n.Predicates)
+			}
+			t.Logf("\t%s\tShould find the 2 authors.", tests.Success)
+		}
+	}
+}
+
+// TestShortestPath tests finding the shortest path between two items.
+func TestShortestPath(t *testing.T) {
+	db, store := setup(t)
+	defer teardown(t, db, store)
+
+	t.Log("Given the need to find the shortest path between two items.")
+	{
+		t.Log("\tWhen finding the path from a user to a comment they flagged the author of")
+		{
+			from := wirePrefix + "a63af637-58af-472b-98c7-f5c00743bac6"
+			to := wirePrefix + "d1dfa366-d2f7-4a4a-a64f-af89d4c97d82"
+
+			gp, err := wire.ShortestPath(tests.Context, db, store, from, to, "", false)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to find the path : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to find the path.", tests.Success)
+
+			if len(gp.Edges) != 2 || gp.Edges[0].Predicate != wirePrefix+"flagged" || gp.Edges[1].Predicate != wirePrefix+"authored" {
+				t.Fatalf("\t%s\tShould go through the flag and the authorship : %+v", tests.Failed, gp.Edges)
+			}
+			t.Logf("\t%s\tShould go through the flag and the authorship.", tests.Success)
+		}
+
+		t.Log("\tWhen finding a path along a predicate that does not connect the items")
+		{
+			from := wirePrefix + "a63af637-58af-472b-98c7-f5c00743bac6"
+			to := wirePrefix + "d1dfa366-d2f7-4a4a-a64f-af89d4c97d82"
+
+			if _, err := wire.ShortestPath(tests.Context, db, store, from, to, wirePrefix+"on", false); err != wire.ErrNoPath {
+				t.Fatalf("\t%s\tShould not find a path : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould not find a path.", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package wire
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/coralproject/shelf/internal/wire/view"
+)
+
+// maxHops is the maximum number of times a variable depth path segment
+// follows its predicate.
+const maxHops = 32
+
+// hopSep separates the tag of a variable depth segment from the hop at which
+// the tagged item was reached.
+const hopSep = "#"
+
+// segmentHops returns the minimum and maximum number of times a path segment
+// follows its predicate.
+func segmentHops(segment view.PathSegment) (int, int) {
+	if !segment.Variable() {
+		return 1, 1
+	}
+
+	min := segment.MinHops
+	if min < 1 {
+		min = 1
+	}
+
+	max := segment.MaxHops
+	if max == view.UntilNoMore || max > maxHops {
+		max = maxHops
+	}
+
+	return min, max
+}
+
+// hopTag returns the tag of the items reached by a segment at the given hop.
+// Items reached at the first hop keep the segment tag.
+func hopTag(tag string, hop int) string {
+	if hop == 1 {
+		return tag
+	}
+
+	return tag + hopSep + strconv.Itoa(hop)
+}
+
+// baseTag returns the segment tag of a hop tag.
+func baseTag(tag string) string {
+	if i := strings.Index(tag, hopSep); i != -1 {
+		return tag[:i]
+	}
+
+	return tag
+}
+
+// variableGraphPaths follows a variable depth segment from the graph path,
+// or from the key if the segment starts the path. It returns a graph path
+// for each number of hops between the minimum and maximum of the segment.
+// A segment followed until no more items are found stops at the first hop
+// reaching no items. The items reached at each hop are tagged with their
+// level if needed.
+func variableGraphPaths(graphPath *path.Path, segment view.PathSegment, alias, key string, tagLevels bool, graphDB *cayley.Handle) []*path.Path {
+	min, max := segmentHops(segment)
+
+	if graphPath == nil {
+		graphPath = cayley.StartPath(graphDB, quad.String(key))
+	}
+
+	var paths []*path.Path
+	for hop := 1; hop <= max; hop++ {
+
+		// Add the relationship.
+		switch segment.Direction {
+		case inString:
+			graphPath = graphPath.Clone().In(quad.String(segment.Predicate))
+		case outString:
+			graphPath = graphPath.Clone().Out(quad.String(segment.Predicate))
+		}
+
+		// Items reached before the minimum hops are not part of the view.
+		if segment.Tag != "" && hop >= min {
+			graphPath = graphPath.Clone().Tag(hopTag(alias+segment.Tag, hop))
+		}
+
+		// Tag the items the filter will be applied to, if present.
+		if segment.Filter != nil {
+			graphPath = graphPath.Clone().Tag(filterTag(alias, segment.Level+hop-1))
+		}
+
+		// Tag the items reached at the level, if needed.
+		if tagLevels {
+			graphPath = graphPath.Clone().Tag(levelTag(alias, segment.Level+hop-1))
+		}
+
+		if segment.MaxHops == view.UntilNoMore && !reachesItems(graphPath) {
+			break
+		}
+
+		if hop >= min {
+			paths = append(paths, graphPath.Clone())
+		}
+	}
+
+	// Keep the path reaching no items so the view path is still translated.
+	if len(paths) == 0 {
+		paths = append(paths, graphPath)
+	}
+
+	return paths
+}
+
+// reachesItems reports if the graph path reaches any item.
+func reachesItems(graphPath *path.Path) bool {
+	it := graphPath.BuildIterator()
+	it, _ = it.Optimize()
+	defer it.Close()
+
+	return it.Next()
+}
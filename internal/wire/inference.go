@@ -0,0 +1,154 @@
+package wire
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/coralproject/shelf/internal/platform/db"
+)
+
+// Set of reasons an inference of a pattern is skipped.
+const (
+	SkipNoValue   = "The item holds no value for the field"
+	SkipDirection = "The direction must be in or out"
+	SkipRejected  = "The related items have types not declared by the relationship"
+)
+
+// Skip describes an inference of a pattern that didn't produce a
+// relationship.
+type Skip struct {
+	Field     string `json:"field"`
+	Predicate string `json:"predicate"`
+	Related   string `json:"related,omitempty"`
+	Required  bool   `json:"required"`
+	Reason    string `json:"reason"`
+}
+
+// InferenceReport describes the changes made to the graph for an item.
+type InferenceReport struct {
+	Added      []Edge      `json:"added"`
+	Removed    []Edge      `json:"removed"`
+	Skipped    []Skip      `json:"skipped"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// newInferenceReport returns a report with no changes.
+func newInferenceReport() *InferenceReport {
+	return &InferenceReport{
+		Added:   []Edge{},
+		Removed: []Edge{},
+		Skipped: []Skip{},
+	}
+}
+
+// RequiredError is returned when the required inferences of the pattern of
+// an item don't produce a relationship, whatever the reason they are skipped.
+type RequiredError struct {
+	ItemID string
+	Type   string
+	Fields []string
+	Skips  []Skip
+}
+
+// Error implements the error interface.
+func (e *RequiredError) Error() string {
+	reasons := make([]string, 0, len(e.Skips))
+	for _, s := range e.Skips {
+		reasons = append(reasons, fmt.Sprintf("%s (%s)", s.Field, s.Reason))
+	}
+	return fmt.Sprintf("Item %q of type %s can't infer the required relationships of the fields : %s", e.ItemID, e.Type, strings.Join(reasons, ", "))
+}
+
+// checkID is the item ID used to infer the relationships of an item that
+// doesn't have an ID yet.
+const checkID = "$check"
+
+// CheckRequired verifies the required inferences of the pattern of the item
+// produce relationships, returning a *RequiredError otherwise. The
+// inferences are run as when the item is added to the graph, so regexes and
+// lookups must find the related items. The item doesn't need an ID yet.
+func CheckRequired(context interface{}, db *db.DB, item map[string]interface{}) error {
+	log.Dev(context, "CheckRequired", "Started : %v", item)
+
+	// Infer the relationships of a copy of the item holding an ID.
+	check := make(map[string]interface{}, len(item))
+	for k, v := range item {
+		check[k] = v
+	}
+	if id, _ := item["item_id"].(string); id == "" {
+		check["item_id"] = checkID
+	}
+
+	inferred, skips, err := inferItem(context, db, check)
+	if err != nil {
+		log.Error(context, "CheckRequired", err, "Completed")
+		return err
+	}
+
+	kept, _, err := checkTypes(context, db, inferred)
+	if err != nil {
+		log.Error(context, "CheckRequired", err, "Completed")
+		return err
+	}
+
+	if err := requiredError(item, append(skips, rejectedSkips(inferred, kept)...)); err != nil {
+		log.Error(context, "CheckRequired", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "CheckRequired", "Completed")
+	return nil
+}
+
+// requiredError returns a *RequiredError if any required inference was
+// skipped, whatever the reason.
+func requiredError(item map[string]interface{}, skips []Skip) error {
+	var fields []string
+	var failed []Skip
+	for _, s := range skips {
+		if s.Required {
+			fields = append(fields, s.Field)
+			failed = append(failed, s)
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	itemID, _ := item["item_id"].(string)
+	itemType, _ := item["type"].(string)
+
+	return &RequiredError{
+		ItemID: itemID,
+		Type:   itemType,
+		Fields: fields,
+		Skips:  failed,
+	}
+}
+
+// rejectedSkips returns the skips of the quads dropped by the type check.
+func rejectedSkips(quadParams, kept []QuadParam) []Skip {
+	keep := make(map[Edge]bool)
+	for _, qp := range kept {
+		keep[Edge{Subject: qp.Subject, Predicate: qp.Predicate, Object: qp.Object}] = true
+	}
+
+	var skips []Skip
+	for _, qp := range quadParams {
+		if keep[Edge{Subject: qp.Subject, Predicate: qp.Predicate, Object: qp.Object}] {
+			continue
+		}
+
+		skips = append(skips, Skip{
+			Field:     qp.field,
+			Predicate: qp.Predicate,
+			Related:   qp.related,
+			Required:  qp.required,
+			Reason:    SkipRejected,
+		})
+	}
+
+	return skips
+}
@@ -0,0 +1,225 @@
+package wire
+
+import (
+	"time"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MetaCollection is the Mongo collection containing the metadata of the
+// relationships in the graph.
+const MetaCollection = "relationship_meta"
+
+// metaChunk is the maximum number of relationships looked up by a query for
+// their metadata.
+const metaChunk = 500
+
+// RelMeta contains the metadata of a relationship in the graph.
+type RelMeta struct {
+	Subject   string    `bson:"subject" json:"subject"`
+	Predicate string    `bson:"predicate" json:"predicate"`
+	Object    string    `bson:"object" json:"object"`
+	Label     string    `bson:"label,omitempty" json:"label,omitempty"`
+	Time      time.Time `bson:"time" json:"time"`                           // When the relationship was created.
+	ItemID    string    `bson:"item_id,omitempty" json:"item_id,omitempty"` // Item the relationship was inferred from.
+	Version   int       `bson:"version,omitempty" json:"version,omitempty"` // Version of that item.
+}
+
+// saveMeta records the metadata of relationships added to the graph. A
+// relationship without a time keeps the time it was first added, and the
+// label and item of a relationship are only replaced when provided.
+func saveMeta(context interface{}, db *db.DB, itemID string, version int, quadParams []QuadParam) error {
+	if len(quadParams) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+
+	f := func(c *mgo.Collection) error {
+		bulk := c.Bulk()
+		bulk.Unordered()
+
+		for _, qp := range quadParams {
+			q := bson.M{"subject": qp.Subject, "predicate": qp.Predicate, "object": qp.Object}
+
+			set := bson.M{}
+			if qp.Label != "" {
+				set["label"] = qp.Label
+			}
+			if itemID != "" {
+				set["item_id"] = itemID
+				set["version"] = version
+			}
+
+			update := bson.M{}
+			if qp.Time.IsZero() {
+				update["$setOnInsert"] = bson.M{"time": now}
+			} else {
+				set["time"] = qp.Time
+			}
+			if len(set) > 0 {
+				update["$set"] = set
+			}
+
+			log.Dev(context, "saveMeta", "MGO : db.%s.upsert(%s, %s)", c.Name, mongo.Query(q), mongo.Query(update))
+			bulk.Upsert(q, update)
+		}
+
+		_, err := bulk.Run()
+		return err
+	}
+
+	return db.ExecuteMGO(context, MetaCollection, f)
+}
+
+// backfillMeta records the metadata of relationships that have none. The
+// metadata already recorded is kept.
+func backfillMeta(context interface{}, db *db.DB, metas []RelMeta) error {
+	if len(metas) == 0 {
+		return nil
+	}
+
+	f := func(c *mgo.Collection) error {
+		bulk := c.Bulk()
+		bulk.Unordered()
+
+		for _, m := range metas {
+			q := bson.M{"subject": m.Subject, "predicate": m.Predicate, "object": m.Object}
+
+			set := bson.M{"time": m.Time}
+			if m.Label != "" {
+				set["label"] = m.Label
+			}
+			if m.ItemID != "" {
+				set["item_id"] = m.ItemID
+				set["version"] = m.Version
+			}
+
+			update := bson.M{"$setOnInsert": set}
+			log.Dev(context, "backfillMeta", "MGO : db.%s.upsert(%s, %s)", c.Name, mongo.Query(q), mongo.Query(update))
+			bulk.Upsert(q, update)
+		}
+
+		_, err := bulk.Run()
+		return err
+	}
+
+	return db.ExecuteMGO(context, MetaCollection, f)
+}
+
+// removeMeta removes the metadata of relationships removed from the graph.
+func removeMeta(context interface{}, db *db.DB, quadParams []QuadParam) error {
+	if len(quadParams) == 0 {
+		return nil
+	}
+
+	f := func(c *mgo.Collection) error {
+		var or []bson.M
+		for _, qp := range quadParams {
+			or = append(or, bson.M{"subject": qp.Subject, "predicate": qp.Predicate, "object": qp.Object})
+		}
+
+		q := bson.M{"$or": or}
+		log.Dev(context, "removeMeta", "MGO : db.%s.remove(%s)", c.Name, mongo.Query(q))
+		_, err := c.RemoveAll(q)
+		return err
+	}
+
+	return db.ExecuteMGO(context, MetaCollection, f)
+}
+
+// itemMeta returns the relationships recorded as inferred from the item.
+func itemMeta(context interface{}, db *db.DB, itemID string) ([]QuadParam, error) {
+	var metas []RelMeta
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"item_id": itemID}
+		log.Dev(context, "itemMeta", "MGO : db.%s.find(%s)", c.Name, mongo.Query(q))
+		return c.Find(q).All(&metas)
+	}
+
+	if err := db.ExecuteMGO(context, MetaCollection, f); err != nil {
+		return nil, err
+	}
+
+	qps := make([]QuadParam, 0, len(metas))
+	for _, m := range metas {
+		qps = append(qps, QuadParam{Subject: m.Subject, Predicate: m.Predicate, Object: m.Object, Label: m.Label, Time: m.Time})
+	}
+	return qps, nil
+}
+
+// getMeta retrieves the metadata of the relationships. The relationships are
+// looked up by their exact subject, predicate and object, metaChunk of them
+// per query.
+func getMeta(context interface{}, db *db.DB, edges []Edge) (map[Edge]RelMeta, error) {
+	metas := make(map[Edge]RelMeta)
+	if len(edges) == 0 {
+		return metas, nil
+	}
+
+	// The same relationship can be reached from several rows.
+	seen := make(map[Edge]bool, len(edges))
+	var or []bson.M
+	for _, e := range edges {
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+
+		or = append(or, bson.M{"subject": e.Subject, "predicate": e.Predicate, "object": e.Object})
+	}
+
+	for len(or) > 0 {
+		chunk := or
+		if len(chunk) > metaChunk {
+			chunk = chunk[:metaChunk]
+		}
+		or = or[len(chunk):]
+
+		f := func(c *mgo.Collection) error {
+			q := bson.M{"$or": chunk}
+			log.Dev(context, "getMeta", "MGO : db.%s.find({$or: [%d relationships]})", c.Name, len(chunk))
+
+			var m RelMeta
+			iter := c.Find(q).Iter()
+			for iter.Next(&m) {
+				metas[Edge{Subject: m.Subject, Predicate: m.Predicate, Object: m.Object}] = m
+			}
+			return iter.Close()
+		}
+
+		if err := db.ExecuteMGO(context, MetaCollection, f); err != nil {
+			return nil, err
+		}
+	}
+
+	return metas, nil
+}
+
+// relTime returns the time held by a field of the item data, the zero time
+// if the field doesn't hold a time.
+func relTime(itemIn map[string]interface{}, field string) time.Time {
+	if field == "" {
+		return time.Time{}
+	}
+
+	data, ok := itemIn["data"].(map[string]interface{})
+	if !ok {
+		return time.Time{}
+	}
+
+	switch v := data[field].(type) {
+	case time.Time:
+		return v.UTC()
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t.UTC()
+		}
+	}
+
+	return time.Time{}
+}
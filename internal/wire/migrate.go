@@ -0,0 +1,373 @@
+package wire
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
+	"github.com/coralproject/shelf/internal/wire/pattern"
+	"github.com/coralproject/shelf/internal/wire/relationship"
+	"github.com/coralproject/shelf/internal/wire/rule"
+	"github.com/coralproject/shelf/internal/wire/view"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ErrMigrateParams is returned when the predicates of a migration are missing
+// or the same.
+var ErrMigrateParams = errors.New("Migration requires two different predicates")
+
+// MigrateParams describes the renaming of a predicate.
+type MigrateParams struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	BatchSize int    `json:"batch_size"` // Number of quads rewritten at a time.
+	DryRun    bool   `json:"dry_run"`    // Report the changes without making them.
+}
+
+// Change describes a change made to a document referencing a predicate.
+type Change struct {
+	Kind   string `json:"kind"`   // pattern, relationship, view or rule.
+	Name   string `json:"name"`   // Type, predicate or name of the document.
+	Field  string `json:"field"`  // Field of the document holding the predicate.
+	Before string `json:"before"` // Value before the change.
+	After  string `json:"after"`  // Value after the change.
+}
+
+// MigrateReport describes the changes made by the renaming of a predicate.
+type MigrateReport struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	DryRun  bool     `json:"dry_run"`
+	Changes []Change `json:"changes"`
+	Quads   int      `json:"quads"`
+}
+
+// migrateFunc renames the predicate in a kind of document.
+type migrateFunc func(context interface{}, db *db.DB, mp *MigrateParams) ([]Change, error)
+
+// MigratePredicate renames a predicate in the patterns, relationships, views
+// and rules referencing it, then rewrites its quads in place, batch size
+// quads at a time. The documents are changed first so items imported during
+// the migration use the new predicate. Running it again after a failure
+// resumes where it stopped, since only what still references the old
+// predicate is changed. On a dry run the changes are reported but not made.
+func MigratePredicate(context interface{}, db *db.DB, store *cayley.Handle, mp *MigrateParams, progress Progress) (*MigrateReport, error) {
+	log.Dev(context, "MigratePredicate", "Started : From[%s] To[%s] DryRun[%v]", mp.From, mp.To, mp.DryRun)
+
+	if mp.From == "" || mp.To == "" || mp.From == mp.To {
+		log.Error(context, "MigratePredicate", ErrMigrateParams, "Completed")
+		return nil, ErrMigrateParams
+	}
+
+	batchSize := mp.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	r := MigrateReport{
+		From:    mp.From,
+		To:      mp.To,
+		DryRun:  mp.DryRun,
+		Changes: []Change{},
+	}
+
+	for _, f := range []migrateFunc{migratePatterns, migrateRelationship, migrateViews, migrateRules} {
+		changes, err := f(context, db, mp)
+		if err != nil {
+			log.Error(context, "MigratePredicate", err, "Completed")
+			return nil, err
+		}
+		r.Changes = append(r.Changes, changes...)
+	}
+
+	quads, err := migrateQuads(store, mp, batchSize, progress)
+	if err != nil {
+		log.Error(context, "MigratePredicate", err, "Completed")
+		return nil, err
+	}
+	r.Quads = quads
+
+	if !mp.DryRun {
+		if err := migrateMeta(context, db, mp); err != nil {
+			log.Error(context, "MigratePredicate", err, "Completed")
+			return nil, err
+		}
+	}
+
+	log.Dev(context, "MigratePredicate", "Completed : Changes[%d] Quads[%d]", len(r.Changes), r.Quads)
+	return &r, nil
+}
+
+//==============================================================================
+
+// migratePatterns renames the predicate in the inferences of the patterns.
+func migratePatterns(context interface{}, db *db.DB, mp *MigrateParams) ([]Change, error) {
+	ps, err := pattern.GetAll(context, db)
+	if err != nil && err != pattern.ErrNotFound {
+		return nil, err
+	}
+
+	var changes []Change
+	for i := range ps {
+		p := &ps[i]
+
+		var changed bool
+		for j := range p.Inferences {
+			if p.Inferences[j].Predicate != mp.From {
+				continue
+			}
+
+			p.Inferences[j].Predicate = mp.To
+			changes = append(changes, Change{Kind: "pattern", Name: p.Type, Field: fmt.Sprintf("inferences[%d].predicate", j), Before: mp.From, After: mp.To})
+			changed = true
+		}
+
+		if changed && !mp.DryRun {
+			if err := pattern.Upsert(context, db, p); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// migrateRelationship renames the relationship of the predicate, unless one
+// already exists for the new predicate.
+func migrateRelationship(context interface{}, db *db.DB, mp *MigrateParams) ([]Change, error) {
+	rel, err := relationship.GetByPredicate(context, db, mp.From)
+	if err != nil {
+		if err == relationship.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	changes := []Change{{Kind: "relationship", Name: mp.From, Field: "predicate", Before: mp.From, After: mp.To}}
+	if mp.DryRun {
+		return changes, nil
+	}
+
+	if _, err := relationship.GetByPredicate(context, db, mp.To); err != nil {
+		if err != relationship.ErrNotFound {
+			return nil, err
+		}
+
+		rel.Predicate = mp.To
+		if err := relationship.Upsert(context, db, rel); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := relationship.Delete(context, db, mp.From); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// migrateViews renames the predicate in the path segments of the views.
+func migrateViews(context interface{}, db *db.DB, mp *MigrateParams) ([]Change, error) {
+	vs, err := view.GetAll(context, db)
+	if err != nil && err != view.ErrNotFound {
+		return nil, err
+	}
+
+	var changes []Change
+	for i := range vs {
+		v := &vs[i]
+
+		var changed bool
+		for j := range v.Paths {
+			for k := range v.Paths[j].Segments {
+				if v.Paths[j].Segments[k].Predicate != mp.From {
+					continue
+				}
+
+				v.Paths[j].Segments[k].Predicate = mp.To
+				changes = append(changes, Change{Kind: "view", Name: v.Name, Field: fmt.Sprintf("paths[%d].path_segments[%d].predicate", j, k), Before: mp.From, After: mp.To})
+				changed = true
+			}
+		}
+
+		if changed && !mp.DryRun {
+			if err := view.Upsert(context, db, v); err != nil {
+				return nil, err
+			}
+
+			if err := InvalidateView(context, db, v.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// migrateRules renames the predicate in the chains and derived predicates of
+// the rules.
+func migrateRules(context interface{}, db *db.DB, mp *MigrateParams) ([]Change, error) {
+	rules, err := getRules(context, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for i := range rules {
+		r := &rules[i]
+
+		var changed bool
+		for j := range r.Chain {
+			if r.Chain[j].Predicate != mp.From {
+				continue
+			}
+
+			r.Chain[j].Predicate = mp.To
+			changes = append(changes, Change{Kind: "rule", Name: r.Name, Field: fmt.Sprintf("chain[%d].predicate", j), Before: mp.From, After: mp.To})
+			changed = true
+		}
+
+		if r.Predicate == mp.From {
+			r.Predicate = mp.To
+			changes = append(changes, Change{Kind: "rule", Name: r.Name, Field: "predicate", Before: mp.From, After: mp.To})
+			changed = true
+		}
+
+		if changed && !mp.DryRun {
+			if err := rule.Upsert(context, db, r); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// migrateQuads rewrites the quads of the predicate with the new predicate,
+// batch size quads at a time, and returns the number of quads rewritten. The
+// new quads are added before the old ones are removed so a failure never
+// loses a relationship. On a dry run the quads are only counted.
+func migrateQuads(store *cayley.Handle, mp *MigrateParams, batchSize int, progress Progress) (int, error) {
+	v := store.ValueOf(quad.String(mp.From))
+	if v == nil {
+		return 0, nil
+	}
+
+	it := store.QuadIterator(quad.Predicate, v)
+	size, _ := it.Size()
+	it.Close()
+
+	total := int(size)
+	if mp.DryRun {
+		return total, nil
+	}
+
+	var done int
+	for {
+
+		// Collect the next batch. The quads rewritten no longer have the old
+		// predicate, so every batch starts over from the first quad left.
+		var batch []quad.Quad
+		it := store.QuadIterator(quad.Predicate, v)
+		for len(batch) < batchSize && it.Next() {
+			batch = append(batch, store.Quad(it.Result()))
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return done, err
+		}
+
+		if len(batch) == 0 {
+			return done, nil
+		}
+
+		add := cayley.NewTransaction()
+		remove := cayley.NewTransaction()
+		for _, q := range batch {
+			nq := q
+			nq.Predicate = quad.String(mp.To)
+			add.AddQuad(nq)
+			remove.RemoveQuad(q)
+		}
+
+		if _, err := applyEach(store, add); err != nil {
+			return done, err
+		}
+		if _, err := applyEach(store, remove); err != nil {
+			return done, err
+		}
+
+		done += len(batch)
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+}
+
+// applyEach applies a transaction and returns the number of quads applied.
+// When some of its quads already exist, or no longer exist, the quads are
+// applied one at a time so the others are not rejected with them.
+func applyEach(store *cayley.Handle, tx *graph.Transaction) (int, error) {
+	err := store.ApplyTransaction(tx)
+	if err == nil {
+		return len(tx.Deltas), nil
+	}
+
+	if !graph.IsQuadExist(err) && !graph.IsQuadNotExist(err) {
+		return 0, err
+	}
+
+	var applied int
+	for i := range tx.Deltas {
+		one := cayley.NewTransaction()
+		switch tx.Deltas[i].Action {
+		case graph.Add:
+			one.AddQuad(tx.Deltas[i].Quad)
+		case graph.Delete:
+			one.RemoveQuad(tx.Deltas[i].Quad)
+		}
+
+		if err := store.ApplyTransaction(one); err != nil {
+			if !graph.IsQuadExist(err) && !graph.IsQuadNotExist(err) {
+				return applied, err
+			}
+			continue
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// migrateMeta renames the predicate in the metadata of the relationships and
+// in the predicates of the persistent views.
+func migrateMeta(context interface{}, db *db.DB, mp *MigrateParams) error {
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"predicate": mp.From}
+		u := bson.M{"$set": bson.M{"predicate": mp.To}}
+		log.Dev(context, "migrateMeta", "MGO : db.%s.update(%s, %s, {multi: true})", c.Name, mongo.Query(q), mongo.Query(u))
+		_, err := c.UpdateAll(q, u)
+		return err
+	}
+
+	if err := db.ExecuteMGO(context, MetaCollection, f); err != nil {
+		return err
+	}
+
+	f = func(c *mgo.Collection) error {
+		q := bson.M{"predicates": mp.From}
+		u := bson.M{"$set": bson.M{"predicates.$": mp.To}}
+		log.Dev(context, "migrateMeta", "MGO : db.%s.update(%s, %s, {multi: true})", c.Name, mongo.Query(q), mongo.Query(u))
+		_, err := c.UpdateAll(q, u)
+		return err
+	}
+
+	return db.ExecuteMGO(context, PersistCollection, f)
+}
@@ -0,0 +1,136 @@
+package wire_test
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/coralproject/shelf/internal/wire"
+	"github.com/coralproject/shelf/internal/wire/pattern"
+	"github.com/coralproject/shelf/internal/wire/relationship"
+	"github.com/coralproject/shelf/internal/wire/view"
+)
+
+// TestMigratePredicate tests renaming a predicate in the documents and the
+// graph.
+func TestMigratePredicate(t *testing.T) {
+	db, store, _ := setupGraph(t)
+	defer tests.DisplayLog()
+
+	pat := pattern.Pattern{
+		Type: "WTEST_migrate_post",
+		Inferences: []pattern.Inference{
+			{RelIDField: "asset", Predicate: "WTEST_migrate_on", Direction: "out"},
+		},
+	}
+	if err := pattern.Upsert(tests.Context, db, &pat); err != nil {
+		t.Fatalf("\t%s\tShould be able to add the pattern : %s", tests.Failed, err)
+	}
+	defer pattern.Delete(tests.Context, db, pat.Type)
+
+	rel := relationship.Relationship{
+		SubjectTypes: []string{pat.Type},
+		Predicate:    "WTEST_migrate_on",
+		ObjectTypes:  []string{"WTEST_asset"},
+	}
+	if err := relationship.Upsert(tests.Context, db, &rel); err != nil {
+		t.Fatalf("\t%s\tShould be able to add the relationship : %s", tests.Failed, err)
+	}
+	defer relationship.Delete(tests.Context, db, "WTEST_migrate_on")
+	defer relationship.Delete(tests.Context, db, "WTEST_migrate_on_asset")
+
+	v := view.View{
+		Name:       "WTEST_migrate_posts",
+		Collection: "items",
+		StartType:  "WTEST_asset",
+		Paths: []view.Path{
+			{Segments: view.PathSegments{{Level: 1, Direction: "in", Predicate: "WTEST_migrate_on"}}},
+		},
+	}
+	if err := view.Upsert(tests.Context, db, &v); err != nil {
+		t.Fatalf("\t%s\tShould be able to add the view : %s", tests.Failed, err)
+	}
+	defer view.Delete(tests.Context, db, v.Name)
+
+	post := map[string]interface{}{
+		"item_id": "WTEST_7a1c9e52-3f4d-4b8a-9c6e-2d5f8a1b3c47",
+		"type":    pat.Type,
+		"version": 1,
+		"data": map[string]interface{}{
+			"asset": "WTEST_c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+		},
+	}
+	if _, err := wire.AddToGraph(tests.Context, db, store, post); err != nil {
+		t.Fatalf("\t%s\tShould be able to add relationships to the graph : %s", tests.Failed, err)
+	}
+
+	t.Log("Given the need to rename a predicate.")
+	{
+		t.Log("\tWhen running the migration as a dry run")
+		{
+			mp := wire.MigrateParams{From: "WTEST_migrate_on", To: "WTEST_migrate_on_asset", DryRun: true}
+			r, err := wire.MigratePredicate(tests.Context, db, store, &mp, nil)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to plan the migration : %s", tests.Failed, err)
+			}
+
+			if len(r.Changes) != 3 || r.Quads != 1 {
+				t.Fatalf("\t%s\tShould list 3 changes and 1 quad : %+v", tests.Failed, r)
+			}
+			t.Logf("\t%s\tShould list 3 changes and 1 quad.", tests.Success)
+
+			if countOut(store, post["item_id"].(string), "WTEST_migrate_on") != 1 {
+				t.Fatalf("\t%s\tShould leave the graph unchanged.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould leave the graph unchanged.", tests.Success)
+		}
+
+		t.Log("\tWhen running the migration")
+		{
+			mp := wire.MigrateParams{From: "WTEST_migrate_on", To: "WTEST_migrate_on_asset", BatchSize: 1}
+			if _, err := wire.MigratePredicate(tests.Context, db, store, &mp, nil); err != nil {
+				t.Fatalf("\t%s\tShould be able to migrate the predicate : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to migrate the predicate.", tests.Success)
+
+			if countOut(store, post["item_id"].(string), "WTEST_migrate_on") != 0 || countOut(store, post["item_id"].(string), "WTEST_migrate_on_asset") != 1 {
+				t.Fatalf("\t%s\tShould rewrite the quads.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould rewrite the quads.", tests.Success)
+
+			p, err := pattern.GetByType(tests.Context, db, pat.Type)
+			if err != nil || p.Inferences[0].Predicate != "WTEST_migrate_on_asset" {
+				t.Fatalf("\t%s\tShould update the pattern : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould update the pattern.", tests.Success)
+
+			if _, err := relationship.GetByPredicate(tests.Context, db, "WTEST_migrate_on_asset"); err != nil {
+				t.Fatalf("\t%s\tShould rename the relationship : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould rename the relationship.", tests.Success)
+
+			r, err := wire.MigratePredicate(tests.Context, db, store, &mp, nil)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to run the migration again : %s", tests.Failed, err)
+			}
+
+			if len(r.Changes) != 0 || r.Quads != 0 {
+				t.Fatalf("\t%s\tShould have nothing left to migrate : %+v", tests.Failed, r)
+			}
+			t.Logf("\t%s\tShould have nothing left to migrate.", tests.Success)
+		}
+	}
+}
+
+// countOut returns the number of relationships from an item with a predicate.
+func countOut(store *cayley.Handle, id, predicate string) int {
+	it, _ := cayley.StartPath(store, quad.String(id)).Out(quad.String(predicate)).BuildIterator().Optimize()
+	defer it.Close()
+
+	var count int
+	for it.Next() {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,180 @@
+package wire
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	// ErrInvalidCursor is returned when the cursor provided to page through a
+	// view can't be decoded.
+	ErrInvalidCursor = errors.New("Invalid cursor")
+
+	// ErrInvalidSort is returned when the field the view items are sorted by
+	// is not a plain item field.
+	ErrInvalidSort = errors.New("Invalid sort field")
+)
+
+// Page describes the page of view items that was returned.
+type Page struct {
+	Total  int    `json:"total"`            // Number of items in the view.
+	Limit  int    `json:"limit,omitempty"`  // Maximum number of items in the page.
+	Offset int    `json:"offset,omitempty"` // Number of items skipped.
+	Next   string `json:"next,omitempty"`   // Cursor for the next page, empty on the last page.
+}
+
+// cursor marks the position of the last item of a page.
+type cursor struct {
+	Value interface{} `bson:"v,omitempty"`
+	ID    string      `bson:"id"`
+}
+
+// encodeCursor returns the opaque form of the cursor.
+func encodeCursor(c cursor) (string, error) {
+	data, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses the opaque form of a cursor.
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	if err := bson.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	// The value is compared with the sort field in the query, so only
+	// scalar values are accepted.
+	switch c.Value.(type) {
+	case nil, string, bool, int, int64, float64, time.Time, bson.ObjectId:
+	default:
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+//==============================================================================
+
+// paged reports if the view params ask for a page of the view items.
+func (vp *ViewParams) paged() bool {
+	return vp.Limit > 0 || vp.Offset > 0 || vp.Cursor != "" || vp.Sort != ""
+}
+
+// sortField returns the item field the view items are sorted by and if the
+// sort is descending. Items are sorted by their ID by default. The field is
+// used as a query key, so operators and empty keys are rejected.
+func (vp *ViewParams) sortField() (string, bool, error) {
+	if vp.Sort == "" {
+		return "item_id", false, nil
+	}
+
+	field, desc := vp.Sort, false
+	if strings.HasPrefix(field, "-") {
+		field, desc = field[1:], true
+	}
+
+	for _, key := range strings.Split(field, ".") {
+		if key == "" || strings.Contains(key, "$") {
+			return "", false, ErrInvalidSort
+		}
+	}
+
+	return field, desc, nil
+}
+
+// itemQuery returns the query and sort used to fetch the page of view
+// items. The item ID breaks ties so the order is stable between pages.
+func itemQuery(vp *ViewParams, ids []string) (bson.M, []string, error) {
+	q := bson.M{"item_id": bson.M{"$in": ids}}
+
+	if !vp.paged() {
+		return q, nil, nil
+	}
+
+	field, desc, err := vp.sortField()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir, op := "", "$gt"
+	if desc {
+		dir, op = "-", "$lt"
+	}
+
+	sort := []string{dir + "item_id"}
+	if field != "item_id" {
+		sort = []string{dir + field, dir + "item_id"}
+	}
+
+	if vp.Cursor == "" {
+		return q, sort, nil
+	}
+
+	c, err := decodeCursor(vp.Cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Only the items after the cursor are in the page.
+	if field == "item_id" {
+		q["item_id"] = bson.M{"$in": ids, op: c.ID}
+		return q, sort, nil
+	}
+
+	q["$or"] = []bson.M{
+		{field: bson.M{op: c.Value}},
+		{field: c.Value, "item_id": bson.M{op: c.ID}},
+	}
+
+	return q, sort, nil
+}
+
+// nextCursor returns the cursor for the page following the last item of a
+// full page.
+func nextCursor(vp *ViewParams, last bson.M) (string, error) {
+	field, _, err := vp.sortField()
+	if err != nil {
+		return "", err
+	}
+
+	c := cursor{
+		ID: last["item_id"].(string),
+	}
+
+	if field != "item_id" {
+		c.Value = lookupField(last, field)
+	}
+
+	return encodeCursor(c)
+}
+
+// lookupField returns the value of a field in dot notation.
+func lookupField(doc bson.M, field string) interface{} {
+	var v interface{} = doc
+	for _, key := range strings.Split(field, ".") {
+		switch m := v.(type) {
+		case bson.M:
+			v = m[key]
+		case map[string]interface{}:
+			v = m[key]
+		default:
+			return nil
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,97 @@
+package wire_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/wire"
+)
+
+// TestExecutePaged tests paging through the items of a view.
+func TestExecutePaged(t *testing.T) {
+	db, store := setup(t)
+	defer teardown(t, db, store)
+
+	t.Log("Given the need to page through the items of a view.")
+	{
+		t.Log("\tWhen using the thread view two items at a time")
+		{
+			viewParams := wire.ViewParams{
+				ViewName: wirePrefix + "thread",
+				ItemKey:  wirePrefix + "c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+				Limit:    2,
+			}
+
+			var ids []string
+			for pages := 0; pages < 5; pages++ {
+				result, err := wire.Execute(tests.Context, db, store, &viewParams)
+				if err != nil {
+					t.Fatalf("\t%s\tShould be able to execute the view : %s", tests.Failed, err)
+				}
+
+				if result.Page == nil || result.Page.Total != 5 {
+					t.Fatalf("\t%s\tShould report 5 items in the view : %+v", tests.Failed, result.Page)
+				}
+
+				items, _ := result.Results.([]bson.M)
+				if len(items) > 2 {
+					t.Fatalf("\t%s\tShould return at most 2 items : %d", tests.Failed, len(items))
+				}
+
+				for _, it := range items {
+					ids = append(ids, it["item_id"].(string))
+				}
+
+				if result.Page.Next == "" {
+					break
+				}
+				viewParams.Cursor = result.Page.Next
+			}
+			t.Logf("\t%s\tShould report 5 items in the view.", tests.Success)
+
+			if len(ids) != 5 {
+				t.Fatalf("\t%s\tShould get all 5 items across the pages : %v", tests.Failed, ids)
+			}
+			t.Logf("\t%s\tShould get all 5 items across the pages.", tests.Success)
+
+			for i := 1; i < len(ids); i++ {
+				if ids[i-1] >= ids[i] {
+					t.Fatalf("\t%s\tShould get the items in order : %v", tests.Failed, ids)
+				}
+			}
+			t.Logf("\t%s\tShould get the items in order.", tests.Success)
+		}
+
+		t.Log("\tWhen sorting by an operator or using a cursor holding a document")
+		{
+			viewParams := wire.ViewParams{
+				ViewName: wirePrefix + "thread",
+				ItemKey:  wirePrefix + "c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+				Limit:    2,
+			}
+
+			for _, sort := range []string{"$where", "-", "data.$gt", "data..name"} {
+				viewParams.Sort = sort
+				if _, err := wire.Execute(tests.Context, db, store, &viewParams); err != wire.ErrInvalidSort {
+					t.Fatalf("\t%s\tShould reject the sort field %q : %v", tests.Failed, sort, err)
+				}
+			}
+			t.Logf("\t%s\tShould reject sort fields that are not plain item fields.", tests.Success)
+
+			data, err := bson.Marshal(bson.M{"v": bson.M{"$gt": ""}, "id": ""})
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to encode the cursor : %s", tests.Failed, err)
+			}
+
+			viewParams.Sort = "type"
+			viewParams.Cursor = base64.RawURLEncoding.EncodeToString(data)
+			if _, err := wire.Execute(tests.Context, db, store, &viewParams); err != wire.ErrInvalidCursor {
+				t.Fatalf("\t%s\tShould reject the cursor : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould reject a cursor holding a document.", tests.Success)
+		}
+	}
+}
@@ -1,12 +1,23 @@
 package pattern
 
-import validator "gopkg.in/bluesuncorp/validator.v8"
+import (
+	"errors"
+	"strings"
+
+	validator "gopkg.in/bluesuncorp/validator.v8"
+)
 
 //==============================================================================
 
 // validate is used to perform model field validation.
 var validate *validator.Validate
 
+// Set of error variables for validating inferences.
+var (
+	ErrRelIDField = errors.New("Related ID field must be a path of names separated by dots")
+	ErrLookup     = errors.New("Lookup type and field must be provided together with a regex")
+)
+
 func init() {
 	validate = validator.New(&validator.Config{TagName: "validate"})
 }
@@ -14,13 +25,30 @@ func init() {
 //==============================================================================
 
 // Inference includes information used to infer a particular relationship
-// within an item.
+// within an item. The relationship is labeled with Label and created at the
+// time held by the TimeField of the item, or when it is added otherwise.
+//
+// RelIDField is a path into the data of the item. Nested documents are
+// reached with dots, as in author.id, and the documents of an array with
+// brackets, as in mentions[].user_id.
+//
+// When Regex names a stored regex, the related IDs are extracted from the
+// text of RelIDField instead: every match yields the value of the capture
+// group named Group, or of the first group when Group is empty. With a
+// LookupType, each value is looked up in the LookupField of the items of
+// that type and relates the items found.
 type Inference struct {
-	RelIDField string `bson:"related_ID_field" json:"related_ID_field" validate:"required,min=2"`
-	RelType    string `bson:"related_type,omitempty" json:"related_type,omitempty"`
-	Predicate  string `bson:"predicate" json:"predicate" validate:"required,min=2"`
-	Direction  string `bson:"direction" json:"direction" validate:"required,min=2"`
-	Required   bool   `bson:"required" json:"required"`
+	RelIDField  string `bson:"related_ID_field" json:"related_ID_field" validate:"required,min=2"`
+	RelType     string `bson:"related_type,omitempty" json:"related_type,omitempty"`
+	Predicate   string `bson:"predicate" json:"predicate" validate:"required,min=2"`
+	Direction   string `bson:"direction" json:"direction" validate:"required,min=2"`
+	Required    bool   `bson:"required" json:"required"`
+	Label       string `bson:"label,omitempty" json:"label,omitempty"`
+	TimeField   string `bson:"time_field,omitempty" json:"time_field,omitempty"`
+	Regex       string `bson:"regex,omitempty" json:"regex,omitempty"`
+	Group       string `bson:"group,omitempty" json:"group,omitempty"`
+	LookupType  string `bson:"lookup_type,omitempty" json:"lookup_type,omitempty"`
+	LookupField string `bson:"lookup_field,omitempty" json:"lookup_field,omitempty"`
 }
 
 // Validate checks the Inference value for consistency.
@@ -28,6 +56,21 @@ func (inf *Inference) Validate() error {
 	if err := validate.Struct(inf); err != nil {
 		return err
 	}
+
+	for _, name := range strings.Split(inf.RelIDField, ".") {
+		name = strings.TrimSuffix(name, "[]")
+		if name == "" || strings.ContainsAny(name, "[]") {
+			return ErrRelIDField
+		}
+	}
+
+	if (inf.LookupType == "") != (inf.LookupField == "") {
+		return ErrLookup
+	}
+
+	if inf.LookupType != "" && inf.Regex == "" {
+		return ErrLookup
+	}
 	return nil
 }
 
@@ -45,7 +88,7 @@ func (p *Pattern) Validate() error {
 	}
 
 	for _, infer := range p.Inferences {
-		if err := validate.Struct(infer); err != nil {
+		if err := infer.Validate(); err != nil {
 			return err
 		}
 	}
@@ -168,3 +168,43 @@ func TestGetAll(t *testing.T) {
 		}
 	}
 }
+
+// TestValidateRelIDField tests the paths accepted for the related ID field
+// of an inference.
+func TestValidateRelIDField(t *testing.T) {
+	tests.ResetLog()
+	defer tests.DisplayLog()
+
+	fields := []struct {
+		field string
+		valid bool
+	}{
+		{"author", true},
+		{"author.id", true},
+		{"mentions[].user_id", true},
+		{"tags[]", true},
+		{"author..id", false},
+		{".id", false},
+		{"mentions[]user_id", false},
+	}
+
+	t.Log("Given the need to validate the related ID field of inferences.")
+	{
+		for _, f := range fields {
+			t.Logf("\tWhen using the field %q", f.field)
+			{
+				inf := pattern.Inference{
+					RelIDField: f.field,
+					Predicate:  "RTEST_authored",
+					Direction:  "in",
+				}
+
+				err := inf.Validate()
+				if (err == nil) != f.valid {
+					t.Fatalf("\t%s\tShould report the field as valid[%v] : %v", tests.Failed, f.valid, err)
+				}
+				t.Logf("\t%s\tShould report the field as valid[%v].", tests.Success, f.valid)
+			}
+		}
+	}
+}
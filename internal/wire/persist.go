@@ -0,0 +1,288 @@
+package wire
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/cayleygraph/cayley"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
+	"github.com/coralproject/shelf/internal/wire/view"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// PersistCollection is the Mongo collection containing the registered
+// persistent views.
+const PersistCollection = "view_persist"
+
+// PersistPrefix prefixes the collections persistent views are saved into, so
+// a persistent view never replaces a collection it doesn't own.
+const PersistPrefix = "persist_"
+
+var (
+	// ErrPersistNotFound is returned when no persistent view is registered
+	// for a collection.
+	ErrPersistNotFound = errors.New("Persistent view Not found")
+
+	// ErrPersistParams is returned when a persistent view is missing its
+	// view name, item key or collection, or the collection is not prefixed
+	// with PersistPrefix.
+	ErrPersistParams = errors.New("Persistent view requires a view name, item key and collection prefixed with " + PersistPrefix)
+
+	// ErrPersistConflict is returned when a collection is already registered
+	// for another view or item.
+	ErrPersistConflict = errors.New("Collection already persists another view or item")
+)
+
+// PersistentView is the view of an item saved into a collection that is kept
+// up to date as the relationships along the path of the view change.
+type PersistentView struct {
+	ViewName    string    `bson:"view_name" json:"view_name"`
+	ItemKey     string    `bson:"item_key" json:"item_key"`
+	Collection  string    `bson:"collection" json:"collection"`
+	Predicates  []string  `bson:"predicates" json:"predicates"`
+	ItemIDs     []string  `bson:"item_ids" json:"-"`
+	Items       int       `bson:"items" json:"items"`
+	Stale       bool      `bson:"stale" json:"stale"`
+	Error       string    `bson:"error,omitempty" json:"error,omitempty"`
+	RefreshedAt time.Time `bson:"refreshed_at" json:"refreshed_at"`
+}
+
+//==============================================================================
+
+// PersistView registers a view of an item to be saved into a collection and
+// saves it. The collection must be prefixed with PersistPrefix and not be
+// registered for another view or item. The collection is recomputed whenever an item in the view
+// changes or a relationship along the path of the view is added to or
+// removed from one of its items.
+func PersistView(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, pv *PersistentView) error {
+	log.Dev(context, "PersistView", "Started : Name[%s] Item[%s] Collection[%s]", pv.ViewName, pv.ItemKey, pv.Collection)
+
+	if pv.ViewName == "" || pv.ItemKey == "" || !strings.HasPrefix(pv.Collection, PersistPrefix) || pv.Collection == PersistPrefix {
+		log.Error(context, "PersistView", ErrPersistParams, "Completed")
+		return ErrPersistParams
+	}
+
+	// Only the view and item registered for the collection can replace it.
+	cur, err := GetPersistentView(context, mgoDB, pv.Collection)
+	if err != nil && err != ErrPersistNotFound {
+		log.Error(context, "PersistView", err, "Completed")
+		return err
+	}
+	if cur != nil && (cur.ViewName != pv.ViewName || cur.ItemKey != pv.ItemKey) {
+		log.Error(context, "PersistView", ErrPersistConflict, "Completed")
+		return ErrPersistConflict
+	}
+
+	if _, err := view.GetByName(context, mgoDB, pv.ViewName); err != nil {
+		log.Error(context, "PersistView", err, "Completed")
+		return err
+	}
+
+	if err := refreshPersistentView(context, mgoDB, graphDB, pv); err != nil {
+		log.Error(context, "PersistView", err, "Completed")
+		return err
+	}
+
+	if pv.Error != "" {
+		err := errors.New(pv.Error)
+		log.Error(context, "PersistView", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "PersistView", "Completed : Items[%d]", pv.Items)
+	return nil
+}
+
+// GetPersistentViews retrieves the registered persistent views and their
+// freshness.
+func GetPersistentViews(context interface{}, mgoDB *db.DB) ([]PersistentView, error) {
+	log.Dev(context, "GetPersistentViews", "Started")
+
+	var pvs []PersistentView
+	f := func(c *mgo.Collection) error {
+		log.Dev(context, "GetPersistentViews", "MGO : db.%s.find({}).sort([\"collection\"])", c.Name)
+		return c.Find(nil).Sort("collection").All(&pvs)
+	}
+
+	if err := mgoDB.ExecuteMGO(context, PersistCollection, f); err != nil {
+		log.Error(context, "GetPersistentViews", err, "Completed")
+		return nil, err
+	}
+
+	if len(pvs) == 0 {
+		log.Error(context, "GetPersistentViews", ErrPersistNotFound, "Completed")
+		return nil, ErrPersistNotFound
+	}
+
+	log.Dev(context, "GetPersistentViews", "Completed : Views[%d]", len(pvs))
+	return pvs, nil
+}
+
+// GetPersistentView retrieves the persistent view saved into a collection.
+func GetPersistentView(context interface{}, mgoDB *db.DB, col string) (*PersistentView, error) {
+	log.Dev(context, "GetPersistentView", "Started : Collection[%s]", col)
+
+	var pv PersistentView
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"collection": col}
+		log.Dev(context, "GetPersistentView", "MGO : db.%s.findOne(%s)", c.Name, mongo.Query(q))
+		return c.Find(q).One(&pv)
+	}
+
+	if err := mgoDB.ExecuteMGO(context, PersistCollection, f); err != nil {
+		if err == mgo.ErrNotFound {
+			err = ErrPersistNotFound
+		}
+		log.Error(context, "GetPersistentView", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "GetPersistentView", "Completed")
+	return &pv, nil
+}
+
+// DeletePersistentView unregisters the persistent view saved into a
+// collection and drops the collection.
+func DeletePersistentView(context interface{}, mgoDB *db.DB, col string) error {
+	log.Dev(context, "DeletePersistentView", "Started : Collection[%s]", col)
+
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"collection": col}
+		log.Dev(context, "DeletePersistentView", "MGO : db.%s.remove(%s)", c.Name, mongo.Query(q))
+		return c.Remove(q)
+	}
+
+	if err := mgoDB.ExecuteMGO(context, PersistCollection, f); err != nil {
+		if err == mgo.ErrNotFound {
+			err = ErrPersistNotFound
+		}
+		log.Error(context, "DeletePersistentView", err, "Completed")
+		return err
+	}
+
+	f = func(c *mgo.Collection) error {
+		if err := c.DropCollection(); err != nil && err.Error() != "ns not found" {
+			return err
+		}
+		return nil
+	}
+
+	if err := mgoDB.ExecuteMGO(context, col, f); err != nil {
+		log.Error(context, "DeletePersistentView", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "DeletePersistentView", "Completed")
+	return nil
+}
+
+//==============================================================================
+
+// refreshPersistentViews recomputes the persistent views affected by a change
+// to an item and its relationships. A view is affected when it contains the
+// item, or when a changed relationship follows a predicate of its path from
+// or to one of its items. A view that fails to refresh is marked stale with
+// the error so the change to the graph itself is not lost.
+func refreshPersistentViews(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, itemID string, edges []Edge) error {
+	ids := append([]string{itemID}, edgeIDs(edges)...)
+
+	var pvs []PersistentView
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"item_ids": bson.M{"$in": ids}}
+		log.Dev(context, "refreshPersistentViews", "MGO : db.%s.find(%s)", c.Name, mongo.Query(q))
+		return c.Find(q).All(&pvs)
+	}
+
+	if err := mgoDB.ExecuteMGO(context, PersistCollection, f); err != nil {
+		return err
+	}
+
+	for i := range pvs {
+		if !persistAffected(&pvs[i], itemID, edges) {
+			continue
+		}
+
+		if err := refreshPersistentView(context, mgoDB, graphDB, &pvs[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// persistAffected reports if a change to an item and its relationships
+// affects a persistent view.
+func persistAffected(pv *PersistentView, itemID string, edges []Edge) bool {
+	items := make(map[string]bool)
+	for _, id := range pv.ItemIDs {
+		items[id] = true
+	}
+
+	if items[itemID] {
+		return true
+	}
+
+	preds := make(map[string]bool)
+	for _, p := range pv.Predicates {
+		preds[p] = true
+	}
+
+	for _, e := range edges {
+		if preds[e.Predicate] && (items[e.Subject] || items[e.Object]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// refreshPersistentView recomputes the collection of a persistent view and
+// records its freshness.
+func refreshPersistentView(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, pv *PersistentView) error {
+	v, ids, err := swapView(context, mgoDB, graphDB, pv.ViewName, pv.ItemKey, pv.Collection)
+	if err != nil {
+		log.Error(context, "refreshPersistentView", err, "Collection[%s]", pv.Collection)
+		pv.Stale = true
+		pv.Error = err.Error()
+	} else {
+		pv.Predicates = viewPredicates(v)
+		pv.ItemIDs = append(ids, pv.ItemKey)
+		pv.Items = len(ids)
+		pv.Stale = false
+		pv.Error = ""
+		pv.RefreshedAt = time.Now()
+	}
+
+	f := func(c *mgo.Collection) error {
+		if err := c.EnsureIndexKey("item_ids"); err != nil {
+			return err
+		}
+
+		q := bson.M{"collection": pv.Collection}
+		log.Dev(context, "refreshPersistentView", "MGO : db.%s.upsert(%s, %s)", c.Name, mongo.Query(q), mongo.Query(pv))
+		_, err := c.Upsert(q, pv)
+		return err
+	}
+
+	return mgoDB.ExecuteMGO(context, PersistCollection, f)
+}
+
+// viewPredicates returns the distinct predicates along the paths of a view.
+func viewPredicates(v *view.View) []string {
+	seen := make(map[string]bool)
+	var preds []string
+	for _, path := range v.Paths {
+		for _, segment := range path.Segments {
+			if seen[segment.Predicate] {
+				continue
+			}
+			seen[segment.Predicate] = true
+			preds = append(preds, segment.Predicate)
+		}
+	}
+	return preds
+}
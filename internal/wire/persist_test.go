@@ -0,0 +1,88 @@
+package wire_test
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/wire"
+)
+
+// TestPersistView tests keeping a persistent view up to date as items are
+// added to and removed from the graph.
+func TestPersistView(t *testing.T) {
+	db, store := setup(t)
+	defer teardown(t, db, store)
+
+	pv := wire.PersistentView{
+		ViewName:   wirePrefix + "thread",
+		ItemKey:    wirePrefix + "c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+		Collection: wire.PersistPrefix + wirePrefix + "thread",
+	}
+
+	comment := map[string]interface{}{
+		"item_id": wirePrefix + "5e0c2f1a-7d4b-4c3e-9a8f-1b2c3d4e5f60",
+		"type":    wirePrefix + "comment",
+		"version": 1,
+		"data": map[string]interface{}{
+			"author": wirePrefix + "80aa936a-f618-4234-a7be-df59a14cf8de",
+			"asset":  wirePrefix + "c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+		},
+	}
+
+	t.Log("Given the need to keep a persistent view up to date.")
+	{
+		t.Logf("\tWhen persisting the view named %s", pv.ViewName)
+		{
+			if err := wire.PersistView(tests.Context, db, store, &pv); err != nil {
+				t.Fatalf("\t%s\tShould be able to persist the view : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to persist the view.", tests.Success)
+			defer wire.DeletePersistentView(tests.Context, db, pv.Collection)
+
+			other := pv
+			other.ItemKey = wirePrefix + "80aa936a-f618-4234-a7be-df59a14cf8de"
+			if err := wire.PersistView(tests.Context, db, store, &other); err != wire.ErrPersistConflict {
+				t.Fatalf("\t%s\tShould not persist another item into the collection : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould not persist another item into the collection.", tests.Success)
+
+			other = pv
+			other.Collection = "items"
+			if err := wire.PersistView(tests.Context, db, store, &other); err != wire.ErrPersistParams {
+				t.Fatalf("\t%s\tShould not persist into an unprefixed collection : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould not persist into an unprefixed collection.", tests.Success)
+
+			items := pv.Items
+
+			if _, err := wire.AddToGraph(tests.Context, db, store, comment); err != nil {
+				t.Fatalf("\t%s\tShould be able to add a comment to the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to add a comment to the graph.", tests.Success)
+
+			got, err := wire.GetPersistentView(tests.Context, db, pv.Collection)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to get the persistent view : %s", tests.Failed, err)
+			}
+
+			if got.Stale || got.Items != items+1 {
+				t.Fatalf("\t%s\tShould include the new comment in the view : %+v", tests.Failed, got)
+			}
+			t.Logf("\t%s\tShould include the new comment in the view.", tests.Success)
+
+			if _, err := wire.RemoveFromGraph(tests.Context, db, store, comment); err != nil {
+				t.Fatalf("\t%s\tShould be able to remove the comment from the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to remove the comment from the graph.", tests.Success)
+
+			if got, err = wire.GetPersistentView(tests.Context, db, pv.Collection); err != nil {
+				t.Fatalf("\t%s\tShould be able to get the persistent view : %s", tests.Failed, err)
+			}
+
+			if got.Items != items {
+				t.Fatalf("\t%s\tShould remove the comment from the view : %+v", tests.Failed, got)
+			}
+			t.Logf("\t%s\tShould remove the comment from the view.", tests.Success)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package relationship
 
-import validator "gopkg.in/bluesuncorp/validator.v8"
+import (
+	"fmt"
+
+	validator "gopkg.in/bluesuncorp/validator.v8"
+)
 
 //==============================================================================
 
@@ -13,14 +17,35 @@ func init() {
 
 //==============================================================================
 
+// Set of policies for relationships between items of types not declared
+// by the relationship.
+const (
+	TypeCheckReject = "reject" // The relationship is not added.
+	TypeCheckWarn   = "warn"   // The relationship is added and reported.
+	TypeCheckAllow  = "allow"  // The types are not checked.
+)
+
+// Set of policies for the items holding a relationship to an item that is
+// removed, the dependents.
+const (
+	OnDeleteRestrict = "restrict" // The item can't be removed.
+	OnDeleteCascade  = "cascade"  // The dependents are removed as well.
+	OnDeleteDetach   = "detach"   // The relationships are removed from the graph.
+	OnDeleteKeep     = "keep"     // The relationships are left in the graph.
+)
+
 // Relationship contains metadata about a relationship.
-// Note, predicate should be unique.
+// Note, predicate should be unique. An empty TypeCheck uses the default
+// policy of the service adding the relationships. An empty OnDelete keeps
+// the relationships of removed items.
 type Relationship struct {
 	SubjectTypes []string `bson:"subject_types" json:"subject_types" validate:"required,min=1"`
 	Predicate    string   `bson:"predicate" json:"predicate" validate:"required,min=2"`
 	ObjectTypes  []string `bson:"object_types" json:"object_types" validate:"required,min=1"`
 	InString     string   `bson:"in_string,omitempty" json:"in_string,omitempty"`
 	OutString    string   `bson:"out_string,omitempty" json:"out_string,omitempty"`
+	TypeCheck    string   `bson:"type_check,omitempty" json:"type_check,omitempty"`
+	OnDelete     string   `bson:"on_delete,omitempty" json:"on_delete,omitempty"`
 }
 
 // Validate checks the Relationship value for consistency.
@@ -28,5 +53,39 @@ func (r *Relationship) Validate() error {
 	if err := validate.Struct(r); err != nil {
 		return err
 	}
+
+	switch r.TypeCheck {
+	case "", TypeCheckReject, TypeCheckWarn, TypeCheckAllow:
+	default:
+		return fmt.Errorf("Invalid type check policy %q", r.TypeCheck)
+	}
+
+	switch r.OnDelete {
+	case "", OnDeleteRestrict, OnDeleteCascade, OnDeleteDetach, OnDeleteKeep:
+	default:
+		return fmt.Errorf("Invalid on delete policy %q", r.OnDelete)
+	}
+
 	return nil
 }
+
+// Allows reports if the relationship is declared between the item types.
+// An unknown type is allowed since it can't be checked.
+func (r *Relationship) Allows(subjectType, objectType string) bool {
+	return allowsType(r.SubjectTypes, subjectType) && allowsType(r.ObjectTypes, objectType)
+}
+
+// allowsType reports if the type is in the list of types.
+func allowsType(types []string, itemType string) bool {
+	if itemType == "" {
+		return true
+	}
+
+	for _, t := range types {
+		if t == itemType {
+			return true
+		}
+	}
+
+	return false
+}
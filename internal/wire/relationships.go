@@ -4,14 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ardanlabs/kit/log"
 	"github.com/cayleygraph/cayley"
-	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/coralproject/shelf/internal/wire/pattern"
 	validator "gopkg.in/bluesuncorp/validator.v8"
+	"gopkg.in/mgo.v2/bson"
 )
 
 var (
@@ -38,6 +39,17 @@ type QuadParam struct {
 	Subject   string `validate:"required,min=2"`
 	Predicate string `validate:"required,min=2"`
 	Object    string `validate:"required,min=2"`
+	Label     string
+	Time      time.Time // When the relationship was created, zero if unknown.
+
+	// The types of the items, when known without retrieving them.
+	subjectType string
+	objectType  string
+
+	// The inference the relationship was inferred from.
+	field    string
+	related  string
+	required bool
 }
 
 // Validate checks the QuadParams value for consistency.
@@ -48,17 +60,43 @@ func (q *QuadParam) Validate() error {
 	return nil
 }
 
-// AddToGraph adds relationships as quads into the cayley graph.
-func AddToGraph(context interface{}, db *db.DB, store *cayley.Handle, item map[string]interface{}) error {
+// AddToGraph adds relationships as quads into the cayley graph. An item
+// whose required inferences don't produce a relationship returns a
+// *RequiredError and nothing is added. The relationships between items of types not declared
+// by the relationship are reported, and are only added if their policy
+// allows it.
+func AddToGraph(context interface{}, db *db.DB, store *cayley.Handle, item map[string]interface{}) (*InferenceReport, error) {
 	log.Dev(context, "AddToGraph", "Started : %v", item)
 
 	// Infer the relationships in the item.
-	quadParams, err := inferRelationships(context, db, item)
+	inferred, skips, err := inferItem(context, db, item)
 	if err != nil {
 		log.Error(context, "AddToGraph", err, "Completed")
-		return err
+		return nil, err
+	}
+
+	// Check the types of the related items.
+	quadParams, violations, err := checkTypes(context, db, inferred)
+	if err != nil {
+		log.Error(context, "AddToGraph", err, "Completed")
+		return nil, err
 	}
 
+	rejected := rejectedSkips(inferred, quadParams)
+	if err := requiredError(item, append(skips, rejected...)); err != nil {
+		log.Error(context, "AddToGraph", err, "Completed")
+		return nil, err
+	}
+
+	for _, v := range violations {
+		log.User(context, "AddToGraph", "Type violation : Policy[%s] %s", v.Policy, v)
+	}
+
+	report := newInferenceReport()
+	report.Skipped = append(report.Skipped, skips...)
+	report.Skipped = append(report.Skipped, rejected...)
+	report.Violations = violations
+
 	// Convert the given parameters into cayley quads.
 	tx := cayley.NewTransaction()
 	for _, params := range quadParams {
@@ -66,134 +104,318 @@ func AddToGraph(context interface{}, db *db.DB, store *cayley.Handle, item map[s
 		// Validate the parameters.
 		if err := params.Validate(); err != nil {
 			log.Error(context, "AddToGraph", err, "Completed")
-			return err
+			return nil, err
 		}
 
 		// Form the cayley quad.
 		quad := quad.Make(params.Subject, params.Predicate, params.Object, "")
 		tx.AddQuad(quad)
+
+		report.Added = append(report.Added, Edge{Subject: params.Subject, Predicate: params.Predicate, Object: params.Object})
 	}
 
-	// Apply the transaction.
-	if err := store.ApplyTransaction(tx); err != nil {
-		if !graph.IsQuadExist(err) {
-			log.Error(context, "AddToGraph", err, "Completed")
-			return err
-		}
+	// Find the items whose derived relationships may change, before and
+	// after the relationships are added.
+	rules, err := getRules(context, db)
+	if err != nil {
+		log.Error(context, "AddToGraph", err, "Completed")
+		return nil, err
 	}
 
-	log.Dev(context, "AddToGraph", "Completed")
-	return nil
+	touched := touchedIDs(item, quadParams)
+	starts := make(map[string]map[string]bool)
+	derivationStarts(starts, store, rules, touched)
+
+	// Apply the transaction. Quads already added, by another item holding
+	// the same relationship, don't keep the others from being added.
+	if _, err := applyEach(store, tx); err != nil {
+		log.Error(context, "AddToGraph", err, "Completed")
+		return nil, err
+	}
+
+	// Update the derived relationships.
+	derivationStarts(starts, store, rules, touched)
+	added, removed, err := updateDerived(store, rules, starts)
+	if err != nil {
+		log.Error(context, "AddToGraph", err, "Completed")
+		return nil, err
+	}
+	report.Added = append(report.Added, added...)
+	report.Removed = append(report.Removed, removed...)
+
+	// Record the metadata of the relationships.
+	itemID, _ := item["item_id"].(string)
+	version, _ := item["version"].(int)
+	if err := saveMeta(context, db, itemID, version, quadParams); err != nil {
+		log.Error(context, "AddToGraph", err, "Completed")
+		return nil, err
+	}
+
+	// Invalidate any materialized views touched by the relationships.
+	if err := InvalidateViews(context, db, append(touched, edgeIDs(added, removed)...)); err != nil {
+		log.Error(context, "AddToGraph", err, "Completed")
+		return nil, err
+	}
+
+	// Recompute the persistent views affected by the relationships.
+	if err := refreshPersistentViews(context, db, store, itemID, append(report.Added, report.Removed...)); err != nil {
+		log.Error(context, "AddToGraph", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "AddToGraph", "Completed : Added[%d] Skipped[%d] Violations[%d]", len(report.Added), len(report.Skipped), len(violations))
+	return report, nil
 }
 
-// RemoveFromGraph removes relationship quads from the cayley graph.
-func RemoveFromGraph(context interface{}, db *db.DB, store *cayley.Handle, item map[string]interface{}) error {
+// RemoveFromGraph removes relationship quads from the cayley graph. The
+// relationships removed are the ones recorded as inferred from the item when
+// it was added, so a relationship is removed even if the item would now infer
+// another one. The removed relationships are reported.
+func RemoveFromGraph(context interface{}, db *db.DB, store *cayley.Handle, item map[string]interface{}) (*InferenceReport, error) {
 	log.Dev(context, "RemoveFromGraph", "Started : %v", item)
 
-	// Infer the relationships in the item.
-	quadParams, err := inferRelationships(context, db, item)
+	// Get the relationships added for the item.
+	itemID, _ := item["item_id"].(string)
+	quadParams, err := itemQuads(context, db, item)
 	if err != nil {
-		log.Error(context, "AddToGraph", err, "Completed")
-		return err
+		log.Error(context, "RemoveFromGraph", err, "Completed")
+		return nil, err
 	}
 
+	report, err := removeQuads(context, db, store, itemID, quadParams)
+	if err != nil {
+		log.Error(context, "RemoveFromGraph", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "RemoveFromGraph", "Completed : Removed[%d]", len(report.Removed))
+	return report, nil
+}
+
+// removeQuads removes the quads of relationships from the graph along with
+// their metadata, and updates the derived relationships and the views
+// touched by them.
+func removeQuads(context interface{}, db *db.DB, store *cayley.Handle, itemID string, quadParams []QuadParam) (*InferenceReport, error) {
+	report := newInferenceReport()
+
 	// Convert the given parameters into cayley quads.
 	tx := cayley.NewTransaction()
 	for _, params := range quadParams {
 
 		// Validate the parameters.
 		if err := params.Validate(); err != nil {
-			log.Error(context, "RemoveFromGraph", err, "Completed")
-			return err
+			return nil, err
 		}
 
 		// Form the cayley quad.
 		quad := quad.Make(params.Subject, params.Predicate, params.Object, "")
 		tx.RemoveQuad(quad)
+
+		report.Removed = append(report.Removed, Edge{Subject: params.Subject, Predicate: params.Predicate, Object: params.Object})
+	}
+
+	// Find the items whose derived relationships may change, before and
+	// after the relationships are removed.
+	rules, err := getRules(context, db)
+	if err != nil {
+		return nil, err
+	}
+
+	touched := touchedIDs(map[string]interface{}{"item_id": itemID}, quadParams)
+	starts := make(map[string]map[string]bool)
+	derivationStarts(starts, store, rules, touched)
+
+	// Apply the transaction. Quads already removed don't keep the others
+	// from being removed.
+	if _, err := applyEach(store, tx); err != nil {
+		return nil, err
+	}
+
+	// Update the derived relationships.
+	derivationStarts(starts, store, rules, touched)
+	added, removed, err := updateDerived(store, rules, starts)
+	if err != nil {
+		return nil, err
+	}
+	report.Added = append(report.Added, added...)
+	report.Removed = append(report.Removed, removed...)
+
+	// Remove the metadata of the relationships.
+	if err := removeMeta(context, db, quadParams); err != nil {
+		return nil, err
+	}
+
+	// Invalidate any materialized views touched by the relationships.
+	if err := InvalidateViews(context, db, append(touched, edgeIDs(added, removed)...)); err != nil {
+		return nil, err
+	}
+
+	// Recompute the persistent views affected by the relationships.
+	if err := refreshPersistentViews(context, db, store, itemID, append(report.Added, report.Removed...)); err != nil {
+		return nil, err
 	}
 
-	// Apply the transaction.
-	if err := store.ApplyTransaction(tx); err != nil {
-		if !graph.IsQuadNotExist(err) {
-			log.Error(context, "RemoveFromGraph", err, "Completed")
-			return err
+	return report, nil
+}
+
+// itemQuads returns the relationships inferred from an item, as recorded in
+// their metadata when the item was added to the graph. The relationships of
+// an item added before the metadata recorded their item are inferred again.
+func itemQuads(context interface{}, db *db.DB, item map[string]interface{}) ([]QuadParam, error) {
+	itemID, _ := item["item_id"].(string)
+	if itemID != "" {
+		quadParams, err := itemMeta(context, db, itemID)
+		if err != nil {
+			return nil, err
+		}
+		if len(quadParams) > 0 {
+			return quadParams, nil
 		}
 	}
 
-	log.Dev(context, "RemoveFromGraph", "Completed")
-	return nil
+	return inferRelationships(context, db, item)
+}
+
+// touchedIDs returns the ID of the item along with the IDs of every item
+// on either end of its relationships.
+func touchedIDs(item map[string]interface{}, quadParams []QuadParam) []string {
+	var ids []string
+
+	if itemID, ok := item["item_id"].(string); ok && itemID != "" {
+		ids = append(ids, itemID)
+	}
+
+	for _, params := range quadParams {
+		ids = append(ids, params.Subject, params.Object)
+	}
+
+	return ids
+}
+
+// edgeIDs returns the IDs of the items on either end of the relationships.
+func edgeIDs(edgeSets ...[]Edge) []string {
+	var ids []string
+	for _, edges := range edgeSets {
+		for _, e := range edges {
+			ids = append(ids, e.Subject, e.Object)
+		}
+	}
+	return ids
 }
 
 // inferRelationships infers realtionships based on patterns corresponding to
 // a type of item.
 func inferRelationships(context interface{}, db *db.DB, itemIn map[string]interface{}) ([]QuadParam, error) {
+	qps, _, err := inferItem(context, db, itemIn)
+	return qps, err
+}
+
+// inferItem infers the relationships of an item based on the pattern of its
+// type, along with the inferences that didn't produce any.
+func inferItem(context interface{}, db *db.DB, itemIn map[string]interface{}) ([]QuadParam, []Skip, error) {
 
 	// Parse the item's type.
 	itemType, err := typeParse(itemIn)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Get the relevant pattern.
 	p, err := pattern.GetByType(context, db, itemType)
 	if err != nil {
 		if err != pattern.ErrNotFound {
-			return nil, err
+			return nil, nil, err
 		}
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	// Parse the item.
 	item, err := itemParse(itemIn, itemType)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Loop over inferences in the pattern.
 	var qps []QuadParam
+	var skips []Skip
 	for _, inf := range p.Inferences {
+		created := relTime(itemIn, inf.TimeField)
 
-		// Check for the relevant field in the item.
-		if relIDs, ok := item.itemData[inf.RelIDField]; ok {
+		skip := Skip{
+			Field:     inf.RelIDField,
+			Predicate: inf.Predicate,
+			Required:  inf.Required,
+		}
 
-			// If the rel field is empty, do not create the quad.
-			if relIDs == "" {
-				continue
+		if inf.Direction != inString && inf.Direction != outString {
+			skip.Reason = SkipDirection
+			skips = append(skips, skip)
+			continue
+		}
+
+		// Extract the IDs from the text of the field or check for the
+		// relevant field in the item. Arrays of values may be referenced
+		// with or without brackets.
+		var relIDs []string
+		if inf.Regex != "" {
+			relIDs, skip.Reason, err = extractIDs(context, db, &inf, item.data)
+			if err != nil {
+				return nil, nil, err
 			}
+		} else {
+			var ok bool
+			if relIDs, ok = item.itemData[strings.TrimSuffix(inf.RelIDField, "[]")]; !ok {
+				skip.Reason = SkipNoValue
+			}
+		}
 
-			// Split the IDs to infer multiple relationships, if present.
-			splitRelIDs := strings.Split(relIDs, ",")
-
-			// Add the appropriate relationship for each ID.
-			for _, relID := range splitRelIDs {
-
-				// If we are using source ids and rel types, compose the id.
-				if inf.RelType != "" {
-					relID = fmt.Sprintf("%s_%v", inf.RelType, relID)
-				}
-
-				// Add the relationship parameters.
-				switch inf.Direction {
-				case inString:
-					qp := QuadParam{
-						Subject:   relID,
-						Predicate: inf.Predicate,
-						Object:    item.itemID,
-					}
-					qps = append(qps, qp)
-				case outString:
-					qp := QuadParam{
-						Subject:   item.itemID,
-						Predicate: inf.Predicate,
-						Object:    relID,
-					}
-					qps = append(qps, qp)
-				}
+		if skip.Reason != "" {
+			skips = append(skips, skip)
+			continue
+		}
+
+		// Items found by a lookup are related by their own IDs and type.
+		relType := inf.RelType
+		if inf.LookupType != "" {
+			relType = inf.LookupType
+		}
+
+		// Add the appropriate relationship for each ID.
+		for _, relID := range relIDs {
+
+			// If we are using source ids and rel types, compose the id.
+			if inf.RelType != "" && inf.LookupType == "" {
+				relID = fmt.Sprintf("%s_%v", inf.RelType, relID)
+			}
+
+			qp := QuadParam{
+				Predicate: inf.Predicate,
+				Label:     inf.Label,
+				Time:      created,
+				field:     inf.RelIDField,
+				related:   relID,
+				required:  inf.Required,
+			}
+
+			// Add the relationship parameters.
+			switch inf.Direction {
+			case inString:
+				qp.Subject = relID
+				qp.Object = item.itemID
+				qp.subjectType = relType
+				qp.objectType = itemType
+			case outString:
+				qp.Subject = item.itemID
+				qp.Object = relID
+				qp.subjectType = itemType
+				qp.objectType = relType
 			}
+
+			qps = append(qps, qp)
 		}
 	}
 
-	return qps, nil
+	return qps, skips, nil
 }
 
 // typeParse parses the type from the input item map.
@@ -221,7 +443,8 @@ func typeParse(itemIn map[string]interface{}) (string, error) {
 type parsedItem struct {
 	itemID   string
 	itemType string
-	itemData map[string]string
+	itemData map[string][]string
+	data     map[string]interface{}
 }
 
 // itemParse parses a general map[string]interface{} into a parsedItem value,
@@ -254,36 +477,8 @@ func itemParse(itemIn map[string]interface{}, itemType string) (parsedItem, erro
 		return parsedItem{}, ErrItemData
 	}
 
-	itemData := make(map[string]string)
-	for k, v := range dataMap {
-
-		var vString string
-		vString, ok := v.(string)
-		if !ok {
-
-			vs, ok := v.([]interface{})
-			if !ok {
-				continue
-			}
-
-			var vStrings []string
-			for _, vid := range vs {
-				val, ok := vid.(string)
-				if !ok {
-					continue
-				}
-				vStrings = append(vStrings, val)
-			}
-
-			if len(vStrings) > 0 {
-				vString = strings.Join(vStrings, ",")
-			}
-		}
-		if vString == "" {
-			continue
-		}
-		itemData[k] = vString
-	}
+	itemData := make(map[string][]string)
+	flattenData("", dataMap, itemData)
 
 	if len(itemData) == 0 {
 		return parsedItem{}, ErrItemData
@@ -294,6 +489,59 @@ func itemParse(itemIn map[string]interface{}, itemType string) (parsedItem, erro
 		itemID:   itemID,
 		itemType: itemType,
 		itemData: itemData,
+		data:     dataMap,
 	}
 	return itemOut, nil
 }
+
+// flattenData collects the values of the data of an item by path. Nested
+// documents add their field names to the path after a dot, and the documents
+// of an array add them after brackets. Strings holding comma separated values
+// and arrays yield one value per element, and numbers are formatted the same
+// way InferIDFromData formats source IDs.
+func flattenData(path string, v interface{}, out map[string][]string) {
+	switch v := v.(type) {
+	case string:
+		for _, s := range strings.Split(v, ",") {
+			if s != "" {
+				out[path] = append(out[path], s)
+			}
+		}
+
+	case int, int32, int64, float32, float64:
+		out[path] = append(out[path], fmt.Sprintf("%v", v))
+
+	case map[string]interface{}:
+		for k, fv := range v {
+			flattenData(joinPath(path, k), fv, out)
+		}
+
+	case bson.M:
+		flattenData(path, map[string]interface{}(v), out)
+
+	case []string:
+		for _, e := range v {
+			flattenData(path, e, out)
+		}
+
+	case []interface{}:
+		for _, e := range v {
+			switch e.(type) {
+			case map[string]interface{}, bson.M:
+				flattenData(path+"[]", e, out)
+			case []interface{}:
+				continue
+			default:
+				flattenData(path, e, out)
+			}
+		}
+	}
+}
+
+// joinPath adds the name of a field to a path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -8,6 +8,9 @@ import (
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/coralproject/shelf/internal/wire"
+	"github.com/coralproject/shelf/internal/wire/pattern"
+	"github.com/coralproject/shelf/internal/wire/pattern/patternfix"
+	"github.com/coralproject/shelf/internal/wire/relationship"
 	"github.com/coralproject/shelf/internal/wire/wirefix"
 )
 
@@ -60,7 +63,7 @@ func TestAddRemoveGraph(t *testing.T) {
 			//----------------------------------------------------------------------
 			// Infer and add the relationships to the graph.
 
-			if err := wire.AddToGraph(tests.Context, db, store, items[0]); err != nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, items[0]); err != nil {
 				t.Fatalf("\t%s\tShould be able to add relationships to the graph : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to add relationships to the graph.", tests.Success)
@@ -110,7 +113,7 @@ func TestAddRemoveGraph(t *testing.T) {
 			//----------------------------------------------------------------------
 			// Try to infer and add the relationships again.
 
-			if err := wire.AddToGraph(tests.Context, db, store, items[0]); err != nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, items[0]); err != nil {
 				t.Fatalf("\t%s\tShould be able to add an item again and maintain relationships : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to add an item again and maintain relationships.", tests.Success)
@@ -118,7 +121,7 @@ func TestAddRemoveGraph(t *testing.T) {
 			//----------------------------------------------------------------------
 			// Remove the relationships from the graph.
 
-			if err := wire.RemoveFromGraph(tests.Context, db, store, items[0]); err != nil {
+			if _, err := wire.RemoveFromGraph(tests.Context, db, store, items[0]); err != nil {
 				t.Fatalf("\t%s\tShould be able to remove relationships from the graph : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to remove relationships from the graph.", tests.Success)
@@ -181,7 +184,7 @@ func TestAddToGraphFail(t *testing.T) {
 				},
 			}
 
-			if err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
 				t.Fatalf("\t%s\tShould be able to catch invalid inferred relationships : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to catch invalid inferred relationships.", tests.Success)
@@ -200,7 +203,7 @@ func TestAddToGraphFail(t *testing.T) {
 				},
 			}
 
-			if err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
 				t.Fatalf("\t%s\tShould be able to catch invalid item type : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to catch invalid item type.", tests.Success)
@@ -219,7 +222,7 @@ func TestAddToGraphFail(t *testing.T) {
 				},
 			}
 
-			if err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
 				t.Fatalf("\t%s\tShould be able to catch invalid item type : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to catch invalid item type.", tests.Success)
@@ -237,7 +240,7 @@ func TestAddToGraphFail(t *testing.T) {
 				},
 			}
 
-			if err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
 				t.Fatalf("\t%s\tShould be able to catch missing item type : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to catch missing item type.", tests.Success)
@@ -256,7 +259,7 @@ func TestAddToGraphFail(t *testing.T) {
 				},
 			}
 
-			if err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
 				t.Fatalf("\t%s\tShould be able to catch invalid item ID : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to catch invalid item ID.", tests.Success)
@@ -275,7 +278,7 @@ func TestAddToGraphFail(t *testing.T) {
 				},
 			}
 
-			if err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
 				t.Fatalf("\t%s\tShould be able to catch invalid item ID : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to catch invalid item ID.", tests.Success)
@@ -293,7 +296,7 @@ func TestAddToGraphFail(t *testing.T) {
 				},
 			}
 
-			if err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
 				t.Fatalf("\t%s\tShould be able to catch missing item ID : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to catch missing item ID.", tests.Success)
@@ -308,7 +311,7 @@ func TestAddToGraphFail(t *testing.T) {
 				"data":    2,
 			}
 
-			if err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
 				t.Fatalf("\t%s\tShould be able to catch invalid item data : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to catch invalid item data.", tests.Success)
@@ -322,7 +325,7 @@ func TestAddToGraphFail(t *testing.T) {
 				"version": 2,
 			}
 
-			if err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
 				t.Fatalf("\t%s\tShould be able to catch invalid item data : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to catch invalid item data.", tests.Success)
@@ -340,7 +343,7 @@ func TestAddToGraphFail(t *testing.T) {
 				},
 			}
 
-			if err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
 				t.Fatalf("\t%s\tShould be able to catch invalid item data : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to catch invalid item data.", tests.Success)
@@ -355,7 +358,7 @@ func TestAddToGraphFail(t *testing.T) {
 				"data":    map[string]interface{}{},
 			}
 
-			if err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
+			if _, err := wire.AddToGraph(tests.Context, db, store, itMap); err == nil {
 				t.Fatalf("\t%s\tShould be able to catch invalid item data : %s", tests.Failed, err)
 			}
 			t.Logf("\t%s\tShould be able to catch invalid item data.", tests.Success)
@@ -363,3 +366,246 @@ func TestAddToGraphFail(t *testing.T) {
 		}
 	}
 }
+
+// TestAddToGraphTypes tests the policies for relationships between items of
+// types not declared by the relationship.
+func TestAddToGraphTypes(t *testing.T) {
+	db, store, _ := setupGraph(t)
+	defer tests.DisplayLog()
+	defer wire.SetTypeCheck(relationship.TypeCheckWarn)
+
+	// The comment is on a user rather than an asset.
+	itMap := map[string]interface{}{
+		"item_id": "WTEST_3c1a4aa7-4a6a-4d62-b2a3-a8e8b4a8f2c1",
+		"type":    "WTEST_comment",
+		"version": 1,
+		"data": map[string]interface{}{
+			"author": "WTEST_80aa936a-f618-4234-a7be-df59a14cf8de",
+			"asset":  "WTEST_80aa936a-f618-4234-a7be-df59a14cf8de",
+		},
+	}
+
+	on := func() int {
+		p := cayley.StartPath(store, quad.String("WTEST_3c1a4aa7-4a6a-4d62-b2a3-a8e8b4a8f2c1")).Out(quad.String("WTEST_on"))
+		it, _ := p.BuildIterator().Optimize()
+		defer it.Close()
+
+		var count int
+		for it.Next() {
+			count++
+		}
+		return count
+	}
+
+	t.Log("Given the need to check the types of related items.")
+	{
+		t.Log("\tWhen relating a comment to a user with the warn policy")
+		{
+			report, err := wire.AddToGraph(tests.Context, db, store, itMap)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to add relationships to the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to add relationships to the graph.", tests.Success)
+
+			if len(report.Violations) != 1 || report.Violations[0].Predicate != "WTEST_on" || report.Violations[0].ObjectType != "WTEST_user" {
+				t.Fatalf("\t%s\tShould report the violation : %+v", tests.Failed, report.Violations)
+			}
+			t.Logf("\t%s\tShould report the violation.", tests.Success)
+
+			if on() != 1 {
+				t.Fatalf("\t%s\tShould add the relationship", tests.Failed)
+			}
+			t.Logf("\t%s\tShould add the relationship.", tests.Success)
+
+			if _, err := wire.RemoveFromGraph(tests.Context, db, store, itMap); err != nil {
+				t.Fatalf("\t%s\tShould be able to remove relationships from the graph : %s", tests.Failed, err)
+			}
+		}
+
+		t.Log("\tWhen relating a comment to a user with the reject policy")
+		{
+			if err := wire.SetTypeCheck(relationship.TypeCheckReject); err != nil {
+				t.Fatalf("\t%s\tShould be able to set the policy : %s", tests.Failed, err)
+			}
+
+			report, err := wire.AddToGraph(tests.Context, db, store, itMap)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to add relationships to the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to add relationships to the graph.", tests.Success)
+
+			if len(report.Violations) != 1 || report.Violations[0].Policy != relationship.TypeCheckReject {
+				t.Fatalf("\t%s\tShould report the violation : %+v", tests.Failed, report.Violations)
+			}
+			t.Logf("\t%s\tShould report the violation.", tests.Success)
+
+			var rejected bool
+			for _, s := range report.Skipped {
+				if s.Field == "asset" && s.Reason == wire.SkipRejected {
+					rejected = true
+				}
+			}
+			if !rejected {
+				t.Fatalf("\t%s\tShould report the rejected inference as skipped : %+v", tests.Failed, report.Skipped)
+			}
+			t.Logf("\t%s\tShould report the rejected inference as skipped.", tests.Success)
+
+			if on() != 0 {
+				t.Fatalf("\t%s\tShould not add the relationship", tests.Failed)
+			}
+			t.Logf("\t%s\tShould not add the relationship.", tests.Success)
+
+			if _, err := wire.RemoveFromGraph(tests.Context, db, store, itMap); err != nil {
+				t.Fatalf("\t%s\tShould be able to remove relationships from the graph : %s", tests.Failed, err)
+			}
+		}
+	}
+}
+
+// TestAddToGraphPaths tests inferring relationships from nested fields,
+// numeric IDs and arrays of documents.
+func TestAddToGraphPaths(t *testing.T) {
+	db, store, _ := setupGraph(t)
+	defer tests.DisplayLog()
+
+	patterns, items, err := patternfix.Get()
+	if err != nil {
+		t.Fatalf("%s\tShould load pattern records from the fixture file : %v", tests.Failed, err)
+	}
+
+	const itemType = "PTEST_imported_comment"
+
+	var itm map[string]interface{}
+	for i, pat := range patterns {
+		if pat.Type != itemType {
+			continue
+		}
+
+		if err := patternfix.Add(tests.Context, db, patterns[i:i+1]); err != nil {
+			t.Fatalf("%s\tShould be able to add the pattern : %v", tests.Failed, err)
+		}
+		defer pattern.Delete(tests.Context, db, itemType)
+	}
+
+	for _, it := range items {
+		if it["type"] == itemType {
+			itm = it
+		}
+	}
+
+	t.Log("Given the need to infer relationships from the paths of an item's data.")
+	{
+		t.Log("\tWhen starting from an empty graph")
+		{
+			if _, err := wire.AddToGraph(tests.Context, db, store, itm); err != nil {
+				t.Fatalf("\t%s\tShould be able to add relationships to the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to add relationships to the graph.", tests.Success)
+			defer wire.RemoveFromGraph(tests.Context, db, store, itm)
+
+			id := itm["item_id"].(string)
+			expected := map[wire.Edge]bool{
+				{Subject: "80aa936a-f618-4234-a7be-df59a14cf8de", Predicate: "RTEST_authored", Object: id}: true,
+				{Subject: id, Predicate: "RTEST_on", Object: "PTEST_asset_123"}:                            true,
+				{Subject: id, Predicate: "RTEST_mentions", Object: "a63af637-58af-472b-98c7-f5c00743bac6"}: true,
+				{Subject: id, Predicate: "RTEST_mentions", Object: "456"}:                                  true,
+			}
+
+			var count int
+			it := store.QuadsAllIterator()
+			for it.Next() {
+				q := store.Quad(it.Result())
+				e := wire.Edge{
+					Subject:   quad.NativeOf(q.Subject).(string),
+					Predicate: quad.NativeOf(q.Predicate).(string),
+					Object:    quad.NativeOf(q.Object).(string),
+				}
+				if !expected[e] {
+					t.Fatalf("\t%s\tShould only infer the expected relationships : %+v", tests.Failed, e)
+				}
+				count++
+			}
+			it.Close()
+
+			if count != len(expected) {
+				t.Fatalf("\t%s\tShould infer %d relationships : %d", tests.Failed, len(expected), count)
+			}
+			t.Logf("\t%s\tShould infer a relationship for each nested, numeric and array field.", tests.Success)
+		}
+	}
+}
+
+// TestAddToGraphRequired tests that items missing the fields of required
+// inferences are not added.
+func TestAddToGraphRequired(t *testing.T) {
+	db, store, _ := setupGraph(t)
+	defer tests.DisplayLog()
+
+	// The comment has an author but is not on an asset.
+	itMap := map[string]interface{}{
+		"item_id": "WTEST_5b0c3f2e-7d8a-4e1b-9f65-2c4d1e8a7b90",
+		"type":    "WTEST_comment",
+		"version": 1,
+		"data": map[string]interface{}{
+			"author": "WTEST_80aa936a-f618-4234-a7be-df59a14cf8de",
+		},
+	}
+
+	t.Log("Given the need to enforce required inferences.")
+	{
+		t.Log("\tWhen adding a comment without an asset")
+		{
+			_, err := wire.AddToGraph(tests.Context, db, store, itMap)
+			reqErr, ok := err.(*wire.RequiredError)
+			if !ok {
+				t.Fatalf("\t%s\tShould fail with a required error : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould fail with a required error.", tests.Success)
+
+			if len(reqErr.Fields) != 1 || reqErr.Fields[0] != "asset" {
+				t.Fatalf("\t%s\tShould report the missing field : %v", tests.Failed, reqErr.Fields)
+			}
+			t.Logf("\t%s\tShould report the missing field.", tests.Success)
+
+			if err := wire.CheckRequired(tests.Context, db, itMap); err == nil {
+				t.Fatalf("\t%s\tShould fail the check of required fields.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould fail the check of required fields.", tests.Success)
+
+			it := store.QuadsAllIterator()
+			defer it.Close()
+			if it.Next() {
+				t.Fatalf("\t%s\tShould not add any relationship.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould not add any relationship.", tests.Success)
+		}
+
+		t.Log("\tWhen adding a comment with an asset but no parent")
+		{
+			itMap["data"].(map[string]interface{})["asset"] = "WTEST_c1b2bbfe-af9f-4903-8777-bd47c4d5b20a"
+
+			report, err := wire.AddToGraph(tests.Context, db, store, itMap)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to add relationships to the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to add relationships to the graph.", tests.Success)
+			defer wire.RemoveFromGraph(tests.Context, db, store, itMap)
+
+			if len(report.Added) != 2 {
+				t.Fatalf("\t%s\tShould report 2 added relationships : %+v", tests.Failed, report.Added)
+			}
+			t.Logf("\t%s\tShould report 2 added relationships.", tests.Success)
+
+			var skipped bool
+			for _, s := range report.Skipped {
+				if s.Field == "parent" && !s.Required && s.Reason == wire.SkipNoValue {
+					skipped = true
+				}
+			}
+			if !skipped {
+				t.Fatalf("\t%s\tShould report the optional parent as skipped : %+v", tests.Failed, report.Skipped)
+			}
+			t.Logf("\t%s\tShould report the optional parent as skipped.", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package wire
+
+// itemRoots holds the item keys a view started from that reached each item.
+type itemRoots map[string][]string
+
+// rootKeys returns the item keys the view starts from.
+func (vp *ViewParams) rootKeys() []string {
+	if len(vp.ItemKeys) == 0 {
+		return []string{vp.ItemKey}
+	}
+
+	// Start from each distinct key once.
+	seen := make(map[string]bool)
+	var keys []string
+	for _, key := range vp.ItemKeys {
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// add records the key as a root of the item IDs. It does nothing when the
+// view starts from a single item key.
+func (r itemRoots) add(key string, ids []string) {
+	if r == nil {
+		return
+	}
+
+	for _, id := range ids {
+		r[id] = append(r[id], key)
+	}
+}
+
+// distinct returns the IDs without duplicates, in the order they are first
+// found.
+func distinct(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
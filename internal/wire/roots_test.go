@@ -0,0 +1,55 @@
+package wire_test
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/wire"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// TestExecuteRoots tests executing a view from several items.
+func TestExecuteRoots(t *testing.T) {
+	db, store := setup(t)
+	defer teardown(t, db, store)
+
+	bilbo := wirePrefix + "80aa936a-f618-4234-a7be-df59a14cf8de"
+	gandalf := wirePrefix + "a63af637-58af-472b-98c7-f5c00743bac6"
+
+	expected := map[string]string{
+		wirePrefix + "d1dfa366-d2f7-4a4a-a64f-af89d4c97d82": bilbo,
+		wirePrefix + "6eaaa19f-da7a-4095-bbe3-cee7a7631dd4": bilbo,
+		wirePrefix + "d16790f8-13e9-4cb4-b9ef-d82835589660": gandalf,
+	}
+
+	t.Log("Given the need to execute a view from several items.")
+	{
+		t.Log("\tWhen starting from two users, one of them twice")
+		{
+			viewParams := wire.ViewParams{
+				ViewName: wirePrefix + "user comments",
+				ItemKeys: []string{bilbo, gandalf, bilbo},
+			}
+
+			result, err := wire.Execute(tests.Context, db, store, &viewParams)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to execute the view : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to execute the view.", tests.Success)
+
+			items, ok := result.Results.([]bson.M)
+			if !ok || len(items) != len(expected) {
+				t.Fatalf("\t%s\tShould get %d distinct comments : %+v", tests.Failed, len(expected), result.Results)
+			}
+			t.Logf("\t%s\tShould get %d distinct comments.", tests.Success, len(expected))
+
+			for _, itm := range items {
+				roots, _ := itm["roots"].([]string)
+				if len(roots) != 1 || roots[0] != expected[itm["item_id"].(string)] {
+					t.Fatalf("\t%s\tShould annotate each comment with its author : %v", tests.Failed, itm)
+				}
+			}
+			t.Logf("\t%s\tShould annotate each comment with its author.", tests.Success)
+		}
+	}
+}
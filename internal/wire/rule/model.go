@@ -0,0 +1,54 @@
+package rule
+
+import (
+	"fmt"
+
+	validator "gopkg.in/bluesuncorp/validator.v8"
+)
+
+//==============================================================================
+
+// validate is used to perform model field validation.
+var validate *validator.Validate
+
+func init() {
+	validate = validator.New(&validator.Config{TagName: "validate"})
+}
+
+//==============================================================================
+
+// Step is a relationship followed by a rule, from its subject to its object
+// when the direction is out and from its object to its subject when in.
+type Step struct {
+	Predicate string `bson:"predicate" json:"predicate" validate:"required,min=2"`
+	Direction string `bson:"direction" json:"direction" validate:"required,min=2"`
+}
+
+// Rule derives a relationship with Predicate from every item to the items
+// reached by following its chain of relationships. Note, name should be
+// unique. The chain only follows relationships inferred from items, not
+// the ones derived by rules.
+type Rule struct {
+	Name      string `bson:"name" json:"name" validate:"required,min=2"`
+	Chain     []Step `bson:"chain" json:"chain" validate:"required,min=1"`
+	Predicate string `bson:"predicate" json:"predicate" validate:"required,min=2"`
+}
+
+// Validate checks the Rule value for consistency.
+func (r *Rule) Validate() error {
+	if err := validate.Struct(r); err != nil {
+		return err
+	}
+
+	for _, s := range r.Chain {
+		if err := validate.Struct(s); err != nil {
+			return err
+		}
+
+		if s.Direction != "in" && s.Direction != "out" {
+			return fmt.Errorf("Invalid direction %q for predicate %q", s.Direction, s.Predicate)
+		}
+	}
+
+	return nil
+}
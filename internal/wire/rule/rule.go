@@ -0,0 +1,112 @@
+// Package rule provides support for the rules deriving relationships from
+// chains of relationships in the graph.
+package rule
+
+import (
+	"errors"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Collection is the Mongo collection containing rules.
+const Collection = "rules"
+
+// ErrNotFound is an error variable thrown when no results are returned from a Mongo query.
+var ErrNotFound = errors.New("Rule Not found")
+
+// Upsert upserts a rule to the collection of currently utilized rules.
+func Upsert(context interface{}, db *db.DB, rule *Rule) error {
+	log.Dev(context, "Upsert", "Started : Name[%s]", rule.Name)
+
+	// Validate the rule.
+	if err := rule.Validate(); err != nil {
+		log.Error(context, "Upsert", err, "Completed")
+		return err
+	}
+
+	// Upsert the rule.
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"name": rule.Name}
+		log.Dev(context, "Upsert", "MGO : db.%s.upsert(%s, %s)", c.Name, mongo.Query(q), mongo.Query(rule))
+		_, err := c.Upsert(q, rule)
+		return err
+	}
+	if err := db.ExecuteMGO(context, Collection, f); err != nil {
+		log.Error(context, "Upsert", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "Upsert", "Completed")
+	return nil
+}
+
+// GetAll retrieves the current rules from Mongo.
+func GetAll(context interface{}, db *db.DB) ([]Rule, error) {
+	log.Dev(context, "GetAll", "Started")
+
+	// Get the rules from Mongo.
+	var rules []Rule
+	f := func(c *mgo.Collection) error {
+		log.Dev(context, "Find", "MGO : db.%s.find()", c.Name)
+		return c.Find(nil).All(&rules)
+	}
+	if err := db.ExecuteMGO(context, Collection, f); err != nil {
+		if err == mgo.ErrNotFound {
+			err = ErrNotFound
+		}
+		log.Error(context, "GetAll", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "GetAll", "Completed : Rules[%d]", len(rules))
+	return rules, nil
+}
+
+// GetByName retrieves a rule by name from Mongo.
+func GetByName(context interface{}, db *db.DB, name string) (*Rule, error) {
+	log.Dev(context, "GetByName", "Started : Name[%s]", name)
+
+	// Get the rule from Mongo.
+	var rule Rule
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"name": name}
+		log.Dev(context, "Find", "MGO : db.%s.find(%s)", c.Name, mongo.Query(q))
+		return c.Find(q).One(&rule)
+	}
+	if err := db.ExecuteMGO(context, Collection, f); err != nil {
+		if err == mgo.ErrNotFound {
+			err = ErrNotFound
+		}
+		log.Error(context, "GetByName", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "GetByName", "Completed")
+	return &rule, nil
+}
+
+// Delete removes a rule from Mongo.
+func Delete(context interface{}, db *db.DB, name string) error {
+	log.Dev(context, "Delete", "Started : Name[%s]", name)
+
+	// Remove the rule.
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"name": name}
+		log.Dev(context, "Remove", "MGO : db.%s.remove(%s)", c.Name, mongo.Query(q))
+		return c.Remove(q)
+	}
+	if err := db.ExecuteMGO(context, Collection, f); err != nil {
+		if err == mgo.ErrNotFound {
+			err = ErrNotFound
+		}
+		log.Error(context, "Delete", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "Delete", "Completed")
+	return nil
+}
@@ -0,0 +1,111 @@
+package rule_test
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ardanlabs/kit/cfg"
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/wire/rule"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(runTest(m))
+}
+
+// runTest initializes the environment for the tests and allows for
+// the proper return code if the test fails or succeeds.
+func runTest(m *testing.M) int {
+
+	// Initialize the configuration and logging systems. Plus anything
+	// else the web app layer needs.
+	tests.Init("XENIA")
+
+	// Initialize MongoDB using the `tests.TestSession` as the name of the
+	// master session.
+	if err := db.RegMasterSession(tests.Context, tests.TestSession, cfg.MustURL("MONGO_URI").String(), 0); err != nil {
+		fmt.Println("Can't register master session: " + err.Error())
+		return 1
+	}
+
+	return m.Run()
+}
+
+//==============================================================================
+
+// TestUpsertDelete tests if we can add/remove a rule to/from the db.
+func TestUpsertDelete(t *testing.T) {
+	tests.ResetLog()
+	defer tests.DisplayLog()
+
+	db, err := db.NewMGO(tests.Context, tests.TestSession)
+	if err != nil {
+		t.Fatalf("%s\tShould be able to get a Mongo session : %v", tests.Failed, err)
+	}
+	defer db.CloseMGO(tests.Context)
+
+	rl := rule.Rule{
+		Name: "RLTEST_participated",
+		Chain: []rule.Step{
+			{Predicate: "RLTEST_authored", Direction: "out"},
+			{Predicate: "RLTEST_on", Direction: "out"},
+		},
+		Predicate: "RLTEST_participated_in",
+	}
+
+	t.Log("Given the need to upsert and delete rules.")
+	{
+		t.Log("\tWhen starting from an empty rules collection")
+		{
+
+			//----------------------------------------------------------------------
+			// Upsert the rule.
+
+			if err := rule.Upsert(tests.Context, db, &rl); err != nil {
+				t.Fatalf("\t%s\tShould be able to upsert a rule : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to upsert a rule.", tests.Success)
+
+			//----------------------------------------------------------------------
+			// Get the rule.
+
+			back, err := rule.GetByName(tests.Context, db, rl.Name)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to get the rule by name : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to get the rule by name.", tests.Success)
+
+			if !reflect.DeepEqual(rl, *back) {
+				t.Logf("\t%+v", rl)
+				t.Logf("\t%+v", back)
+				t.Fatalf("\t%s\tShould be able to get back the same rule.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould be able to get back the same rule.", tests.Success)
+
+			//----------------------------------------------------------------------
+			// Delete the rule.
+
+			if err := rule.Delete(tests.Context, db, rl.Name); err != nil {
+				t.Fatalf("\t%s\tShould be able to delete the rule : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to delete the rule.", tests.Success)
+
+			if _, err := rule.GetByName(tests.Context, db, rl.Name); err != rule.ErrNotFound {
+				t.Fatalf("\t%s\tShould not find the deleted rule : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould not find the deleted rule.", tests.Success)
+		}
+
+		t.Log("\tWhen a step of the chain has an invalid direction")
+		{
+			rl.Chain[1].Direction = "sideways"
+			if err := rule.Upsert(tests.Context, db, &rl); err == nil {
+				t.Fatalf("\t%s\tShould not be able to upsert the rule.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould not be able to upsert the rule.", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package wire
+
+import (
+	"sort"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/cayleygraph/cayley"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/sponge/item"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// DefaultStatsTop is the number of items with the most relationships
+// reported when no number is given.
+const DefaultStatsTop = 10
+
+// PredicateCount is the number of relationships with a predicate.
+type PredicateCount struct {
+	Predicate string `json:"predicate"`
+	Count     int    `json:"count"`
+}
+
+// TypeCount is the number of items of a type, and how many of them have no
+// relationships.
+type TypeCount struct {
+	Type    string `json:"type"`
+	Items   int    `json:"items"`
+	Orphans int    `json:"orphans"`
+}
+
+// TripleCount is the number of relationships with a predicate between items
+// of a subject type and an object type. An empty type is used for the items
+// missing from the items collection.
+type TripleCount struct {
+	SubjectType string `json:"subject_type"`
+	Predicate   string `json:"predicate"`
+	ObjectType  string `json:"object_type"`
+	Count       int    `json:"count"`
+}
+
+// Degree is the number of relationships of an item.
+type Degree struct {
+	ItemID string `json:"item_id"`
+	Type   string `json:"type"`
+	In     int    `json:"in"`
+	Out    int    `json:"out"`
+	Total  int    `json:"total"`
+}
+
+// GraphStats describes the relationships in the graph and the items they
+// relate. The quads derived by rules are only counted in Derived.
+type GraphStats struct {
+	Quads      int              `json:"quads"`
+	Derived    int              `json:"derived"`
+	Items      int              `json:"items"`
+	Orphans    int              `json:"orphans"`
+	Predicates []PredicateCount `json:"predicates"`
+	Types      []TypeCount      `json:"types"`
+	Triples    []TripleCount    `json:"triples"`
+	TopDegrees []Degree         `json:"top_degrees"`
+}
+
+// Stats counts the relationships in the graph per predicate and per triple of
+// subject type, predicate and object type, the items per type along with the
+// orphans having no relationships, and reports the top items with the most
+// relationships. The quads derived by rules are counted apart and left out of
+// the other counts. Every item and quad is walked and counted in memory, so
+// the call is costly on a large graph.
+func Stats(context interface{}, db *db.DB, store *cayley.Handle, top int) (*GraphStats, error) {
+	log.Dev(context, "Stats", "Started : Top[%d]", top)
+
+	if top <= 0 {
+		top = DefaultStatsTop
+	}
+
+	// Get the type of every item.
+	types := make(map[string]string)
+	f := func(c *mgo.Collection) error {
+		log.Dev(context, "Stats", "MGO : db.%s.find({}, {item_id: 1, type: 1})", c.Name)
+		it := c.Find(nil).Select(bson.M{"item_id": 1, "type": 1}).Iter()
+
+		var itm item.Item
+		for it.Next(&itm) {
+			types[itm.ID] = itm.Type
+		}
+		return it.Close()
+	}
+
+	if err := db.ExecuteMGO(context, item.Collection, f); err != nil {
+		log.Error(context, "Stats", err, "Completed")
+		return nil, err
+	}
+
+	// Count the relationships.
+	s := GraphStats{Items: len(types)}
+	predicates := make(map[string]int)
+	triples := make(map[TripleCount]int)
+	degrees := make(map[string]*Degree)
+
+	degree := func(id string) *Degree {
+		d, ok := degrees[id]
+		if !ok {
+			d = &Degree{ItemID: id, Type: types[id]}
+			degrees[id] = d
+		}
+		return d
+	}
+
+	it := store.QuadsAllIterator()
+	for it.Next() {
+		q := store.Quad(it.Result())
+
+		// Derived quads are maintained by DeriveGraph.
+		if isDerived(q) {
+			s.Derived++
+			continue
+		}
+
+		sub := nativeString(q.Subject)
+		pred := nativeString(q.Predicate)
+		obj := nativeString(q.Object)
+
+		s.Quads++
+		predicates[pred]++
+		triples[TripleCount{SubjectType: types[sub], Predicate: pred, ObjectType: types[obj]}]++
+
+		degree(sub).Out++
+		degree(obj).In++
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil {
+		log.Error(context, "Stats", err, "Completed")
+		return nil, err
+	}
+
+	s.Predicates = make([]PredicateCount, 0, len(predicates))
+	for p, n := range predicates {
+		s.Predicates = append(s.Predicates, PredicateCount{Predicate: p, Count: n})
+	}
+	sort.Slice(s.Predicates, func(i, j int) bool {
+		if s.Predicates[i].Count != s.Predicates[j].Count {
+			return s.Predicates[i].Count > s.Predicates[j].Count
+		}
+		return s.Predicates[i].Predicate < s.Predicates[j].Predicate
+	})
+
+	s.Triples = make([]TripleCount, 0, len(triples))
+	for t, n := range triples {
+		t.Count = n
+		s.Triples = append(s.Triples, t)
+	}
+	sort.Slice(s.Triples, func(i, j int) bool {
+		a, b := s.Triples[i], s.Triples[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Predicate != b.Predicate {
+			return a.Predicate < b.Predicate
+		}
+		if a.SubjectType != b.SubjectType {
+			return a.SubjectType < b.SubjectType
+		}
+		return a.ObjectType < b.ObjectType
+	})
+
+	// Count the items and orphans per type.
+	counts := make(map[string]*TypeCount)
+	for id, t := range types {
+		tc, ok := counts[t]
+		if !ok {
+			tc = &TypeCount{Type: t}
+			counts[t] = tc
+		}
+
+		tc.Items++
+		if _, ok := degrees[id]; !ok {
+			tc.Orphans++
+			s.Orphans++
+		}
+	}
+
+	s.Types = make([]TypeCount, 0, len(counts))
+	for _, tc := range counts {
+		s.Types = append(s.Types, *tc)
+	}
+	sort.Slice(s.Types, func(i, j int) bool {
+		if s.Types[i].Items != s.Types[j].Items {
+			return s.Types[i].Items > s.Types[j].Items
+		}
+		return s.Types[i].Type < s.Types[j].Type
+	})
+
+	// Keep the items with the most relationships.
+	s.TopDegrees = make([]Degree, 0, len(degrees))
+	for _, d := range degrees {
+		d.Total = d.In + d.Out
+		s.TopDegrees = append(s.TopDegrees, *d)
+	}
+	sort.Slice(s.TopDegrees, func(i, j int) bool {
+		if s.TopDegrees[i].Total != s.TopDegrees[j].Total {
+			return s.TopDegrees[i].Total > s.TopDegrees[j].Total
+		}
+		return s.TopDegrees[i].ItemID < s.TopDegrees[j].ItemID
+	})
+	if len(s.TopDegrees) > top {
+		s.TopDegrees = s.TopDegrees[:top]
+	}
+
+	log.Dev(context, "Stats", "Completed : Quads[%d] Derived[%d] Items[%d] Orphans[%d]", s.Quads, s.Derived, s.Items, s.Orphans)
+	return &s, nil
+}
@@ -0,0 +1,63 @@
+package wire_test
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/coralproject/shelf/internal/wire"
+)
+
+// TestStats tests counting the relationships in the graph.
+func TestStats(t *testing.T) {
+	db, store, items := setupGraph(t)
+	defer tests.DisplayLog()
+
+	t.Log("Given the need to report statistics about the graph.")
+	{
+		t.Log("\tWhen the graph holds the relationships of a comment")
+		{
+			report, err := wire.AddToGraph(tests.Context, db, store, items[0])
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to add relationships to the graph : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to add relationships to the graph.", tests.Success)
+
+			derived := quad.Make(items[0]["item_id"], wirePrefix+"derived", items[0]["item_id"], "rule:"+wirePrefix+"test")
+			if err := store.AddQuad(derived); err != nil {
+				t.Fatalf("\t%s\tShould be able to add a derived quad : %s", tests.Failed, err)
+			}
+			defer store.RemoveQuad(derived)
+
+			s, err := wire.Stats(tests.Context, db, store, 1)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to get the stats : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to get the stats.", tests.Success)
+
+			if s.Quads != len(report.Added) {
+				t.Fatalf("\t%s\tShould count %d quads : %d", tests.Failed, len(report.Added), s.Quads)
+			}
+			t.Logf("\t%s\tShould count %d quads.", tests.Success, len(report.Added))
+
+			if s.Derived != 1 {
+				t.Fatalf("\t%s\tShould count the derived quad apart : %d", tests.Failed, s.Derived)
+			}
+			t.Logf("\t%s\tShould count the derived quad apart.", tests.Success)
+
+			var sum int
+			for _, p := range s.Predicates {
+				sum += p.Count
+			}
+			if sum != s.Quads {
+				t.Fatalf("\t%s\tShould count every quad by predicate : %+v", tests.Failed, s.Predicates)
+			}
+			t.Logf("\t%s\tShould count every quad by predicate.", tests.Success)
+
+			if len(s.TopDegrees) != 1 || s.TopDegrees[0].ItemID != items[0]["item_id"] || s.TopDegrees[0].Total != s.Quads {
+				t.Fatalf("\t%s\tShould report the comment with the most relationships : %+v", tests.Failed, s.TopDegrees)
+			}
+			t.Logf("\t%s\tShould report the comment with the most relationships.", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package wire
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/sponge/item"
+	"github.com/coralproject/shelf/internal/wire/relationship"
+)
+
+// typeCheck is the policy used for relationships that don't declare one.
+var typeCheck = struct {
+	sync.RWMutex
+	policy string
+}{
+	policy: relationship.TypeCheckWarn,
+}
+
+// SetTypeCheck sets the policy used for relationships that don't declare
+// one.
+func SetTypeCheck(policy string) error {
+	switch policy {
+	case relationship.TypeCheckReject, relationship.TypeCheckWarn, relationship.TypeCheckAllow:
+	default:
+		return fmt.Errorf("Invalid type check policy %q", policy)
+	}
+
+	typeCheck.Lock()
+	{
+		typeCheck.policy = policy
+	}
+	typeCheck.Unlock()
+
+	return nil
+}
+
+// Violation describes a relationship between items of types not declared
+// by the relationship.
+type Violation struct {
+	Subject     string `json:"subject"`
+	SubjectType string `json:"subject_type,omitempty"`
+	Predicate   string `json:"predicate"`
+	Object      string `json:"object"`
+	ObjectType  string `json:"object_type,omitempty"`
+	Policy      string `json:"policy"`
+}
+
+// String returns a description of the violation.
+func (v Violation) String() string {
+	return fmt.Sprintf("Relationship %s %s %s between types %q and %q", v.Subject, v.Predicate, v.Object, v.SubjectType, v.ObjectType)
+}
+
+// checkTypes validates the quads against the definitions of their
+// relationships. Quads rejected by their policy are removed. Relationships
+// without a definition are not checked.
+func checkTypes(context interface{}, db *db.DB, quadParams []QuadParam) ([]QuadParam, []Violation, error) {
+	typeCheck.RLock()
+	def := typeCheck.policy
+	typeCheck.RUnlock()
+
+	rels := make(map[string]*relationship.Relationship)
+	types := make(map[string]string)
+
+	var kept []QuadParam
+	var violations []Violation
+	for _, qp := range quadParams {
+
+		// Get the definition of the relationship.
+		rel, ok := rels[qp.Predicate]
+		if !ok {
+			var err error
+			rel, err = relationship.GetByPredicate(context, db, qp.Predicate)
+			if err != nil && err != relationship.ErrNotFound {
+				return nil, nil, err
+			}
+			rels[qp.Predicate] = rel
+		}
+
+		policy := def
+		if rel != nil && rel.TypeCheck != "" {
+			policy = rel.TypeCheck
+		}
+
+		if rel == nil || policy == relationship.TypeCheckAllow {
+			kept = append(kept, qp)
+			continue
+		}
+
+		// Look up the types not known from the item or the pattern.
+		subjectType, err := lookupType(context, db, types, qp.Subject, qp.subjectType)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		objectType, err := lookupType(context, db, types, qp.Object, qp.objectType)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if rel.Allows(subjectType, objectType) {
+			kept = append(kept, qp)
+			continue
+		}
+
+		violations = append(violations, Violation{
+			Subject:     qp.Subject,
+			SubjectType: subjectType,
+			Predicate:   qp.Predicate,
+			Object:      qp.Object,
+			ObjectType:  objectType,
+			Policy:      policy,
+		})
+
+		if policy == relationship.TypeCheckWarn {
+			kept = append(kept, qp)
+		}
+	}
+
+	return kept, violations, nil
+}
+
+// lookupType returns the type of an item, retrieving the item if the type
+// is not known. The type of an item that doesn't exist yet is empty.
+func lookupType(context interface{}, db *db.DB, types map[string]string, id, known string) (string, error) {
+	if known != "" {
+		return known, nil
+	}
+
+	if t, ok := types[id]; ok {
+		return t, nil
+	}
+
+	itm, err := item.GetByID(context, db, id)
+	if err != nil && err != item.ErrNotFound {
+		return "", err
+	}
+
+	types[id] = itm.Type
+	return itm.Type, nil
+}
@@ -3,7 +3,10 @@ package view
 import (
 	"fmt"
 
+	"github.com/coralproject/shelf/internal/platform/auth"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
 	validator "gopkg.in/bluesuncorp/validator.v8"
+	"gopkg.in/mgo.v2/bson"
 )
 
 //==============================================================================
@@ -17,13 +20,83 @@ func init() {
 
 //==============================================================================
 
+// Filter restricts the items a path segment can reach. Data contains
+// conditions on the fields of the item data using the MongoDB query syntax,
+// ie {"status": "approved", "score": {"$gte": 3}}. The operators and dotted
+// field names of Data are escaped while the view is stored.
+type Filter struct {
+	Types []string               `bson:"types,omitempty" json:"types,omitempty"`
+	Data  map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`
+}
+
+// Validate checks the filter value for consistency.
+func (f *Filter) Validate() error {
+	if len(f.Types) == 0 && len(f.Data) == 0 {
+		return fmt.Errorf("Filter has no conditions")
+	}
+
+	if hasKey(f.Data, "$where") {
+		return fmt.Errorf("Filter can't use $where")
+	}
+
+	return nil
+}
+
+// Query returns the MongoDB query matching the items allowed by the filter.
+func (f *Filter) Query() bson.M {
+	q := make(bson.M)
+
+	if len(f.Types) > 0 {
+		q["type"] = bson.M{"$in": f.Types}
+	}
+
+	for field, cond := range f.Data {
+		q["data."+field] = cond
+	}
+
+	return q
+}
+
+// hasKey reports if the key is used at any depth of the document.
+func hasKey(v interface{}, key string) bool {
+	switch doc := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range doc {
+			if k == key || hasKey(sub, key) {
+				return true
+			}
+		}
+
+	case bson.M:
+		return hasKey(map[string]interface{}(doc), key)
+
+	case []interface{}:
+		for _, sub := range doc {
+			if hasKey(sub, key) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// UntilNoMore is used as the MaxHops of a path segment to follow the
+// predicate until no more items are found.
+const UntilNoMore = -1
+
 // PathSegment contains metadata about a segment of a path,
-// which path partially defines a View.
+// which path partially defines a View. A segment with MaxHops set follows
+// its predicate repeatedly, between MinHops and MaxHops times. Such a
+// variable depth segment must be the last segment of its path.
 type PathSegment struct {
-	Level     int    `bson:"level" json:"level" validate:"required,min=1"`
-	Direction string `bson:"direction" json:"direction" validate:"required,min=2"`
-	Predicate string `bson:"predicate" json:"predicate" validate:"required,min=1"`
-	Tag       string `bson:"tag,omitempty" json:"tag,omitempty"`
+	Level     int     `bson:"level" json:"level" validate:"required,min=1"`
+	Direction string  `bson:"direction" json:"direction" validate:"required,min=2"`
+	Predicate string  `bson:"predicate" json:"predicate" validate:"required,min=1"`
+	Tag       string  `bson:"tag,omitempty" json:"tag,omitempty"`
+	Filter    *Filter `bson:"filter,omitempty" json:"filter,omitempty"`
+	MinHops   int     `bson:"min_hops,omitempty" json:"min_hops,omitempty"`
+	MaxHops   int     `bson:"max_hops,omitempty" json:"max_hops,omitempty"`
 }
 
 // Validate checks the pathsegment value for consistency.
@@ -31,9 +104,30 @@ func (ps *PathSegment) Validate() error {
 	if err := validate.Struct(ps); err != nil {
 		return err
 	}
+
+	if ps.MinHops < 0 || ps.MaxHops < UntilNoMore {
+		return fmt.Errorf("Path segment includes invalid hops")
+	}
+
+	if ps.MaxHops > 0 && ps.MinHops > ps.MaxHops {
+		return fmt.Errorf("Path segment min hops exceeds max hops")
+	}
+
+	if ps.Filter != nil {
+		if err := ps.Filter.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// Variable reports if the segment follows its predicate a variable number
+// of times.
+func (ps *PathSegment) Variable() bool {
+	return ps.MaxHops != 0
+}
+
 // PathSegments is a slice of PathSegment values.
 type PathSegments []PathSegment
 
@@ -69,11 +163,41 @@ func (path *Path) Validate() error {
 
 // View contains metadata about a view.
 type View struct {
-	Name       string `bson:"name" json:"name" validate:"required,min=3"`
-	Collection string `bson:"collection" json:"collection" validate:"required,min=2"`
-	StartType  string `bson:"start_type" json:"start_type" validate:"required,min=3"`
-	ReturnRoot bool   `bson:"return_root,omitempty" json:"return_root,omitempty"`
-	Paths      []Path `bson:"paths" json:"paths" validate:"required,min=1"`
+	Name       string    `bson:"name" json:"name" validate:"required,min=3"`
+	Collection string    `bson:"collection" json:"collection" validate:"required,min=2"`
+	StartType  string    `bson:"start_type" json:"start_type" validate:"required,min=3"`
+	ReturnRoot bool      `bson:"return_root,omitempty" json:"return_root,omitempty"`
+	Paths      []Path    `bson:"paths" json:"paths" validate:"required,min=1"`
+	ACL        *auth.ACL `bson:"acl,omitempty" json:"acl,omitempty"`
+}
+
+// PrepareForInsert replaces the keys of the filters so they can be saved
+// into MongoDB.
+func (v *View) PrepareForInsert() {
+	for _, f := range v.filters() {
+		mongo.PrepareForInsert(f.Data)
+	}
+}
+
+// PrepareForUse replaces the keys of the filters back to their orginal form.
+func (v *View) PrepareForUse() {
+	for _, f := range v.filters() {
+		mongo.PrepareForUse(f.Data)
+	}
+}
+
+// filters returns the filters of the path segments of the view.
+func (v *View) filters() []*Filter {
+	var fs []*Filter
+	for p := range v.Paths {
+		for s := range v.Paths[p].Segments {
+			if f := v.Paths[p].Segments[s].Filter; f != nil {
+				fs = append(fs, f)
+			}
+		}
+	}
+
+	return fs
 }
 
 // Validate checks the View value for consistency.
@@ -93,7 +217,19 @@ func (v *View) Validate() error {
 		}
 
 		// Validate each of the PathSegment values in the Path.
+		var last int
 		for _, segment := range path.Segments {
+			if segment.Level > last {
+				last = segment.Level
+			}
+		}
+
+		for _, segment := range path.Segments {
+
+			// A variable depth segment must end the path.
+			if segment.Variable() && segment.Level != last {
+				return fmt.Errorf("Variable depth path segment must be the last segment")
+			}
 
 			// Validate the PathSegment using the validator.
 			if err := segment.Validate(); err != nil {
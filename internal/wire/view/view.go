@@ -26,6 +26,10 @@ func Upsert(context interface{}, db *db.DB, view *View) error {
 		return err
 	}
 
+	// Fix the filters so the view can be saved.
+	view.PrepareForInsert()
+	defer view.PrepareForUse()
+
 	// Upsert the view.
 	f := func(c *mgo.Collection) error {
 		q := bson.M{"name": view.Name}
@@ -60,6 +64,11 @@ func GetAll(context interface{}, db *db.DB) ([]View, error) {
 		return nil, err
 	}
 
+	// Fix the filters so the views can be used.
+	for i := range views {
+		views[i].PrepareForUse()
+	}
+
 	log.Dev(context, "GetAll", "Completed")
 	return views, nil
 }
@@ -83,6 +92,9 @@ func GetByName(context interface{}, db *db.DB, name string) (*View, error) {
 		return &view, err
 	}
 
+	// Fix the filters so the view can be used.
+	view.PrepareForUse()
+
 	log.Dev(context, "GetByName", "Completed")
 	return &view, nil
 }
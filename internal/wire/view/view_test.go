@@ -168,3 +168,57 @@ func TestGetAll(t *testing.T) {
 		}
 	}
 }
+
+// TestFilterKeys tests saving a view filtering items with query operators and
+// dotted field names.
+func TestFilterKeys(t *testing.T) {
+	_, db := setup(t)
+	defer teardown(t, db)
+
+	v := view.View{
+		Name:       prefix + "filter_keys",
+		Collection: "items",
+		StartType:  "asset",
+		Paths: []view.Path{
+			{
+				Segments: view.PathSegments{
+					{
+						Level:     1,
+						Direction: "in",
+						Predicate: "on",
+						Filter: &view.Filter{
+							Data: map[string]interface{}{
+								"author.role": map[string]interface{}{"$ne": "hobbit"},
+								"$or":         []interface{}{map[string]interface{}{"score": map[string]interface{}{"$gte": 3.0}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	t.Log("Given the need to save views with filters.")
+	{
+		t.Log("\tWhen the filter uses operators and dotted field names")
+		{
+			if err := view.Upsert(tests.Context, db, &v); err != nil {
+				t.Fatalf("\t%s\tShould be able to upsert the view : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to upsert the view.", tests.Success)
+
+			got, err := view.GetByName(tests.Context, db, v.Name)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to get the view by name : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to get the view by name.", tests.Success)
+
+			if !reflect.DeepEqual(v, *got) {
+				t.Logf("\t%+v", v.Paths[0].Segments[0].Filter)
+				t.Logf("\t%+v", got.Paths[0].Segments[0].Filter)
+				t.Fatalf("\t%s\tShould be able to get back the same filter.", tests.Failed)
+			}
+			t.Logf("\t%s\tShould be able to get back the same filter.", tests.Success)
+		}
+	}
+}
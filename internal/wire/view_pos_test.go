@@ -10,6 +10,9 @@ func getPosViews() []execView {
 		splitPath(),
 		backwards(),
 		unfulfilledFullPath(),
+		filteredSegment(),
+		operatorFilter(),
+		replyTree(),
 	}
 }
 
@@ -138,3 +141,56 @@ func unfulfilledFullPath() execView {
 		},
 	}
 }
+
+// filteredSegment executes a view with filters on the items of its segments.
+func filteredSegment() execView {
+	return execView{
+		fail:       false,
+		viewName:   "thread_wizards",
+		itemKey:    "c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+		number:     4,
+		collection: "",
+		results: []string{
+			`"item_id":"WTEST_d1dfa366-d2f7-4a4a-a64f-af89d4c97d82"`,
+			`"item_id":"WTEST_6eaaa19f-da7a-4095-bbe3-cee7a7631dd4"`,
+			`"item_id":"WTEST_d16790f8-13e9-4cb4-b9ef-d82835589660"`,
+			`"item_id":"WTEST_a63af637-58af-472b-98c7-f5c00743bac6"`,
+		},
+	}
+}
+
+// operatorFilter executes a view filtering the items of a segment with a
+// query operator.
+func operatorFilter() execView {
+	return execView{
+		fail:       false,
+		viewName:   "thread_not_hobbits",
+		itemKey:    "c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+		number:     4,
+		collection: "",
+		results: []string{
+			`"item_id":"WTEST_d1dfa366-d2f7-4a4a-a64f-af89d4c97d82"`,
+			`"item_id":"WTEST_6eaaa19f-da7a-4095-bbe3-cee7a7631dd4"`,
+			`"item_id":"WTEST_d16790f8-13e9-4cb4-b9ef-d82835589660"`,
+			`"item_id":"WTEST_a63af637-58af-472b-98c7-f5c00743bac6"`,
+		},
+	}
+}
+
+// replyTree executes a view following the replies to the comments of an asset
+// until no more replies are found.
+func replyTree() execView {
+	return execView{
+		fail:       false,
+		viewName:   "thread_replies",
+		itemKey:    "c1b2bbfe-af9f-4903-8777-bd47c4d5b20a",
+		number:     3,
+		collection: "",
+		results: []string{
+			`"item_id":"WTEST_d1dfa366-d2f7-4a4a-a64f-af89d4c97d82"`,
+			`"item_id":"WTEST_6eaaa19f-da7a-4095-bbe3-cee7a7631dd4"`,
+			`"item_id":"WTEST_d16790f8-13e9-4cb4-b9ef-d82835589660"`,
+			`"related":{"reply":["WTEST_6eaaa19f-da7a-4095-bbe3-cee7a7631dd4"]}`,
+		},
+	}
+}
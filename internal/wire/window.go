@@ -0,0 +1,133 @@
+package wire
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/wire/view"
+)
+
+// window bounds the times the relationships followed by a view were created.
+// A zero bound does not limit the times.
+type window struct {
+	from time.Time
+	to   time.Time
+}
+
+// window returns the bounds of the times of the relationships followed by
+// the view, nil if the view is not limited in time.
+func (vp *ViewParams) window() *window {
+	if vp.AsOf == nil && vp.From == nil && vp.To == nil {
+		return nil
+	}
+
+	var w window
+	if vp.From != nil {
+		w.from = *vp.From
+	}
+	if vp.To != nil {
+		w.to = *vp.To
+	}
+	if vp.AsOf != nil && (w.to.IsZero() || vp.AsOf.Before(w.to)) {
+		w.to = *vp.AsOf
+	}
+
+	return &w
+}
+
+// contains reports if the time is within the window.
+func (w *window) contains(t time.Time) bool {
+	if !w.from.IsZero() && t.Before(w.from) {
+		return false
+	}
+	if !w.to.IsZero() && t.After(w.to) {
+		return false
+	}
+	return true
+}
+
+// levelTag returns the tag marking the items reached at a level of a path,
+// so the relationships followed can be checked. It can't collide with view
+// tags since those never contain "$".
+func levelTag(alias string, level int) string {
+	return alias + "$level" + strconv.Itoa(level)
+}
+
+// segmentAt returns the segment reaching the items at the level of a path.
+// The levels past a variable depth segment are reached by that segment.
+func segmentAt(segments view.PathSegments, level int) (view.PathSegment, bool) {
+	for _, segment := range segments {
+		if segment.Level == level {
+			return segment, true
+		}
+
+		if segment.Variable() && level > segment.Level {
+			if _, max := segmentHops(segment); level <= segment.Level+max-1 {
+				return segment, true
+			}
+		}
+	}
+
+	return view.PathSegment{}, false
+}
+
+// windowRows removes the graph results following a relationship that was not
+// created within the window. A relationship without metadata, like one added
+// before the metadata was recorded and not backfilled by RebuildGraph, or one
+// derived by a rule, has an unknown time and is not removed by any window.
+func windowRows(context interface{}, mgoDB *db.DB, v *view.View, key string, w *window, rows []map[string]string) ([]map[string]string, error) {
+	if w == nil || len(rows) == 0 {
+		return rows, nil
+	}
+
+	// Find the relationships followed by each result.
+	followed := make([][]Edge, len(rows))
+	var edges []Edge
+	for i, row := range rows {
+		for idx, pth := range v.Paths {
+			alias := strconv.Itoa(idx+1) + "_"
+
+			prev := key
+			for level := 1; ; level++ {
+				cur, ok := row[levelTag(alias, level)]
+				if !ok {
+					break
+				}
+
+				segment, ok := segmentAt(pth.Segments, level)
+				if !ok {
+					break
+				}
+
+				e := Edge{Subject: prev, Predicate: segment.Predicate, Object: cur}
+				if segment.Direction == inString {
+					e = Edge{Subject: cur, Predicate: segment.Predicate, Object: prev}
+				}
+
+				followed[i] = append(followed[i], e)
+				edges = append(edges, e)
+				prev = cur
+			}
+		}
+	}
+
+	metas, err := getMeta(context, mgoDB, edges)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the results where every relationship is within the window.
+	var kept []map[string]string
+next:
+	for i, row := range rows {
+		for _, e := range followed[i] {
+			if m, ok := metas[e]; ok && !w.contains(m.Time) {
+				continue next
+			}
+		}
+		kept = append(kept, row)
+	}
+
+	return kept, nil
+}
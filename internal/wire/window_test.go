@@ -0,0 +1,87 @@
+package wire_test
+
+import (
+	"testing"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/wire"
+)
+
+// TestExecuteWindow tests executing a view following only the relationships
+// created within a time window.
+func TestExecuteWindow(t *testing.T) {
+	db, store := setup(t)
+	defer teardown(t, db, store)
+
+	asset := wirePrefix + "c1b2bbfe-af9f-4903-8777-bd47c4d5b20a"
+	start := time.Date(2016, 1, 22, 15, 0, 0, 0, time.UTC)
+
+	// The comments were made at the start, after half an hour and after two hours.
+	metas := []wire.RelMeta{
+		{Subject: wirePrefix + "d1dfa366-d2f7-4a4a-a64f-af89d4c97d82", Predicate: wirePrefix + "on", Object: asset, Time: start},
+		{Subject: wirePrefix + "6eaaa19f-da7a-4095-bbe3-cee7a7631dd4", Predicate: wirePrefix + "on", Object: asset, Time: start.Add(30 * time.Minute)},
+		{Subject: wirePrefix + "d16790f8-13e9-4cb4-b9ef-d82835589660", Predicate: wirePrefix + "on", Object: asset, Time: start.Add(2 * time.Hour)},
+	}
+
+	f := func(c *mgo.Collection) error {
+		for _, m := range metas {
+			if _, err := c.Upsert(bson.M{"subject": m.Subject, "predicate": m.Predicate, "object": m.Object}, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := db.ExecuteMGO(tests.Context, wire.MetaCollection, f); err != nil {
+		t.Fatalf("\t%s\tShould be able to save the relationship metadata : %s", tests.Failed, err)
+	}
+
+	defer func() {
+		f := func(c *mgo.Collection) error {
+			_, err := c.RemoveAll(bson.M{"subject": bson.RegEx{Pattern: "^" + wirePrefix}})
+			return err
+		}
+		if err := db.ExecuteMGO(tests.Context, wire.MetaCollection, f); err != nil {
+			t.Fatalf("\t%s\tShould be able to remove the relationship metadata : %s", tests.Failed, err)
+		}
+	}()
+
+	t.Log("Given the need to execute a view within a time window.")
+	{
+		t.Log("\tWhen getting the comments made in the first hour")
+		{
+			to := start.Add(time.Hour)
+			viewParams := wire.ViewParams{
+				ViewName: wirePrefix + "thread",
+				ItemKey:  asset,
+				From:     &start,
+				To:       &to,
+			}
+
+			result, err := wire.Execute(tests.Context, db, store, &viewParams)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to execute the view : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to execute the view.", tests.Success)
+
+			// The authored relationships have no metadata, so their time is
+			// unknown and they are followed.
+			items, _ := result.Results.([]bson.M)
+			if len(items) != 3 {
+				t.Fatalf("\t%s\tShould get the 2 comments made in the first hour and their author : %v", tests.Failed, items)
+			}
+			t.Logf("\t%s\tShould get the 2 comments made in the first hour and their author.", tests.Success)
+
+			for _, it := range items {
+				if it["item_id"] == metas[2].Subject || it["item_id"] == wirePrefix+"a63af637-58af-472b-98c7-f5c00743bac6" {
+					t.Fatalf("\t%s\tShould not get the comment made after two hours or its author", tests.Failed)
+				}
+			}
+			t.Logf("\t%s\tShould not get the comment made after two hours or its author.", tests.Success)
+		}
+	}
+}
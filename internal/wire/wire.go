@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -38,6 +39,7 @@ const (
 // Result represents what a user will receive after generating a view.
 type Result struct {
 	Results interface{} `json:"results"`
+	Page    *Page       `json:"page,omitempty"`
 }
 
 // errResult returns a Result value with an error message.
@@ -51,10 +53,21 @@ func errResult(err error) *Result {
 
 // ViewParams represents how the View will be generated and persisted.
 type ViewParams struct {
-	ViewName          string `json:"view_name"`
-	ItemKey           string `json:"item_key"`
-	ResultsCollection string `json:"results_collection"`
-	BufferLimit       int    `json:"buffer_limit"`
+	ViewName          string   `json:"view_name"`
+	ItemKey           string   `json:"item_key"`
+	ItemKeys          []string `json:"item_keys,omitempty"` // Start from every key, annotating the items with their roots.
+	ResultsCollection string   `json:"results_collection"`
+	BufferLimit       int      `json:"buffer_limit"`
+	Limit             int      `json:"limit,omitempty"`  // Maximum number of items, zero for all.
+	Offset            int      `json:"offset,omitempty"` // Number of items to skip.
+	Cursor            string   `json:"cursor,omitempty"` // Cursor returned with the previous page.
+	Sort              string   `json:"sort,omitempty"`   // Item field to sort by, prefixed with - for descending.
+
+	// The view only follows relationships created within a time window, and
+	// those without metadata whose time is unknown.
+	AsOf *time.Time `json:"as_of,omitempty"` // Relationships created at or before.
+	From *time.Time `json:"from,omitempty"`  // Relationships created at or after.
+	To   *time.Time `json:"to,omitempty"`    // Relationships created at or before.
 }
 
 //==============================================================================
@@ -63,29 +76,8 @@ type ViewParams struct {
 func Execute(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, viewParams *ViewParams) (*Result, error) {
 	log.Dev(context, "Execute", "Started : Name[%s]", viewParams.ViewName)
 
-	// Get the view.
-	v, err := view.GetByName(context, mgoDB, viewParams.ViewName)
-	if err != nil {
-		log.Error(context, "Execute", err, "Completed")
-		return errResult(err), err
-	}
-
-	// Validate the start type.
-	if err := validateStartType(context, mgoDB, v); err != nil {
-		log.Error(context, "Execute", err, "Completed")
-		return errResult(err), err
-	}
-
-	// Translate the view path into a graph query path.
-	graphPath, err := viewPathToGraphPath(v, viewParams.ItemKey, graphDB)
-	if err != nil {
-		log.Error(context, "Execute", err, "Completed")
-		return errResult(err), err
-	}
-
-	// Retrieve the item IDs for the view along with any related item IDs
-	// that should be embedded in view items (i.e., "embeds").
-	ids, embeds, err := viewIDs(v, graphPath, viewParams.ItemKey, graphDB)
+	// Resolve the view into the item IDs and embedded relationships it contains.
+	v, ids, embeds, roots, err := resolveView(context, mgoDB, graphDB, viewParams)
 	if err != nil {
 		log.Error(context, "Execute", err, "Completed")
 		return errResult(err), err
@@ -93,30 +85,93 @@ func Execute(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, viewPara
 
 	// Persist the items in the view, if an output Collection is provided.
 	if viewParams.ResultsCollection != "" {
-		if err := viewSave(context, mgoDB, v, viewParams, ids, embeds); err != nil {
+		saved, err := viewSave(context, mgoDB, v, viewParams, ids, embeds, roots)
+		if err != nil {
 			log.Error(context, "Execute", err, "Completed")
 			return errResult(err), err
 		}
+
+		// Without paging every item in the view is saved.
+		number := len(ids)
+		if viewParams.paged() {
+			number = saved
+		}
+
 		result := Result{
-			Results: bson.M{"number_of_results": len(ids)},
+			Results: bson.M{"number_of_results": number},
 		}
 		return &result, nil
 	}
 
 	// Otherwise, gather the items in the view.
-	items, err := viewItems(context, mgoDB, v, ids, embeds)
+	items, page, err := viewItems(context, mgoDB, v, viewParams, ids, embeds, roots)
 	if err != nil {
 		log.Error(context, "Execute", err, "Completed")
 		return errResult(err), err
 	}
 	result := Result{
 		Results: items,
+		Page:    page,
 	}
 
 	log.Dev(context, "Execute", "Completed")
 	return &result, nil
 }
 
+// resolveView retrieves the view and walks the graph to find the item IDs in
+// the view along with any related item IDs that should be embedded in view
+// items. When the view starts from several item keys, the item IDs reached
+// from each key are merged and the keys reaching each item are returned.
+func resolveView(context interface{}, mgoDB *db.DB, graphDB *cayley.Handle, viewParams *ViewParams) (*view.View, []string, embeddedRels, itemRoots, error) {
+
+	// Get the view.
+	v, err := view.GetByName(context, mgoDB, viewParams.ViewName)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// Validate the start type.
+	if err := validateStartType(context, mgoDB, v); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	win := viewParams.window()
+
+	var ids []string
+	var embeds embeddedRels
+	var roots itemRoots
+	if len(viewParams.ItemKeys) > 0 {
+		roots = make(itemRoots)
+	}
+
+	for _, key := range viewParams.rootKeys() {
+
+		// Translate the view path into a graph query path.
+		graphPath, err := viewPathToGraphPath(v, key, win != nil, graphDB)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+
+		// Retrieve the item IDs for the view along with any related item IDs
+		// that should be embedded in view items (i.e., "embeds").
+		keyIDs, keyEmbeds, err := viewIDs(context, mgoDB, v, graphPath, key, win, graphDB)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+
+		ids = append(ids, keyIDs...)
+		embeds = append(embeds, keyEmbeds...)
+		roots.add(key, keyIDs)
+	}
+
+	// Items reached from several keys are only included once.
+	if roots != nil {
+		ids = distinct(ids)
+	}
+
+	return v, ids, embeds, roots, nil
+}
+
 //==============================================================================
 
 // validateStartType verifies the start type of a view path.
@@ -168,8 +223,9 @@ PathLoop:
 }
 
 // viewPathToGraphPath translates the path in a view into a "path"
-// utilized in graph queries.
-func viewPathToGraphPath(v *view.View, key string, graphDB *cayley.Handle) (*path.Path, error) {
+// utilized in graph queries. The items reached at each level are tagged if
+// the relationships followed need to be checked.
+func viewPathToGraphPath(v *view.View, key string, tagLevels bool, graphDB *cayley.Handle) (*path.Path, error) {
 
 	// outputPath is the final tranlated graph path.
 	var outputPath *path.Path
@@ -202,6 +258,23 @@ func viewPathToGraphPath(v *view.View, key string, graphDB *cayley.Handle) (*pat
 				return graphPath, err
 			}
 
+			// Follow a variable depth segment for each number of hops. It
+			// ends the path, so each hop is a sub path.
+			if segment.Variable() {
+				hopPaths := variableGraphPaths(graphPath, segment, alias, key, tagLevels, graphDB)
+
+				graphPath = hopPaths[0]
+				for _, hopPath := range hopPaths {
+					subPaths = append(subPaths, *hopPath.Clone())
+					if hopPath != hopPaths[0] {
+						graphPath = graphPath.Clone().Or(hopPath)
+					}
+				}
+
+				level++
+				continue
+			}
+
 			// Initialize the path, if we are on level 1.
 			if level == 1 {
 
@@ -218,6 +291,16 @@ func viewPathToGraphPath(v *view.View, key string, graphDB *cayley.Handle) (*pat
 					graphPath = graphPath.Clone().Tag(alias + segment.Tag)
 				}
 
+				// Tag the items the filter will be applied to, if present.
+				if segment.Filter != nil {
+					graphPath = graphPath.Clone().Tag(filterTag(alias, segment.Level))
+				}
+
+				// Tag the items reached at the level, if needed.
+				if tagLevels {
+					graphPath = graphPath.Clone().Tag(levelTag(alias, segment.Level))
+				}
+
 				// Track this as a subpath.
 				subPaths = append(subPaths, *graphPath.Clone())
 
@@ -238,6 +321,16 @@ func viewPathToGraphPath(v *view.View, key string, graphDB *cayley.Handle) (*pat
 				graphPath = graphPath.Clone().Tag(alias + segment.Tag)
 			}
 
+			// Tag the items the filter will be applied to, if present.
+			if segment.Filter != nil {
+				graphPath = graphPath.Clone().Tag(filterTag(alias, segment.Level))
+			}
+
+			// Tag the items reached at the level, if needed.
+			if tagLevels {
+				graphPath = graphPath.Clone().Tag(levelTag(alias, segment.Level))
+			}
+
 			// Add this as a subpath.
 			subPaths = append(subPaths, *graphPath.Clone())
 
@@ -283,8 +376,9 @@ type embeddedRels []embeddedRel
 // relList contains one or more related IDs.
 type relList []string
 
-// viewIDs retrieves the item IDs associated with the view.
-func viewIDs(v *view.View, path *path.Path, key string, graphDB *cayley.Handle) ([]string, embeddedRels, error) {
+// viewIDs retrieves the item IDs associated with the view. If a window is
+// provided, only the relationships created within it are followed.
+func viewIDs(context interface{}, mgoDB *db.DB, v *view.View, path *path.Path, key string, win *window, graphDB *cayley.Handle) ([]string, embeddedRels, error) {
 
 	// Build the Cayley iterator.
 	it := path.BuildIterator()
@@ -300,41 +394,69 @@ func viewIDs(v *view.View, path *path.Path, key string, graphDB *cayley.Handle)
 	for idx, pth := range v.Paths {
 		alias := strconv.Itoa(idx+1) + "_"
 		for _, segment := range pth.Segments {
-			if segment.Tag != "" {
-				viewTags = append(viewTags, alias+segment.Tag)
-				tagOrder[alias+segment.Tag] = alias + strconv.Itoa(segment.Level)
-				tagOrder[alias+strconv.Itoa(segment.Level)] = alias + segment.Tag
+			if segment.Tag == "" {
+				continue
+			}
 
+			// A variable depth segment tags the items of each hop, ordered
+			// as if each hop was a segment.
+			min, max := segmentHops(segment)
+			for hop := min; hop <= max; hop++ {
+				tag := hopTag(alias+segment.Tag, hop)
+				order := alias + strconv.Itoa(segment.Level+hop-1)
+				viewTags = append(viewTags, tag)
+				tagOrder[tag] = order
+				tagOrder[order] = tag
 			}
 		}
 	}
 
-	// Retrieve the end path and tagged item IDs.
-	var ids []string
-	var embeds embeddedRels
+	// Retrieve the tagged item IDs of each result.
+	var rows []map[string]string
 	for it.Next() {
 
 		// Tag the results.
 		resultTags := make(map[string]graph.Value)
 		it.TagResults(resultTags)
 
+		row := make(map[string]string, len(resultTags))
+		for tag, t := range resultTags {
+			row[tag] = quad.NativeOf(graphDB.NameOf(t)).(string)
+		}
+		rows = append(rows, row)
+	}
+	if it.Err() != nil {
+		return nil, nil, it.Err()
+	}
+
+	// Remove the results reaching items excluded by the segment filters.
+	rows, err := filterRows(context, mgoDB, v, rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Remove the results following relationships created outside the window.
+	rows, err = windowRows(context, mgoDB, v, key, win, rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Retrieve the end path and tagged item IDs.
+	var ids []string
+	var embeds embeddedRels
+	for _, row := range rows {
+
 		// Extract the tagged item IDs.
 		taggedIDs := make(map[string]relList)
 		for _, tag := range viewTags {
-			if t, ok := resultTags[tag]; ok {
+			if id, ok := row[tag]; ok {
 
 				// Append the view item ID.
-				ids = append(ids, quad.NativeOf(graphDB.NameOf(t)).(string))
+				ids = append(ids, id)
 
 				// Add the tagged ID to the tagged map for embedded
 				// relationship extraction.
-				current, ok := taggedIDs[tag]
-				if !ok {
-					taggedIDs[tag] = []string{quad.NativeOf(graphDB.NameOf(t)).(string)}
-					continue
-				}
-				updated := append(current, quad.NativeOf(graphDB.NameOf(t)).(string))
-				taggedIDs[tag] = updated
+				taggedIDs[tag] = append(taggedIDs[tag], id)
 			}
 		}
 
@@ -345,9 +467,6 @@ func viewIDs(v *view.View, path *path.Path, key string, graphDB *cayley.Handle)
 		}
 		embeds = append(embeds, embed...)
 	}
-	if it.Err() != nil {
-		return ids, embeds, it.Err()
-	}
 
 	// Remove duplicates.
 	found := make(map[string]bool)
@@ -403,11 +522,13 @@ func extractEmbeddedRels(v *view.View, taggedIDs map[string]relList, tagOrder ma
 				if ok {
 					break
 				}
+				order--
 			}
 		}
 
-		// Extract the non-alias tag.
-		aliasTag := strings.Split(tag, "_")
+		// Extract the non-alias tag. Items reached by a variable depth
+		// segment are embedded under the segment tag at every hop.
+		aliasTag := strings.Split(baseTag(tag), "_")
 		nonAliasTag := strings.Join(aliasTag[1:], "_")
 
 		// If we are embedding in a non-root item, get that item ID and form
@@ -446,7 +567,8 @@ func extractEmbeddedRels(v *view.View, taggedIDs map[string]relList, tagOrder ma
 }
 
 // viewSave retrieve items for a view and saves those items to a new collection.
-func viewSave(context interface{}, mgoDB *db.DB, v *view.View, viewParams *ViewParams, ids []string, embeds embeddedRels) error {
+// The number of items saved is returned.
+func viewSave(context interface{}, mgoDB *db.DB, v *view.View, viewParams *ViewParams, ids []string, embeds embeddedRels, roots itemRoots) (int, error) {
 
 	// Determine the buffer limit that will be used for saving this view.
 	if viewParams.BufferLimit != 0 {
@@ -454,25 +576,38 @@ func viewSave(context interface{}, mgoDB *db.DB, v *view.View, viewParams *ViewP
 	}
 
 	// Form the query.
-	q := bson.M{"item_id": bson.M{"$in": ids}}
-	results, err := mgoDB.BatchedQueryMGO(context, v.Collection, q)
+	q, sort, err := itemQuery(viewParams, ids)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	var results *mgo.Iter
+	if sort == nil {
+		if results, err = mgoDB.BatchedQueryMGO(context, v.Collection, q); err != nil {
+			return 0, err
+		}
+	} else {
+		c, err := mgoDB.CollectionMGO(context, v.Collection)
+		if err != nil {
+			return 0, err
+		}
+		results = c.Find(q).Sort(sort...).Skip(viewParams.Offset).Limit(viewParams.Limit).Iter()
 	}
 
 	// Set up a Bulk upsert.
 	tx, err := mgoDB.BulkOperationMGO(context, viewParams.ResultsCollection)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Group the embedded relationships by item and predicate/tag.
 	embedByItem, err := groupEmbeds(embeds)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Iterate over the view items.
+	var saved int
 	var queuedDocs int
 	var result item.Item
 	for results.Next(&result) {
@@ -489,42 +624,70 @@ func viewSave(context interface{}, mgoDB *db.DB, v *view.View, viewParams *ViewP
 			result.Related = relMap
 		}
 
-		// Queue the upsert of the result.
-		tx.Upsert(bson.M{"item_id": result.ID}, result)
+		// Queue the upsert of the result, annotated with the keys reaching it.
+		tx.Upsert(bson.M{"item_id": result.ID}, rootedItem{Item: result, Roots: roots[result.ID]})
 		queuedDocs++
+		saved++
 
 		// If the queued documents for upsert have reached the buffer limit,
 		// run the bulk upsert and re-initialize the bulk operation.
 		if queuedDocs >= bufferLimit {
 			if _, err := tx.Run(); err != nil {
-				return err
+				return saved, err
 			}
 			tx, err = mgoDB.BulkOperationMGO(context, viewParams.ResultsCollection)
 			if err != nil {
-				return err
+				return saved, err
 			}
 			queuedDocs = 0
 		}
 	}
 	if err := results.Close(); err != nil {
-		return err
+		return saved, err
 	}
 
 	// Run the bulk operation for any remaining queued documents.
 	if _, err := tx.Run(); err != nil {
-		return err
+		return saved, err
 	}
 
-	return nil
+	return saved, nil
 }
 
 // viewItems retrieves the items corresponding to the provided list of item IDs.
-func viewItems(context interface{}, db *db.DB, v *view.View, ids []string, embeds embeddedRels) ([]bson.M, error) {
+// If a page of the items is requested, the page is described by the returned
+// Page value.
+func viewItems(context interface{}, db *db.DB, v *view.View, viewParams *ViewParams, ids []string, embeds embeddedRels, roots itemRoots) ([]bson.M, *Page, error) {
 
 	// Form the query.
+	q, sort, err := itemQuery(viewParams, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var results []item.Item
+	var page *Page
 	f := func(c *mgo.Collection) error {
-		return c.Find(bson.M{"item_id": bson.M{"$in": ids}}).All(&results)
+		if sort == nil {
+			return c.Find(q).All(&results)
+		}
+
+		if err := c.Find(q).Sort(sort...).Skip(viewParams.Offset).Limit(viewParams.Limit).All(&results); err != nil {
+			return err
+		}
+
+		total, err := c.Find(bson.M{"item_id": bson.M{"$in": ids}}).Count()
+		if err != nil {
+			return err
+		}
+
+		page = &Page{
+			Total:  total,
+			Limit:  viewParams.Limit,
+			Offset: viewParams.Offset,
+		}
+
+		return nil
 	}
 
 	// Execute the query.
@@ -532,18 +695,27 @@ func viewItems(context interface{}, db *db.DB, v *view.View, ids []string, embed
 		if err == mgo.ErrNotFound {
 			err = ErrNotFound
 		}
-		return nil, err
+		return nil, nil, err
+	}
+
+	// A full page might be followed by more items.
+	if page != nil && page.Limit > 0 && len(results) == page.Limit {
+		next, err := nextCursor(viewParams, itemDoc(results[len(results)-1], nil))
+		if err != nil {
+			return nil, nil, err
+		}
+		page.Next = next
 	}
 
 	// Group the embedded relationships by item and predicate/tag.
 	embedByItem, err := groupEmbeds(embeds)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Embed any related item IDs in the returned items.
 	var output []bson.M
-	if len(embedByItem) > 0 {
+	if len(embedByItem) > 0 || roots != nil {
 		for _, result := range results {
 
 			// Get the respective IDs to embed.
@@ -556,21 +728,38 @@ func viewItems(context interface{}, db *db.DB, v *view.View, ids []string, embed
 				result.Related = relMap
 			}
 
-			// Convert to bson.M for output.
-			itemBSON := bson.M{
-				"item_id":    result.ID,
-				"type":       result.Type,
-				"version":    result.Version,
-				"data":       result.Data,
-				"created_at": result.CreatedAt,
-				"updated_at": result.UpdatedAt,
-				"related":    result.Related,
-			}
-			output = append(output, itemBSON)
+			// Convert to bson.M for output, annotated with the keys reaching it.
+			output = append(output, itemDoc(result, roots[result.ID]))
 		}
 	}
 
-	return output, nil
+	return output, page, nil
+}
+
+// rootedItem is a view item saved with the item keys that reached it.
+type rootedItem struct {
+	item.Item `bson:",inline"`
+	Roots     []string `bson:"roots,omitempty"`
+}
+
+// itemDoc converts a view item to the document that is returned, with the
+// item keys that reached it.
+func itemDoc(it item.Item, roots []string) bson.M {
+	doc := bson.M{
+		"item_id":    it.ID,
+		"type":       it.Type,
+		"version":    it.Version,
+		"data":       it.Data,
+		"created_at": it.CreatedAt,
+		"updated_at": it.UpdatedAt,
+		"related":    it.Related,
+	}
+
+	if len(roots) > 0 {
+		doc["roots"] = roots
+	}
+
+	return doc
 }
 
 // predicateEmbeds includes slices of related item IDs grouped by predicate/tag.
@@ -16,7 +16,6 @@ import (
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/coralproject/shelf/internal/platform/db"
-	cayleyshelf "github.com/coralproject/shelf/internal/platform/db/cayley"
 	"github.com/coralproject/shelf/internal/wire"
 	"github.com/coralproject/shelf/internal/wire/wirefix"
 )
@@ -35,6 +34,13 @@ func runTest(m *testing.M) int {
 	// else the web app layer needs.
 	tests.Init("XENIA")
 
+	// Keep the graph in memory so the tests don't depend on the quads
+	// stored in MongoDB.
+	if err := db.SetGraphBackend(db.GraphMemory, ""); err != nil {
+		fmt.Println("Can't use the in-memory graph: " + err.Error())
+		return 1
+	}
+
 	// Initialize MongoDB using the `tests.TestSession` as the name of the
 	// master session.
 	if err := db.RegMasterSession(tests.Context, tests.TestSession, cfg.MustURL("MONGO_URI").String(), 0); err != nil {
@@ -83,12 +89,6 @@ func loadTestData(context interface{}, db *db.DB) error {
 	// -----------------------------------------------------------
 	// Build the example graph.
 
-	mongoURI := cfg.MustURL("MONGO_URI")
-
-	if err := cayleyshelf.InitQuadStore(mongoURI.String()); err != nil {
-		return err
-	}
-
 	var quads []quad.Quad
 	quads = append(quads, quad.Make(wirePrefix+"d1dfa366-d2f7-4a4a-a64f-af89d4c97d82", wirePrefix+"on", wirePrefix+"c1b2bbfe-af9f-4903-8777-bd47c4d5b20a", ""))
 	quads = append(quads, quad.Make(wirePrefix+"6eaaa19f-da7a-4095-bbe3-cee7a7631dd4", wirePrefix+"on", wirePrefix+"c1b2bbfe-af9f-4903-8777-bd47c4d5b20a", ""))
@@ -97,6 +97,7 @@ func loadTestData(context interface{}, db *db.DB) error {
 	quads = append(quads, quad.Make(wirePrefix+"80aa936a-f618-4234-a7be-df59a14cf8de", wirePrefix+"authored", wirePrefix+"6eaaa19f-da7a-4095-bbe3-cee7a7631dd4", ""))
 	quads = append(quads, quad.Make(wirePrefix+"a63af637-58af-472b-98c7-f5c00743bac6", wirePrefix+"authored", wirePrefix+"d16790f8-13e9-4cb4-b9ef-d82835589660", ""))
 	quads = append(quads, quad.Make(wirePrefix+"a63af637-58af-472b-98c7-f5c00743bac6", wirePrefix+"flagged", wirePrefix+"80aa936a-f618-4234-a7be-df59a14cf8de", ""))
+	quads = append(quads, quad.Make(wirePrefix+"6eaaa19f-da7a-4095-bbe3-cee7a7631dd4", wirePrefix+"parented_by", wirePrefix+"d1dfa366-d2f7-4a4a-a64f-af89d4c97d82", ""))
 
 	tx := cayley.NewTransaction()
 	for _, quad := range quads {
@@ -139,6 +140,7 @@ func unloadTestData(context interface{}, db *db.DB) error {
 	quads = append(quads, quad.Make(wirePrefix+"80aa936a-f618-4234-a7be-df59a14cf8de", wirePrefix+"authored", wirePrefix+"6eaaa19f-da7a-4095-bbe3-cee7a7631dd4", ""))
 	quads = append(quads, quad.Make(wirePrefix+"a63af637-58af-472b-98c7-f5c00743bac6", wirePrefix+"authored", wirePrefix+"d16790f8-13e9-4cb4-b9ef-d82835589660", ""))
 	quads = append(quads, quad.Make(wirePrefix+"a63af637-58af-472b-98c7-f5c00743bac6", wirePrefix+"flagged", wirePrefix+"80aa936a-f618-4234-a7be-df59a14cf8de", ""))
+	quads = append(quads, quad.Make(wirePrefix+"6eaaa19f-da7a-4095-bbe3-cee7a7631dd4", wirePrefix+"parented_by", wirePrefix+"d1dfa366-d2f7-4a4a-a64f-af89d4c97d82", ""))
 
 	tx := cayley.NewTransaction()
 	for _, quad := range quads {
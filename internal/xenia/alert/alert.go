@@ -0,0 +1,173 @@
+// Package alert provides the service layer for alerts that fire when the
+// results of a query set meet a condition.
+package alert
+
+import (
+	"errors"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Contains the name of Mongo collections.
+const (
+	Collection        = "alert_defs"
+	CollectionHistory = "alerts"
+)
+
+// Set of error variables.
+var (
+	ErrNotFound = errors.New("Alert Not found")
+)
+
+// =============================================================================
+
+// Upsert is used to create or update an existing Alert document. The state
+// of an existing alert is maintained, as is its secret if none is provided.
+func Upsert(context interface{}, db *db.DB, a *Alert) error {
+	log.Dev(context, "Upsert", "Started : Name[%s]", a.Name)
+
+	// Validate the alert that is provided.
+	if err := a.Validate(); err != nil {
+		log.Error(context, "Upsert", err, "Completed")
+		return err
+	}
+
+	// The state is owned by the evaluation of the alert.
+	cur, err := GetByName(context, db, a.Name)
+	if err != nil && err != ErrNotFound {
+		log.Error(context, "Upsert", err, "Completed")
+		return err
+	}
+
+	if cur != nil {
+		a.State = cur.State
+		a.LastEval = cur.LastEval
+		a.LastChange = cur.LastChange
+
+		if a.Secret == "" {
+			a.Secret = cur.Secret
+		}
+	} else {
+		a.State = StateOK
+	}
+
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"name": a.Name}
+		log.Dev(context, "Upsert", "MGO : db.%s.upsert(%s, %s)", c.Name, mongo.Query(q), mongo.Query(a))
+		_, err := c.Upsert(q, a)
+		return err
+	}
+
+	if err := db.ExecuteMGO(context, Collection, f); err != nil {
+		log.Error(context, "Upsert", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "Upsert", "Completed")
+	return nil
+}
+
+// =============================================================================
+
+// GetAll retrieves the list of alerts.
+func GetAll(context interface{}, db *db.DB) ([]Alert, error) {
+	log.Dev(context, "GetAll", "Started")
+
+	var alerts []Alert
+	f := func(c *mgo.Collection) error {
+		log.Dev(context, "GetAll", "MGO : db.%s.find({}).sort([\"name\"])", c.Name)
+		return c.Find(nil).Sort("name").All(&alerts)
+	}
+
+	if err := db.ExecuteMGO(context, Collection, f); err != nil {
+		log.Error(context, "GetAll", err, "Completed")
+		return nil, err
+	}
+
+	if alerts == nil {
+		log.Error(context, "GetAll", ErrNotFound, "Completed")
+		return nil, ErrNotFound
+	}
+
+	log.Dev(context, "GetAll", "Completed : Alerts[%d]", len(alerts))
+	return alerts, nil
+}
+
+// GetByName retrieves the document for the specified alert.
+func GetByName(context interface{}, db *db.DB, name string) (*Alert, error) {
+	log.Dev(context, "GetByName", "Started : Name[%s]", name)
+
+	var a Alert
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"name": name}
+		log.Dev(context, "GetByName", "MGO : db.%s.findOne(%s)", c.Name, mongo.Query(q))
+		return c.Find(q).One(&a)
+	}
+
+	if err := db.ExecuteMGO(context, Collection, f); err != nil {
+		if err == mgo.ErrNotFound {
+			err = ErrNotFound
+		}
+
+		log.Error(context, "GetByName", err, "Completed")
+		return nil, err
+	}
+
+	log.Dev(context, "GetByName", "Completed : Alert[%s]", a.Name)
+	return &a, nil
+}
+
+// GetHistory retrieves the most recent state changes of the specified
+// alert, newest first.
+func GetHistory(context interface{}, db *db.DB, name string, limit int) ([]Event, error) {
+	log.Dev(context, "GetHistory", "Started : Name[%s] Limit[%d]", name, limit)
+
+	var events []Event
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"alert": name}
+		log.Dev(context, "GetHistory", "MGO : db.%s.find(%s).sort([\"-date\"]).limit(%d)", c.Name, mongo.Query(q), limit)
+		return c.Find(q).Sort("-date").Limit(limit).All(&events)
+	}
+
+	if err := db.ExecuteMGO(context, CollectionHistory, f); err != nil {
+		log.Error(context, "GetHistory", err, "Completed")
+		return nil, err
+	}
+
+	if events == nil {
+		events = []Event{}
+	}
+
+	log.Dev(context, "GetHistory", "Completed : Events[%d]", len(events))
+	return events, nil
+}
+
+// =============================================================================
+
+// Delete is used to remove an existing alert document. The history of the
+// alert is kept.
+func Delete(context interface{}, db *db.DB, name string) error {
+	log.Dev(context, "Delete", "Started : Name[%s]", name)
+
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"name": name}
+		log.Dev(context, "Delete", "MGO : db.%s.remove(%s)", c.Name, mongo.Query(q))
+		return c.Remove(q)
+	}
+
+	if err := db.ExecuteMGO(context, Collection, f); err != nil {
+		if err == mgo.ErrNotFound {
+			err = ErrNotFound
+		}
+
+		log.Error(context, "Delete", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "Delete", "Completed")
+	return nil
+}
@@ -0,0 +1,314 @@
+package alert_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ardanlabs/kit/cfg"
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/xenia/alert"
+	"github.com/coralproject/shelf/internal/xenia/query"
+	"github.com/coralproject/shelf/internal/xenia/query/qfix"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// prefix is what we are looking to delete after the test.
+const prefix = "ATEST_O"
+
+func TestMain(m *testing.M) {
+	os.Exit(runTest(m))
+}
+
+// runTest initializes the environment for the tests and allows for
+// the proper return code if the test fails or succeeds.
+func runTest(m *testing.M) int {
+
+	// Initialize the configuration and logging systems. Plus anything
+	// else the web app layer needs.
+	tests.Init("XENIA")
+
+	// The webhooks of the tests are served on the loopback address.
+	alert.AllowPrivateWebhooks(true)
+
+	// Initialize MongoDB using the `tests.TestSession` as the name of the
+	// master session.
+	if err := db.RegMasterSession(tests.Context, tests.TestSession, cfg.MustURL("MONGO_URI").String(), 0); err != nil {
+		fmt.Println("Can't register master session: " + err.Error())
+		return 1
+	}
+
+	return m.Run()
+}
+
+//==============================================================================
+
+// setup initializes for each indivdual test.
+func setup(t *testing.T) *db.DB {
+	tests.ResetLog()
+
+	db, err := db.NewMGO(tests.Context, tests.TestSession)
+	if err != nil {
+		t.Fatalf("%s\tShould be able to get a Mongo session : %v", tests.Failed, err)
+	}
+
+	// The set counts the documents in a collection that does not exist, so
+	// it always returns no documents.
+	set := query.Set{
+		Name:    prefix + "_count",
+		Enabled: true,
+		Queries: []query.Query{
+			{
+				Name:       "Count",
+				Type:       query.TypePipeline,
+				Collection: "test_xenia_alert_empty",
+				Return:     true,
+				Commands: []map[string]interface{}{
+					{"$group": map[string]interface{}{"_id": nil, "count": map[string]interface{}{"$sum": 1}}},
+				},
+			},
+		},
+	}
+
+	if err := qfix.Add(db, &set); err != nil {
+		t.Fatalf("%s\tShould be able to add the query set : %v", tests.Failed, err)
+	}
+
+	return db
+}
+
+// teardown deinitializes for each indivdual test.
+func teardown(t *testing.T, db *db.DB) {
+	if err := qfix.Remove(db, prefix); err != nil {
+		t.Fatalf("%s\tShould be able to remove the query set : %v", tests.Failed, err)
+	}
+
+	for _, col := range []string{alert.Collection, alert.CollectionHistory} {
+		f := func(c *mgo.Collection) error {
+			_, err := c.RemoveAll(bson.M{"name": bson.RegEx{Pattern: prefix}})
+			if err != nil {
+				return err
+			}
+			_, err = c.RemoveAll(bson.M{"alert": bson.RegEx{Pattern: prefix}})
+			return err
+		}
+
+		if err := db.ExecuteMGO(tests.Context, col, f); err != nil {
+			t.Fatalf("%s\tShould be able to remove the alerts : %v", tests.Failed, err)
+		}
+	}
+	t.Logf("%s\tShould be able to remove the alerts.", tests.Success)
+
+	db.CloseMGO(tests.Context)
+
+	tests.DisplayLog()
+}
+
+//==============================================================================
+
+// TestEvaluate tests the evaluation of an alert and the delivery of the
+// state change to its webhook.
+func TestEvaluate(t *testing.T) {
+	db := setup(t)
+	defer teardown(t, db)
+
+	var body []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(alert.SignatureHeader)
+	}))
+	defer srv.Close()
+
+	a := alert.Alert{
+		Name:     prefix + "_empty",
+		Set:      prefix + "_count",
+		Interval: "1m",
+		Condition: alert.Condition{
+			Field: "count",
+			Op:    alert.OpLT,
+			Value: 1,
+		},
+		Webhook: srv.URL,
+		Secret:  "secret",
+		Enabled: true,
+	}
+
+	t.Log("Given the need to evaluate alerts.")
+	{
+		t.Log("\tWhen using an alert on a set returning no documents")
+		{
+			if err := alert.Upsert(tests.Context, db, &a); err != nil {
+				t.Fatalf("\t%s\tShould be able to save the alert : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to save the alert.", tests.Success)
+
+			ev, err := alert.Evaluate(tests.Context, db, &a)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to evaluate the alert : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to evaluate the alert.", tests.Success)
+
+			if ev == nil || ev.State != alert.StateFiring {
+				t.Fatalf("\t%s\tShould fire the alert : %+v", tests.Failed, ev)
+			}
+			t.Logf("\t%s\tShould fire the alert.", tests.Success)
+
+			if !ev.Delivered {
+				t.Fatalf("\t%s\tShould deliver the event : %s", tests.Failed, ev.Error)
+			}
+			t.Logf("\t%s\tShould deliver the event.", tests.Success)
+
+			var posted alert.Event
+			if err := json.Unmarshal(body, &posted); err != nil || posted.Alert != a.Name {
+				t.Fatalf("\t%s\tShould post the event to the webhook : %s", tests.Failed, body)
+			}
+			t.Logf("\t%s\tShould post the event to the webhook.", tests.Success)
+
+			if signature != "sha256="+alert.Sign(a.Secret, body) {
+				t.Fatalf("\t%s\tShould sign the payload : %s", tests.Failed, signature)
+			}
+			t.Logf("\t%s\tShould sign the payload.", tests.Success)
+
+			ev, err = alert.Evaluate(tests.Context, db, &a)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to evaluate the alert again : %s", tests.Failed, err)
+			}
+
+			if ev != nil {
+				t.Fatalf("\t%s\tShould not fire the alert again : %+v", tests.Failed, ev)
+			}
+			t.Logf("\t%s\tShould not fire the alert again.", tests.Success)
+
+			events, err := alert.GetHistory(tests.Context, db, a.Name, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to get the history : %s", tests.Failed, err)
+			}
+
+			if len(events) != 1 {
+				t.Fatalf("\t%s\tShould have one event in the history : %d", tests.Failed, len(events))
+			}
+			t.Logf("\t%s\tShould have one event in the history.", tests.Success)
+		}
+	}
+}
+
+// TestRedeliver tests retrying the delivery of an event the webhook did not
+// accept.
+func TestRedeliver(t *testing.T) {
+	db := setup(t)
+	defer teardown(t, db)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	a := alert.Alert{
+		Name:     prefix + "_retry",
+		Set:      prefix + "_count",
+		Interval: "1m",
+		Condition: alert.Condition{
+			Field: "count",
+			Op:    alert.OpLT,
+			Value: 1,
+		},
+		Webhook: srv.URL,
+		Enabled: true,
+	}
+
+	t.Log("Given the need to redeliver events.")
+	{
+		t.Log("\tWhen the webhook fails the first delivery")
+		{
+			if err := alert.Upsert(tests.Context, db, &a); err != nil {
+				t.Fatalf("\t%s\tShould be able to save the alert : %s", tests.Failed, err)
+			}
+
+			ev, err := alert.Evaluate(tests.Context, db, &a)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to evaluate the alert : %s", tests.Failed, err)
+			}
+
+			if ev == nil || ev.Delivered || ev.RetryAt.IsZero() {
+				t.Fatalf("\t%s\tShould schedule another delivery : %+v", tests.Failed, ev)
+			}
+			t.Logf("\t%s\tShould schedule another delivery.", tests.Success)
+
+			if err := alert.Redeliver(tests.Context, db, ev.Date); err != nil {
+				t.Fatalf("\t%s\tShould be able to redeliver events : %s", tests.Failed, err)
+			}
+
+			if calls != 1 {
+				t.Fatalf("\t%s\tShould not redeliver before the retry is due : %d", tests.Failed, calls)
+			}
+			t.Logf("\t%s\tShould not redeliver before the retry is due.", tests.Success)
+
+			if err := alert.Redeliver(tests.Context, db, ev.RetryAt); err != nil {
+				t.Fatalf("\t%s\tShould be able to redeliver events : %s", tests.Failed, err)
+			}
+
+			events, err := alert.GetHistory(tests.Context, db, a.Name, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to get the history : %s", tests.Failed, err)
+			}
+
+			if len(events) != 1 || !events[0].Delivered || events[0].Attempts != 2 || calls != 2 {
+				t.Fatalf("\t%s\tShould deliver the event on the second attempt : %+v", tests.Failed, events)
+			}
+			t.Logf("\t%s\tShould deliver the event on the second attempt.", tests.Success)
+		}
+	}
+}
+
+// TestValidateWebhook tests rejecting webhooks pointing to internal
+// addresses.
+func TestValidateWebhook(t *testing.T) {
+	alert.AllowPrivateWebhooks(false)
+	defer alert.AllowPrivateWebhooks(true)
+
+	a := alert.Alert{
+		Name:     prefix + "_webhook",
+		Set:      prefix + "_count",
+		Interval: "1m",
+		Condition: alert.Condition{
+			Field: "count",
+			Op:    alert.OpLT,
+			Value: 1,
+		},
+		Enabled: true,
+	}
+
+	t.Log("Given the need to validate the webhook of an alert.")
+	{
+		t.Log("\tWhen the webhook points to an internal address")
+		{
+			for _, webhook := range []string{"http://127.0.0.1:8080/", "http://localhost/", "http://10.0.0.1/", "http://[::1]/", "http://169.254.169.254/", "ftp://example.com/", "http:///"} {
+				a.Webhook = webhook
+				if err := a.Validate(); err != alert.ErrWebhook {
+					t.Fatalf("\t%s\tShould reject the webhook %q : %v", tests.Failed, webhook, err)
+				}
+			}
+			t.Logf("\t%s\tShould reject the webhooks.", tests.Success)
+		}
+
+		t.Log("\tWhen the webhook points to a public address")
+		{
+			a.Webhook = "https://example.com/hook"
+			if err := a.Validate(); err != nil {
+				t.Fatalf("\t%s\tShould accept the webhook : %v", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould accept the webhook.", tests.Success)
+		}
+	}
+}
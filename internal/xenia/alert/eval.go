@@ -0,0 +1,382 @@
+package alert
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
+	"github.com/coralproject/shelf/internal/xenia"
+	"github.com/coralproject/shelf/internal/xenia/query"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SignatureHeader is the header containing the signature of the payloads
+// posted to webhooks. It is the hex encoded HMAC-SHA256 of the body keyed
+// by the secret of the alert.
+const SignatureHeader = "X-Shelf-Signature"
+
+// Set of values controlling the redelivery of events the webhook of their
+// alert did not accept. The delay doubles after every failed attempt.
+const (
+	maxAttempts = 5
+	retryDelay  = time.Minute
+)
+
+//==============================================================================
+
+// Start evaluates the alerts that are due every tick in the background. The
+// database sessions are taken from the named master session.
+func Start(context interface{}, session string, tick time.Duration) {
+	log.Dev(context, "Start", "Started : Tick[%v]", tick)
+
+	go func() {
+		for range time.Tick(tick) {
+			evaluateDue(context, session, time.Now())
+		}
+	}()
+
+	log.Dev(context, "Start", "Completed")
+}
+
+// evaluateDue evaluates every alert that is due at the specified time.
+func evaluateDue(context interface{}, session string, now time.Time) {
+	mgoDB, err := db.NewMGO(context, session)
+	if err != nil {
+		log.Error(context, "evaluateDue", err, "Getting Mongo session")
+		return
+	}
+	defer mgoDB.CloseMGO(context)
+
+	// Sets executed on views require the graph.
+	if err := mgoDB.NewCayley(context, session); err != nil {
+		log.Error(context, "evaluateDue", err, "Getting Cayley handle")
+	} else {
+		defer mgoDB.CloseCayley(context)
+	}
+
+	if err := Redeliver(context, mgoDB, now); err != nil {
+		log.Error(context, "evaluateDue", err, "Redelivering events")
+	}
+
+	alerts, err := GetAll(context, mgoDB)
+	if err != nil {
+		if err != ErrNotFound {
+			log.Error(context, "evaluateDue", err, "Getting alerts")
+		}
+		return
+	}
+
+	for i := range alerts {
+		if !alerts[i].Due(now) {
+			continue
+		}
+
+		if _, err := Evaluate(context, mgoDB, &alerts[i]); err != nil {
+			log.Error(context, "evaluateDue", err, "Evaluating alert %s", alerts[i].Name)
+		}
+	}
+}
+
+//==============================================================================
+
+// Evaluate executes the query set of the alert and checks the condition. If
+// the state of the alert changes, the change is recorded in the history and
+// posted to the webhook. The event is returned, or nil if the state did not
+// change. Only one evaluator can record a given change of state, so alerts
+// evaluated by multiple processes are not delivered twice.
+func Evaluate(context interface{}, mgoDB *db.DB, a *Alert) (*Event, error) {
+	log.Dev(context, "Evaluate", "Started : Name[%s]", a.Name)
+
+	now := time.Now()
+
+	value, err := evaluateSet(context, mgoDB, a)
+	if err != nil {
+
+		// Push back the next evaluation so a failing set does not run
+		// on every tick.
+		if uerr := updateState(context, mgoDB, a, a.State, now); uerr != nil {
+			log.Error(context, "Evaluate", uerr, "Updating last evaluation")
+		}
+
+		log.Error(context, "Evaluate", err, "Completed")
+		return nil, err
+	}
+
+	state := StateOK
+	if a.Condition.Met(value) {
+		state = StateFiring
+	}
+
+	if err := updateState(context, mgoDB, a, state, now); err != nil {
+		if err == mgo.ErrNotFound {
+			log.Dev(context, "Evaluate", "Completed : Changed by another evaluator")
+			return nil, nil
+		}
+
+		log.Error(context, "Evaluate", err, "Completed")
+		return nil, err
+	}
+
+	// Nothing to report if the state did not change.
+	if state == a.State {
+		log.Dev(context, "Evaluate", "Completed : State[%s] Value[%v]", state, value)
+		return nil, nil
+	}
+
+	ev := Event{
+		Alert:     a.Name,
+		Set:       a.Set,
+		State:     state,
+		Previous:  a.State,
+		Value:     value,
+		Condition: a.Condition,
+		Date:      now,
+	}
+
+	if a.Webhook != "" {
+		ev.Attempts = 1
+		if err := deliver(context, a, &ev); err != nil {
+			ev.Error = err.Error()
+			ev.RetryAt = now.Add(retryDelay)
+		} else {
+			ev.Delivered = true
+		}
+	}
+
+	f := func(c *mgo.Collection) error {
+		log.Dev(context, "Evaluate", "MGO : db.%s.insert(%s)", c.Name, mongo.Query(ev))
+		return c.Insert(&ev)
+	}
+
+	if err := mgoDB.ExecuteMGO(context, CollectionHistory, f); err != nil {
+		log.Error(context, "Evaluate", err, "Completed")
+		return nil, err
+	}
+
+	a.State = state
+	a.LastEval = now
+	a.LastChange = now
+
+	log.Dev(context, "Evaluate", "Completed : State[%s] Previous[%s] Value[%v]", ev.State, ev.Previous, value)
+	return &ev, nil
+}
+
+// evaluateSet executes the query set of the alert and returns the value
+// of the field the condition is checked against.
+func evaluateSet(context interface{}, mgoDB *db.DB, a *Alert) (float64, error) {
+	set, err := query.GetByName(context, mgoDB, a.Set)
+	if err != nil {
+		return 0, err
+	}
+
+	vars := make(map[string]string, len(a.Params))
+	for k, v := range a.Params {
+		vars[k] = v
+	}
+
+	result := xenia.Exec(context, mgoDB, set, vars)
+
+	docs, err := xenia.ResultDocs(result, a.Condition.Query)
+	if err != nil {
+		return 0, err
+	}
+
+	// No documents is treated as nothing to count.
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	return fieldValue(docs[0], a.Condition.Field)
+}
+
+// updateState records the evaluation of the alert. The update only applies
+// if the alert is still in the state it was read with.
+func updateState(context interface{}, mgoDB *db.DB, a *Alert, state string, now time.Time) error {
+	set := bson.M{"state": state, "last_eval": now}
+	if state != a.State {
+		set["last_change"] = now
+	}
+
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"name": a.Name, "state": a.State}
+		u := bson.M{"$set": set}
+		log.Dev(context, "updateState", "MGO : db.%s.update(%s, %s)", c.Name, mongo.Query(q), mongo.Query(u))
+		return c.Update(q, u)
+	}
+
+	return mgoDB.ExecuteMGO(context, Collection, f)
+}
+
+// fieldValue returns the numeric value of the field in dot notation.
+func fieldValue(doc map[string]interface{}, field string) (float64, error) {
+	var v interface{} = doc
+	for _, key := range strings.Split(field, ".") {
+		switch m := v.(type) {
+		case bson.M:
+			v = m[key]
+		case map[string]interface{}:
+			v = m[key]
+		default:
+			return 0, fmt.Errorf("Field %q not found", field)
+		}
+	}
+
+	switch n := v.(type) {
+	case int:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case float32:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	case nil:
+		return 0, fmt.Errorf("Field %q not found", field)
+	}
+
+	return 0, fmt.Errorf("Field %q is a %T but must be a number", field, v)
+}
+
+//==============================================================================
+
+// Redeliver posts the events whose delivery failed and are due for another
+// attempt at the specified time to the webhook of their alert. An event is
+// attempted at most maxAttempts times, and only one evaluator makes a given
+// attempt. Events of removed alerts, or alerts without a webhook, are given up.
+func Redeliver(context interface{}, mgoDB *db.DB, now time.Time) error {
+	log.Dev(context, "Redeliver", "Started")
+
+	var events []Event
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"delivered": false, "retry_at": bson.M{"$lte": now}}
+		log.Dev(context, "Redeliver", "MGO : db.%s.find(%s)", c.Name, mongo.Query(q))
+		return c.Find(q).All(&events)
+	}
+
+	if err := mgoDB.ExecuteMGO(context, CollectionHistory, f); err != nil {
+		log.Error(context, "Redeliver", err, "Completed")
+		return err
+	}
+
+	var delivered int
+	for i := range events {
+		ev := &events[i]
+
+		a, err := GetByName(context, mgoDB, ev.Alert)
+		if err != nil && err != ErrNotFound {
+			log.Error(context, "Redeliver", err, "Completed")
+			return err
+		}
+
+		// Claim the attempt, giving up the event once there is nothing to
+		// deliver it to or no attempt left.
+		set := bson.M{"attempts": ev.Attempts + 1}
+		upd := bson.M{"$set": set}
+		if a == nil || a.Webhook == "" || ev.Attempts+1 >= maxAttempts {
+			upd["$unset"] = bson.M{"retry_at": ""}
+		} else {
+			set["retry_at"] = now.Add(retryDelay << uint(ev.Attempts))
+		}
+
+		if err := updateEvent(context, mgoDB, ev, upd); err != nil {
+			if err == mgo.ErrNotFound {
+				continue
+			}
+			log.Error(context, "Redeliver", err, "Completed")
+			return err
+		}
+
+		if a == nil || a.Webhook == "" {
+			continue
+		}
+
+		ev.Attempts++
+		ev.Error = ""
+		ev.RetryAt = time.Time{}
+
+		upd = bson.M{"$set": bson.M{"delivered": true}, "$unset": bson.M{"error": "", "retry_at": ""}}
+		if err := deliver(context, a, ev); err != nil {
+			upd = bson.M{"$set": bson.M{"error": err.Error()}}
+		} else {
+			delivered++
+		}
+
+		if err := updateEvent(context, mgoDB, ev, upd); err != nil && err != mgo.ErrNotFound {
+			log.Error(context, "Redeliver", err, "Completed")
+			return err
+		}
+	}
+
+	log.Dev(context, "Redeliver", "Completed : Events[%d] Delivered[%d]", len(events), delivered)
+	return nil
+}
+
+// updateEvent applies the update to the event. The update only applies if
+// the event is still at the attempts it was read with.
+func updateEvent(context interface{}, mgoDB *db.DB, ev *Event, upd bson.M) error {
+	f := func(c *mgo.Collection) error {
+		q := bson.M{"_id": ev.ID, "attempts": ev.Attempts}
+		log.Dev(context, "updateEvent", "MGO : db.%s.update(%s, %s)", c.Name, mongo.Query(q), mongo.Query(upd))
+		return c.Update(q, upd)
+	}
+
+	return mgoDB.ExecuteMGO(context, CollectionHistory, f)
+}
+
+// deliver posts the event to the webhook of the alert, signing the payload
+// with the secret of the alert.
+func deliver(context interface{}, a *Alert, ev *Event) error {
+	log.Dev(context, "deliver", "Started : Webhook[%s]", a.Webhook)
+
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Error(context, "deliver", err, "Completed")
+		return err
+	}
+
+	req, err := http.NewRequest("POST", a.Webhook, bytes.NewReader(body))
+	if err != nil {
+		log.Error(context, "deliver", err, "Completed")
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if a.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(a.Secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Error(context, "deliver", err, "Completed")
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("Webhook responded with status %d", resp.StatusCode)
+		log.Error(context, "deliver", err, "Completed")
+		return err
+	}
+
+	log.Dev(context, "deliver", "Completed")
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of the body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
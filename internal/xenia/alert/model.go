@@ -0,0 +1,151 @@
+package alert
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/bluesuncorp/validator.v8"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Set of states an alert can be in.
+const (
+	StateOK     = "ok"
+	StateFiring = "firing"
+)
+
+// Set of operators a condition can use to compare values.
+const (
+	OpGT  = "gt"
+	OpGTE = "gte"
+	OpLT  = "lt"
+	OpLTE = "lte"
+	OpEQ  = "eq"
+	OpNE  = "ne"
+)
+
+//==============================================================================
+
+// validate is used to perform model field validation.
+var validate *validator.Validate
+
+func init() {
+	validate = validator.New(&validator.Config{TagName: "validate"})
+}
+
+//==============================================================================
+
+// Condition describes when an alert fires. The field is read from the first
+// document returned by the query. A query returning no documents provides
+// a value of zero.
+type Condition struct {
+	Query string  `bson:"query,omitempty" json:"query,omitempty"` // Name of the query in the set, the first query when empty.
+	Field string  `bson:"field" json:"field" validate:"required"` // Dot notation path to the numeric field in the document.
+	Op    string  `bson:"op" json:"op" validate:"required"`       // OpGT, OpGTE, OpLT, OpLTE, OpEQ, OpNE
+	Value float64 `bson:"value" json:"value"`                     // Value the field is compared against.
+}
+
+// Validate checks the condition value for consistency.
+func (c *Condition) Validate() error {
+	if err := validate.Struct(c); err != nil {
+		return err
+	}
+
+	switch c.Op {
+	case OpGT, OpGTE, OpLT, OpLTE, OpEQ, OpNE:
+	default:
+		return fmt.Errorf("Invalid condition operator %q", c.Op)
+	}
+
+	return nil
+}
+
+// Met reports if the value meets the condition.
+func (c *Condition) Met(v float64) bool {
+	switch c.Op {
+	case OpGT:
+		return v > c.Value
+	case OpGTE:
+		return v >= c.Value
+	case OpLT:
+		return v < c.Value
+	case OpLTE:
+		return v <= c.Value
+	case OpEQ:
+		return v == c.Value
+	case OpNE:
+		return v != c.Value
+	}
+
+	return false
+}
+
+//==============================================================================
+
+// Alert contains the configuration details for an alert driven by the
+// results of a query set.
+type Alert struct {
+	Name        string            `bson:"name" json:"name" validate:"required,min=3"`         // Unique name of the alert.
+	Description string            `bson:"desc,omitempty" json:"desc,omitempty"`               // Description of the alert.
+	Set         string            `bson:"set" json:"set" validate:"required,min=3"`           // Name of the query set to execute.
+	Params      map[string]string `bson:"params,omitempty" json:"params,omitempty"`           // Parameters to execute the set with.
+	Interval    string            `bson:"interval" json:"interval" validate:"required"`       // How often the alert is evaluated, ie 5m.
+	Condition   Condition         `bson:"condition" json:"condition"`                         // Condition under which the alert fires.
+	Webhook     string            `bson:"webhook,omitempty" json:"webhook,omitempty"`         // URL state changes are posted to.
+	Secret      string            `bson:"secret,omitempty" json:"secret,omitempty"`           // Key used to sign the payloads posted to the webhook.
+	Enabled     bool              `bson:"enabled" json:"enabled"`                             // If the alert is evaluated.
+	State       string            `bson:"state,omitempty" json:"state,omitempty"`             // Current state of the alert.
+	LastEval    time.Time         `bson:"last_eval,omitempty" json:"last_eval,omitempty"`     // When the alert was last evaluated.
+	LastChange  time.Time         `bson:"last_change,omitempty" json:"last_change,omitempty"` // When the state of the alert last changed.
+}
+
+// Validate checks the alert value for consistency.
+func (a *Alert) Validate() error {
+	if err := validate.Struct(a); err != nil {
+		return err
+	}
+
+	if d, err := time.ParseDuration(a.Interval); err != nil || d <= 0 {
+		return fmt.Errorf("Invalid interval %q", a.Interval)
+	}
+
+	if err := a.Condition.Validate(); err != nil {
+		return err
+	}
+
+	if a.Webhook != "" {
+		if err := validateWebhook(a.Webhook); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Due reports if the alert needs to be evaluated at the specified time.
+func (a *Alert) Due(now time.Time) bool {
+	d, err := time.ParseDuration(a.Interval)
+	if err != nil {
+		return false
+	}
+
+	return a.Enabled && !now.Before(a.LastEval.Add(d))
+}
+
+//==============================================================================
+
+// Event contains the details of a change in the state of an alert.
+type Event struct {
+	ID        bson.ObjectId `bson:"_id,omitempty" json:"-"`                       // Identifies the event to redeliver.
+	Alert     string        `bson:"alert" json:"alert"`                           // Name of the alert.
+	Set       string        `bson:"set" json:"set"`                               // Name of the query set that was executed.
+	State     string        `bson:"state" json:"state"`                           // State the alert changed to.
+	Previous  string        `bson:"previous" json:"previous"`                     // State the alert changed from.
+	Value     float64       `bson:"value" json:"value"`                           // Value of the field when evaluated.
+	Condition Condition     `bson:"condition" json:"condition"`                   // Condition that was evaluated.
+	Date      time.Time     `bson:"date" json:"date"`                             // When the state changed.
+	Delivered bool          `bson:"delivered" json:"delivered"`                   // If the webhook accepted the payload.
+	Error     string        `bson:"error,omitempty" json:"error,omitempty"`       // Error delivering the payload.
+	Attempts  int           `bson:"attempts,omitempty" json:"attempts,omitempty"` // Deliveries attempted.
+	RetryAt   time.Time     `bson:"retry_at,omitempty" json:"retry_at,omitempty"` // When a failed delivery is attempted again.
+}
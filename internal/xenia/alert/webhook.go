@@ -0,0 +1,105 @@
+package alert
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrWebhook is returned when the webhook of an alert is not a valid url or
+// points to an address that is not allowed.
+var ErrWebhook = errors.New("Invalid webhook url")
+
+// webhooks holds if webhooks may point to loopback, private or link local
+// addresses. They can't by default so alerts can't be used to reach the
+// internal services of the server.
+var webhooks = struct {
+	sync.RWMutex
+	private bool
+}{}
+
+// AllowPrivateWebhooks sets if webhooks may point to loopback, private or
+// link local addresses.
+func AllowPrivateWebhooks(allow bool) {
+	webhooks.Lock()
+	{
+		webhooks.private = allow
+	}
+	webhooks.Unlock()
+}
+
+// privateAllowed reports if webhooks may point to internal addresses.
+func privateAllowed() bool {
+	webhooks.RLock()
+	defer webhooks.RUnlock()
+
+	return webhooks.private
+}
+
+// internalIP reports if the address is not routable on the internet.
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// validateWebhook checks the webhook is an http url with a host. Hosts that
+// are internal addresses are rejected here, names are checked once resolved
+// when the payload is posted.
+func validateWebhook(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrWebhook
+	}
+
+	if privateAllowed() {
+		return nil
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhook
+	}
+
+	if ip := net.ParseIP(host); ip != nil && internalIP(ip) {
+		return ErrWebhook
+	}
+
+	return nil
+}
+
+// dialWebhook refuses the connections to internal addresses, which the name
+// of a webhook may resolve to.
+func dialWebhook(network, address string, c syscall.RawConn) error {
+	if privateAllowed() {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || internalIP(ip) {
+		return ErrWebhook
+	}
+
+	return nil
+}
+
+// client is used to post payloads to webhooks. No proxy is used so the
+// addresses connected to are the ones checked.
+var client = http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: dialWebhook,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
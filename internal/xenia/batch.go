@@ -0,0 +1,99 @@
+package xenia
+
+import (
+	"sync"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/xenia/query"
+)
+
+// BatchSet contains a set to execute as part of a batch and the key its
+// result is returned under.
+type BatchSet struct {
+	Key  string
+	Set  *query.Set
+	Vars map[string]string
+}
+
+// batchWorkers is the number of sets of a batch executed at the same time.
+var batchWorkers = struct {
+	sync.RWMutex
+	n int
+}{
+	n: 4,
+}
+
+// SetBatchWorkers sets the number of sets of a batch executed at the same time.
+func SetBatchWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+
+	batchWorkers.Lock()
+	{
+		batchWorkers.n = n
+	}
+	batchWorkers.Unlock()
+}
+
+// ExecBatch executes the sets of the batch concurrently and returns the
+// result of each set by key. A set that fails only has an error in its own
+// result. Each worker runs on its own copy of the database sessions.
+func ExecBatch(context interface{}, db *db.DB, batch []BatchSet) map[string]*query.Result {
+	log.Dev(context, "ExecBatch", "Started : Sets[%d]", len(batch))
+
+	batchWorkers.RLock()
+	workers := batchWorkers.n
+	batchWorkers.RUnlock()
+
+	if workers > len(batch) {
+		workers = len(batch)
+	}
+
+	results := make(map[string]*query.Result, len(batch))
+	var mu sync.Mutex
+
+	sets := make(chan BatchSet)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			// Copying the sessions can only fail if the database is not
+			// valid, so every set of the batch reports the error.
+			wDB, err := db.Copy(context)
+			if err != nil {
+				for bs := range sets {
+					mu.Lock()
+					results[bs.Key] = errResult(context, err, "Copying sessions")
+					mu.Unlock()
+				}
+				return
+			}
+			defer wDB.CloseMGO(context)
+			defer wDB.CloseCayley(context)
+
+			for bs := range sets {
+				r := Exec(context, wDB, bs.Set, bs.Vars)
+
+				mu.Lock()
+				results[bs.Key] = r
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, bs := range batch {
+		sets <- bs
+	}
+	close(sets)
+
+	wg.Wait()
+
+	log.Dev(context, "ExecBatch", "Completed")
+	return results
+}
@@ -3,6 +3,7 @@ package mask
 import (
 	"fmt"
 
+	"github.com/coralproject/shelf/internal/platform/auth"
 	"gopkg.in/bluesuncorp/validator.v8"
 )
 
@@ -28,9 +29,10 @@ func init() {
 
 // Mask contains information about what needs to be masked.
 type Mask struct {
-	Collection string `bson:"collection" json:"collection" validate:"required"`
-	Field      string `bson:"field" json:"field" validate:"required"`
-	Type       string `bson:"type" json:"type" validate:"required,min=3"`
+	Collection string    `bson:"collection" json:"collection" validate:"required"`
+	Field      string    `bson:"field" json:"field" validate:"required"`
+	Type       string    `bson:"type" json:"type" validate:"required,min=3"`
+	ACL        *auth.ACL `bson:"acl,omitempty" json:"acl,omitempty"`
 }
 
 // Validate checks the set value for consistency.
@@ -11,9 +11,9 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
-// processMasks reviews the document for fields that are defined to have
-// their values masked.
-func processMasks(context interface{}, db *db.DB, collection string, results []bson.M) error {
+// ProcessMasks reviews the documents of the collection for fields that are
+// defined to have their values masked.
+func ProcessMasks(context interface{}, db *db.DB, collection string, results []bson.M) error {
 	masks, err := mask.GetByCollection(context, db, collection)
 	if err != nil {
 
@@ -36,9 +36,9 @@ func TestMaskingDelete(t *testing.T) {
 	t.Logf("Given the need to mask fields as deletes.")
 	{
 		masks := map[string]mask.Mask{
-			"station_id": {"*", "station_id", mask.MaskRemove},
-			"type":       {"*", "type", mask.MaskRemove},
-			"wind_dir":   {"*", "wind_dir", mask.MaskRemove},
+			"station_id": {Collection: "*", Field: "station_id", Type: mask.MaskRemove},
+			"type":       {Collection: "*", Field: "type", Type: mask.MaskRemove},
+			"wind_dir":   {Collection: "*", Field: "wind_dir", Type: mask.MaskRemove},
 		}
 
 		docs, err := fixtures()
@@ -92,9 +92,9 @@ func TestMaskingDelete(t *testing.T) {
 // TestMaskingAll tests the masking functionality for all.
 func TestMaskingAll(t *testing.T) {
 	masks := map[string]mask.Mask{
-		"station_id": {"*", "station_id", mask.MaskAll},
-		"type":       {"*", "type", mask.MaskAll},
-		"temp_f":     {"*", "temp_f", mask.MaskAll},
+		"station_id": {Collection: "*", Field: "station_id", Type: mask.MaskAll},
+		"type":       {Collection: "*", Field: "type", Type: mask.MaskAll},
+		"temp_f":     {Collection: "*", Field: "temp_f", Type: mask.MaskAll},
 	}
 
 	t.Logf("Given the need to mask fields as all.")
@@ -145,9 +145,9 @@ func TestMaskingAll(t *testing.T) {
 // TestMaskingLeft tests the masking functionality for left.
 func TestMaskingLeft(t *testing.T) {
 	masks := map[string]mask.Mask{
-		"station_id":         {"*", "station_id", mask.MaskLeft},
-		"temperature_string": {"*", "temperature_string", mask.MaskLeft},
-		"temp_f":             {"*", "temp_f", mask.MaskLeft},
+		"station_id":         {Collection: "*", Field: "station_id", Type: mask.MaskLeft},
+		"temperature_string": {Collection: "*", Field: "temperature_string", Type: mask.MaskLeft},
+		"temp_f":             {Collection: "*", Field: "temp_f", Type: mask.MaskLeft},
 	}
 
 	t.Logf("Given the need to mask fields as left.")
@@ -198,9 +198,9 @@ func TestMaskingLeft(t *testing.T) {
 // TestMaskingLeft8 tests the masking functionality for left8.
 func TestMaskingLeft8(t *testing.T) {
 	masks := map[string]mask.Mask{
-		"station_id":         {"*", "station_id", mask.MaskLeft + "8"},
-		"temperature_string": {"*", "temperature_string", mask.MaskLeft + "8"},
-		"temp_f":             {"*", "temp_f", mask.MaskLeft + "8"},
+		"station_id":         {Collection: "*", Field: "station_id", Type: mask.MaskLeft + "8"},
+		"temperature_string": {Collection: "*", Field: "temperature_string", Type: mask.MaskLeft + "8"},
+		"temp_f":             {Collection: "*", Field: "temp_f", Type: mask.MaskLeft + "8"},
 	}
 
 	t.Logf("Given the need to mask fields as left.")
@@ -251,9 +251,9 @@ func TestMaskingLeft8(t *testing.T) {
 // TestMaskingRight tests the masking functionality for right.
 func TestMaskingRight(t *testing.T) {
 	masks := map[string]mask.Mask{
-		"station_id":         {"*", "station_id", mask.MaskRight},
-		"temperature_string": {"*", "temperature_string", mask.MaskRight},
-		"temp_f":             {"*", "temp_f", mask.MaskRight},
+		"station_id":         {Collection: "*", Field: "station_id", Type: mask.MaskRight},
+		"temperature_string": {Collection: "*", Field: "temperature_string", Type: mask.MaskRight},
+		"temp_f":             {Collection: "*", Field: "temp_f", Type: mask.MaskRight},
 	}
 
 	t.Logf("Given the need to mask fields as left.")
@@ -304,9 +304,9 @@ func TestMaskingRight(t *testing.T) {
 // TestMaskingRight8 tests the masking functionality for right8.
 func TestMaskingRight8(t *testing.T) {
 	masks := map[string]mask.Mask{
-		"station_id":         {"*", "station_id", mask.MaskRight + "8"},
-		"temperature_string": {"*", "temperature_string", mask.MaskRight + "8"},
-		"temp_f":             {"*", "temp_f", mask.MaskRight + "8"},
+		"station_id":         {Collection: "*", Field: "station_id", Type: mask.MaskRight + "8"},
+		"temperature_string": {Collection: "*", Field: "temperature_string", Type: mask.MaskRight + "8"},
+		"temp_f":             {Collection: "*", Field: "temp_f", Type: mask.MaskRight + "8"},
 	}
 
 	t.Logf("Given the need to mask fields as left.")
@@ -357,9 +357,9 @@ func TestMaskingRight8(t *testing.T) {
 // TestMaskingEmail tests the masking functionality for email.
 func TestMaskingEmail(t *testing.T) {
 	masks := map[string]mask.Mask{
-		"station_id": {"*", "station_id", mask.MaskEmail},
-		"name":       {"*", "name", mask.MaskEmail},
-		"temp_f":     {"*", "temp_f", mask.MaskEmail},
+		"station_id": {Collection: "*", Field: "station_id", Type: mask.MaskEmail},
+		"name":       {Collection: "*", Field: "name", Type: mask.MaskEmail},
+		"temp_f":     {Collection: "*", Field: "temp_f", Type: mask.MaskEmail},
 	}
 
 	t.Logf("Given the need to mask fields as left.")
@@ -0,0 +1,254 @@
+// Package metrics records execution statistics for query sets and exposes
+// them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Buckets are the upper bounds in seconds of the latency histograms.
+var Buckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 25}
+
+// histogram counts observations of latency.
+type histogram struct {
+	counts []uint64 // One count per bucket.
+	count  uint64
+	sum    float64
+}
+
+// observe records the duration in the histogram.
+func (h *histogram) observe(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(Buckets))
+	}
+
+	s := d.Seconds()
+	for i, b := range Buckets {
+		if s <= b {
+			h.counts[i]++
+		}
+	}
+
+	h.count++
+	h.sum += s
+}
+
+// setStats contains the statistics for a query set.
+type setStats struct {
+	executions uint64
+	errors     uint64
+	latency    histogram
+}
+
+// queryKey identifies a query within a query set.
+type queryKey struct {
+	set   string
+	query string
+}
+
+// queryStats contains the statistics for a query within a query set.
+type queryStats struct {
+	executions uint64
+	errors     uint64
+	timeouts   uint64
+	documents  uint64
+	latency    histogram
+}
+
+// registry holds all the statistics recorded by the process.
+var registry = struct {
+	sync.Mutex
+	sets    map[string]*setStats
+	queries map[queryKey]*queryStats
+}{
+	sets:    make(map[string]*setStats),
+	queries: make(map[queryKey]*queryStats),
+}
+
+//==============================================================================
+
+// ObserveSet records the execution of a query set.
+func ObserveSet(set string, d time.Duration, failed bool) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	s, exists := registry.sets[set]
+	if !exists {
+		s = new(setStats)
+		registry.sets[set] = s
+	}
+
+	s.executions++
+	if failed {
+		s.errors++
+	}
+	s.latency.observe(d)
+}
+
+// ObserveQuery records the execution of a query within a query set.
+func ObserveQuery(set string, query string, d time.Duration, docs int, failed bool, timedOut bool) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	k := queryKey{set, query}
+	q, exists := registry.queries[k]
+	if !exists {
+		q = new(queryStats)
+		registry.queries[k] = q
+	}
+
+	q.executions++
+	if failed {
+		q.errors++
+	}
+	if timedOut {
+		q.timeouts++
+	}
+	q.documents += uint64(docs)
+	q.latency.observe(d)
+}
+
+// Reset removes all the recorded statistics.
+func Reset() {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.sets = make(map[string]*setStats)
+	registry.queries = make(map[queryKey]*queryStats)
+}
+
+//==============================================================================
+
+// Write writes the recorded statistics in the Prometheus text format.
+func Write(w io.Writer) error {
+	registry.Lock()
+	defer registry.Unlock()
+
+	// Sort the keys so the output is stable between scrapes.
+	sets := make([]string, 0, len(registry.sets))
+	for name := range registry.sets {
+		sets = append(sets, name)
+	}
+	sort.Strings(sets)
+
+	queries := make([]queryKey, 0, len(registry.queries))
+	for k := range registry.queries {
+		queries = append(queries, k)
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		if queries[i].set != queries[j].set {
+			return queries[i].set < queries[j].set
+		}
+		return queries[i].query < queries[j].query
+	})
+
+	pw := promWriter{w: w}
+
+	pw.header("xenia_set_executions_total", "counter", "Number of query set executions.")
+	for _, name := range sets {
+		pw.sample("xenia_set_executions_total", setLabels(name), float64(registry.sets[name].executions))
+	}
+
+	pw.header("xenia_set_errors_total", "counter", "Number of query set executions that failed.")
+	for _, name := range sets {
+		pw.sample("xenia_set_errors_total", setLabels(name), float64(registry.sets[name].errors))
+	}
+
+	pw.header("xenia_set_duration_seconds", "histogram", "Latency of query set executions.")
+	for _, name := range sets {
+		pw.histogram("xenia_set_duration_seconds", setLabels(name), &registry.sets[name].latency)
+	}
+
+	pw.header("xenia_query_executions_total", "counter", "Number of query executions.")
+	for _, k := range queries {
+		pw.sample("xenia_query_executions_total", queryLabels(k), float64(registry.queries[k].executions))
+	}
+
+	pw.header("xenia_query_errors_total", "counter", "Number of query executions that failed.")
+	for _, k := range queries {
+		pw.sample("xenia_query_errors_total", queryLabels(k), float64(registry.queries[k].errors))
+	}
+
+	pw.header("xenia_query_timeouts_total", "counter", "Number of query executions that timed out.")
+	for _, k := range queries {
+		pw.sample("xenia_query_timeouts_total", queryLabels(k), float64(registry.queries[k].timeouts))
+	}
+
+	pw.header("xenia_query_documents_total", "counter", "Number of documents returned by queries.")
+	for _, k := range queries {
+		pw.sample("xenia_query_documents_total", queryLabels(k), float64(registry.queries[k].documents))
+	}
+
+	pw.header("xenia_query_duration_seconds", "histogram", "Latency of query executions.")
+	for _, k := range queries {
+		pw.histogram("xenia_query_duration_seconds", queryLabels(k), &registry.queries[k].latency)
+	}
+
+	return pw.err
+}
+
+//==============================================================================
+
+// promWriter writes metrics in the Prometheus text format and holds on
+// to the first error that occurs.
+type promWriter struct {
+	w   io.Writer
+	err error
+}
+
+// header writes the help and type lines of a metric.
+func (pw *promWriter) header(name string, typ string, help string) {
+	pw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a single sample of a metric.
+func (pw *promWriter) sample(name string, labels string, v float64) {
+	pw.printf("%s{%s} %v\n", name, labels, v)
+}
+
+// histogram writes the bucket, sum and count samples of a histogram.
+func (pw *promWriter) histogram(name string, labels string, h *histogram) {
+	for i, b := range Buckets {
+		var c uint64
+		if h.counts != nil {
+			c = h.counts[i]
+		}
+		pw.printf("%s_bucket{%s,le=\"%v\"} %d\n", name, labels, b, c)
+	}
+
+	pw.printf("%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	pw.printf("%s_sum{%s} %v\n", name, labels, h.sum)
+	pw.printf("%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// printf writes the formatted string unless a previous write failed.
+func (pw *promWriter) printf(format string, a ...interface{}) {
+	if pw.err != nil {
+		return
+	}
+
+	_, pw.err = fmt.Fprintf(pw.w, format, a...)
+}
+
+// setLabels returns the labels identifying a query set.
+func setLabels(set string) string {
+	return fmt.Sprintf("set=\"%s\"", escape(set))
+}
+
+// queryLabels returns the labels identifying a query within a query set.
+func queryLabels(k queryKey) string {
+	return fmt.Sprintf("set=\"%s\",query=\"%s\"", escape(k.set), escape(k.query))
+}
+
+// labelEscaper escapes the characters not allowed in label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// escape returns the value escaped for use as a label value.
+func escape(v string) string {
+	return labelEscaper.Replace(v)
+}
@@ -0,0 +1,56 @@
+package metrics_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/xenia/metrics"
+)
+
+// TestWrite tests the exposition of recorded metrics.
+func TestWrite(t *testing.T) {
+	metrics.Reset()
+	defer metrics.Reset()
+
+	metrics.ObserveSet("MTEST_set", 30*time.Millisecond, false)
+	metrics.ObserveSet("MTEST_set", 2*time.Second, true)
+	metrics.ObserveQuery("MTEST_set", "MTEST_query", 30*time.Millisecond, 12, false, false)
+	metrics.ObserveQuery("MTEST_set", "MTEST_query", 2*time.Second, 0, true, true)
+
+	samples := []string{
+		`xenia_set_executions_total{set="MTEST_set"} 2`,
+		`xenia_set_errors_total{set="MTEST_set"} 1`,
+		`xenia_set_duration_seconds_bucket{set="MTEST_set",le="0.05"} 1`,
+		`xenia_set_duration_seconds_bucket{set="MTEST_set",le="+Inf"} 2`,
+		`xenia_set_duration_seconds_count{set="MTEST_set"} 2`,
+		`xenia_query_executions_total{set="MTEST_set",query="MTEST_query"} 2`,
+		`xenia_query_errors_total{set="MTEST_set",query="MTEST_query"} 1`,
+		`xenia_query_timeouts_total{set="MTEST_set",query="MTEST_query"} 1`,
+		`xenia_query_documents_total{set="MTEST_set",query="MTEST_query"} 12`,
+		`xenia_query_duration_seconds_bucket{set="MTEST_set",query="MTEST_query",le="2.5"} 2`,
+	}
+
+	t.Log("Given the need to expose execution metrics.")
+	{
+		t.Log("\tWhen executions have been recorded")
+		{
+			var buf bytes.Buffer
+			if err := metrics.Write(&buf); err != nil {
+				t.Fatalf("\t%s\tShould be able to write the metrics : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s\tShould be able to write the metrics.", tests.Success)
+
+			out := buf.String()
+			for _, s := range samples {
+				if !strings.Contains(out, s+"\n") {
+					t.Log(out)
+					t.Fatalf("\t%s\tShould contain the sample %s", tests.Failed, s)
+				}
+				t.Logf("\t%s\tShould contain the sample %s", tests.Success, s)
+			}
+		}
+	}
+}
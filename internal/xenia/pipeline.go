@@ -10,12 +10,18 @@ import (
 	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/coralproject/shelf/internal/platform/db/mongo"
 	"github.com/coralproject/shelf/internal/wire"
+	"github.com/coralproject/shelf/internal/xenia/policy"
 	"github.com/coralproject/shelf/internal/xenia/query"
-	"github.com/pborman/uuid"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
+// Set of errors returned when a pipeline times out.
+var (
+	errNetTimeout = errors.New("Completed : Timed out executing commands")
+	errTimeout    = errors.New("Timedout executing commands")
+)
+
 // execPipeline executes the sepcified pipeline query.
 func execPipeline(context interface{}, db *db.DB, q *query.Query, vars map[string]string, data map[string]interface{}, explain bool) (docs, []map[string]interface{}, error) {
 
@@ -63,17 +69,20 @@ func execPipeline(context interface{}, db *db.DB, q *query.Query, vars map[strin
 		agg += mongo.Query(command) + ",\n"
 	}
 
+	// Validate the pipeline is allowed by the policy now that the
+	// variables have been substituted.
+	if err := policy.Check(q.Name, q.Collection, commands); err != nil {
+		log.Error(context, "executePipeline", err, "Checking policy")
+		return docs{}, commands, err
+	}
+
 	// Are we being asked to execute the query on a view.
 	if q.Collection == "view" {
 
 		// Materialize the view for the query.
-		viewCol, err := materializeView(context, db, q, vars)
-		if err != nil {
+		if err := materializeView(context, db, q, vars); err != nil {
 			return docs{}, commands, err
 		}
-
-		// Defer clean up of the temporary collection.
-		defer cleanupView(context, db, viewCol)
 	}
 
 	// Do we want the explain output.
@@ -138,7 +147,7 @@ func execPipeline(context interface{}, db *db.DB, q *query.Query, vars map[strin
 		if err != nil {
 			if _, ok := err.(*net.OpError); ok {
 				log.Error(context, "executePipeline", err, "Timed out Network")
-				return docs{}, commands, errors.New("Completed : Timed out executing commands")
+				return docs{}, commands, errNetTimeout
 			}
 
 			log.Error(context, "executePipeline", err, "Completed")
@@ -147,9 +156,8 @@ func execPipeline(context interface{}, db *db.DB, q *query.Query, vars map[strin
 
 	// Wait to timeout the entire operation.
 	case <-time.After(timeout):
-		err := errors.New("Timedout executing commands")
-		log.Error(context, "executePipeline", err, "Completed : Timed out Processing")
-		return docs{}, commands, err
+		log.Error(context, "executePipeline", errTimeout, "Completed : Timed out Processing")
+		return docs{}, commands, errTimeout
 	}
 
 	log.Dev(context, "executePipeline", "Completed")
@@ -160,7 +168,7 @@ func execPipeline(context interface{}, db *db.DB, q *query.Query, vars map[strin
 	}
 
 	// Perform any masking that is required.
-	if err := processMasks(context, db, q.Collection, results); err != nil {
+	if err := ProcessMasks(context, db, q.Collection, results); err != nil {
 		return docs{}, commands, err
 	}
 
@@ -174,58 +182,35 @@ func execPipeline(context interface{}, db *db.DB, q *query.Query, vars map[strin
 	return docs{q.Name, results}, commands, nil
 }
 
-// materializeView executes a view, creates a temporary collection for the view, and
-// modifies the query to query the temporary collection.
-func materializeView(context interface{}, db *db.DB, q *query.Query, vars map[string]string) (string, error) {
+// materializeView retrieves the collection holding the materialized view and
+// modifies the query to query that collection.
+func materializeView(context interface{}, db *db.DB, q *query.Query, vars map[string]string) error {
 
 	// Make sure we have a valid connection to the graph.
 	graph, err := db.GraphHandle(context)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	// Make sure we have the information we need to execute the view.
 	viewName, ok := vars["view"]
 	if !ok {
-		return "", fmt.Errorf("Vars does not include \"view\".")
+		return fmt.Errorf("Vars does not include \"view\".")
 	}
 
 	itemKey, ok := vars["item"]
 	if !ok {
-		return "", fmt.Errorf("Vars does not include \"item\".")
+		return fmt.Errorf("Vars does not include \"item\".")
 	}
 
-	// Generate a unique name for the collection.
-	viewCol := uuid.New()
-
-	// Prepare the parameters for executing the view.
-	viewParams := wire.ViewParams{
-		ViewName:          viewName,
-		ItemKey:           itemKey,
-		ResultsCollection: viewCol,
-	}
-
-	// Execute the view.
-	if _, err := wire.Execute(context, db, graph, &viewParams); err != nil {
-		return viewCol, err
-	}
-
-	// Provide the query with the temporary collection name.
-	q.Collection = viewCol
-
-	return viewCol, nil
-}
-
-// cleanupView removed a tempory view collection.
-func cleanupView(context interface{}, db *db.DB, viewCol string) error {
-	c, err := db.CollectionMGO(context, viewCol)
+	// Get the collection holding the view, materializing it if required.
+	viewCol, err := wire.Materialize(context, db, graph, viewName, itemKey)
 	if err != nil {
 		return err
 	}
 
-	if err := c.DropCollection(); err != nil {
-		return err
-	}
+	// Provide the query with the view collection name.
+	q.Collection = viewCol
 
 	return nil
 }
@@ -0,0 +1,225 @@
+// Package policy provides the sandboxing rules applied to the pipelines of
+// query sets before they are saved or executed.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Policy describes what a pipeline is allowed to do. Empty lists place no
+// restriction on the pipeline. Collection names ending in "*" match any
+// collection with that prefix.
+type Policy struct {
+	AllowedStages      []string `json:"allowed_stages,omitempty"`      // Only these stages can be used.
+	ForbiddenStages    []string `json:"forbidden_stages,omitempty"`    // These stages can't be used.
+	ForbiddenOperators []string `json:"forbidden_operators,omitempty"` // These operators can't be used at any depth.
+	Collections        []string `json:"collections,omitempty"`         // Collections a query can be run against.
+	JoinCollections    []string `json:"join_collections,omitempty"`    // Collections a $lookup or $graphLookup can read from.
+	MaxStages          int      `json:"max_stages,omitempty"`          // Maximum number of stages in a pipeline.
+}
+
+// Default is the policy in use until another policy is set. It blocks writing
+// pipeline results to collections and the execution of server side JavaScript.
+var Default = Policy{
+	ForbiddenStages:    []string{"$out", "$merge"},
+	ForbiddenOperators: []string{"$where", "$function", "$accumulator"},
+}
+
+// current is the policy applied to pipelines.
+var current = struct {
+	sync.RWMutex
+	p Policy
+}{
+	p: Default,
+}
+
+// Set replaces the policy applied to pipelines.
+func Set(p Policy) {
+	current.Lock()
+	{
+		current.p = p
+	}
+	current.Unlock()
+}
+
+// Current returns the policy applied to pipelines.
+func Current() Policy {
+	current.RLock()
+	defer current.RUnlock()
+
+	return current.p
+}
+
+// Load reads a policy from the JSON document in the specified file.
+func Load(path string) (Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Policy{}, err
+	}
+	defer f.Close()
+
+	var p Policy
+	if err := json.NewDecoder(f).Decode(&p); err != nil {
+		return Policy{}, fmt.Errorf("Decoding policy %s : %v", path, err)
+	}
+
+	return p, nil
+}
+
+//==============================================================================
+
+// Error is returned when a pipeline violates the policy. It contains
+// every violation that was found.
+type Error struct {
+	Query      string
+	Violations []string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("Policy violation in query %q : %s", e.Query, strings.Join(e.Violations, ", "))
+}
+
+//==============================================================================
+
+// Check validates the pipeline for the named query against the current policy.
+func Check(name string, collection string, commands []map[string]interface{}) error {
+	p := Current()
+	return p.Check(name, collection, commands)
+}
+
+// Check validates the pipeline for the named query against the policy. The
+// extended $save command is not part of the pipeline and is ignored.
+func (p *Policy) Check(name string, collection string, commands []map[string]interface{}) error {
+	var violations []string
+
+	if len(p.Collections) > 0 && !matches(p.Collections, collection) {
+		violations = append(violations, fmt.Sprintf("collection %q can't be read", collection))
+	}
+
+	if l := len(commands); l > 0 {
+		if _, exists := commands[l-1]["$save"]; exists {
+			commands = commands[:l-1]
+		}
+	}
+
+	if p.MaxStages > 0 && len(commands) > p.MaxStages {
+		violations = append(violations, fmt.Sprintf("pipeline has %d stages, the maximum is %d", len(commands), p.MaxStages))
+	}
+
+	for _, command := range commands {
+		for stage := range command {
+			if len(p.AllowedStages) > 0 && !contains(p.AllowedStages, stage) {
+				violations = append(violations, fmt.Sprintf("stage %q is not allowed", stage))
+			}
+		}
+
+		violations = p.walk(command, violations)
+	}
+
+	if len(violations) > 0 {
+		return &Error{Query: name, Violations: violations}
+	}
+
+	return nil
+}
+
+// walk looks through the document at any depth for forbidden stages and
+// operators and for joins against collections that can't be read.
+func (p *Policy) walk(v interface{}, violations []string) []string {
+	switch doc := v.(type) {
+	case map[string]interface{}:
+		for key, value := range doc {
+			violations = p.checkKey(key, value, violations)
+			violations = p.walk(value, violations)
+		}
+
+	case bson.M:
+		return p.walk(map[string]interface{}(doc), violations)
+
+	case []map[string]interface{}:
+		for _, value := range doc {
+			violations = p.walk(value, violations)
+		}
+
+	case []interface{}:
+		for _, value := range doc {
+			violations = p.walk(value, violations)
+		}
+	}
+
+	return violations
+}
+
+// checkKey validates a single key of a document and its value.
+func (p *Policy) checkKey(key string, value interface{}, violations []string) []string {
+	if !strings.HasPrefix(key, "$") {
+		return violations
+	}
+
+	if contains(p.ForbiddenStages, key) {
+		violations = append(violations, fmt.Sprintf("stage %q is forbidden", key))
+	}
+
+	if contains(p.ForbiddenOperators, key) {
+		violations = append(violations, fmt.Sprintf("operator %q is forbidden", key))
+	}
+
+	switch key {
+	case "$lookup", "$graphLookup":
+		if len(p.JoinCollections) == 0 {
+			break
+		}
+
+		var from string
+		switch doc := value.(type) {
+		case map[string]interface{}:
+			from, _ = doc["from"].(string)
+		case bson.M:
+			from, _ = doc["from"].(string)
+		}
+
+		if !matches(p.JoinCollections, from) {
+			violations = append(violations, fmt.Sprintf("%s from collection %q is not allowed", key, from))
+		}
+	}
+
+	return violations
+}
+
+//==============================================================================
+
+// contains reports if the value is in the list.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches reports if the collection name matches any of the patterns.
+func matches(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+			continue
+		}
+
+		if p == name {
+			return true
+		}
+	}
+
+	return false
+}
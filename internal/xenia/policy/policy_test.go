@@ -0,0 +1,97 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/xenia/policy"
+)
+
+// TestCheck tests the validation of pipelines against a policy.
+func TestCheck(t *testing.T) {
+	p := policy.Policy{
+		ForbiddenStages:    []string{"$out"},
+		ForbiddenOperators: []string{"$where"},
+		Collections:        []string{"test_xenia_data", "view"},
+		JoinCollections:    []string{"test_xenia_*"},
+		MaxStages:          3,
+	}
+
+	pipelines := []struct {
+		name       string
+		collection string
+		commands   []map[string]interface{}
+		valid      bool
+	}{
+		{"allowed", "test_xenia_data", []map[string]interface{}{
+			{"$match": map[string]interface{}{"station_id": "42021"}},
+			{"$lookup": map[string]interface{}{"from": "test_xenia_users", "localField": "a", "foreignField": "b", "as": "c"}},
+			{"$limit": 10},
+			{"$save": map[string]interface{}{"$map": "list"}},
+		}, true},
+		{"out", "test_xenia_data", []map[string]interface{}{
+			{"$out": "users"},
+		}, false},
+		{"where", "test_xenia_data", []map[string]interface{}{
+			{"$match": map[string]interface{}{"$or": []interface{}{map[string]interface{}{"$where": "sleep(100)"}}}},
+		}, false},
+		{"collection", "users", []map[string]interface{}{
+			{"$limit": 10},
+		}, false},
+		{"join", "test_xenia_data", []map[string]interface{}{
+			{"$lookup": map[string]interface{}{"from": "users", "localField": "a", "foreignField": "b", "as": "c"}},
+		}, false},
+		{"length", "test_xenia_data", []map[string]interface{}{
+			{"$skip": 1}, {"$skip": 1}, {"$skip": 1}, {"$skip": 1},
+		}, false},
+	}
+
+	t.Log("Given the need to validate pipelines against a policy.")
+	{
+		for _, pl := range pipelines {
+			t.Logf("\tWhen using the %q pipeline", pl.name)
+			{
+				err := p.Check(pl.name, pl.collection, pl.commands)
+				if pl.valid {
+					if err != nil {
+						t.Fatalf("\t%s\tShould be allowed by the policy : %s", tests.Failed, err)
+					}
+					t.Logf("\t%s\tShould be allowed by the policy.", tests.Success)
+					continue
+				}
+
+				if err == nil {
+					t.Fatalf("\t%s\tShould be rejected by the policy.", tests.Failed)
+				}
+				t.Logf("\t%s\tShould be rejected by the policy.", tests.Success)
+
+				if _, ok := err.(*policy.Error); !ok {
+					t.Fatalf("\t%s\tShould return a policy error : %T", tests.Failed, err)
+				}
+				t.Logf("\t%s\tShould return a policy error : %s", tests.Success, err)
+			}
+		}
+	}
+}
+
+// TestDefault tests the default policy blocks writing pipeline results to
+// collections.
+func TestDefault(t *testing.T) {
+	t.Log("Given the need to sandbox pipelines by default.")
+	{
+		for _, stage := range []string{"$out", "$merge"} {
+			t.Logf("\tWhen using the %q stage", stage)
+			{
+				commands := []map[string]interface{}{
+					{"$match": map[string]interface{}{"station_id": "42021"}},
+					{stage: "users"},
+				}
+
+				if err := policy.Default.Check("write", "test_xenia_data", commands); err == nil {
+					t.Fatalf("\t%s\tShould be rejected by the default policy.", tests.Failed)
+				}
+				t.Logf("\t%s\tShould be rejected by the default policy.", tests.Success)
+			}
+		}
+	}
+}
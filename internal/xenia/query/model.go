@@ -3,6 +3,8 @@ package query
 import (
 	"errors"
 
+	"github.com/coralproject/shelf/internal/platform/auth"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
 	"gopkg.in/bluesuncorp/validator.v8"
 )
 
@@ -90,14 +92,15 @@ type Param struct {
 
 // Set contains the configuration details for a rule set.
 type Set struct {
-	Name        string  `bson:"name" json:"name" validate:"required,min=3"` // Name of the query set.
-	Description string  `bson:"desc" json:"desc"`                           // Description of the query set.
-	PreScript   string  `bson:"pre_script" json:"pre_script"`               // Name of a script document to prepend.
-	PstScript   string  `bson:"pst_script" json:"pst_script"`               // Name of a script document to append.
-	Params      []Param `bson:"params" json:"params"`                       // Collection of parameters.
-	Queries     []Query `bson:"queries" json:"queries"`                     // Collection of queries.
-	Enabled     bool    `bson:"enabled" json:"enabled"`                     // If the query set is enabled to run.
-	Explain     bool    `bson:"explain" json:"explain"`                     // If we want the explain output.
+	Name        string    `bson:"name" json:"name" validate:"required,min=3"` // Name of the query set.
+	Description string    `bson:"desc" json:"desc"`                           // Description of the query set.
+	PreScript   string    `bson:"pre_script" json:"pre_script"`               // Name of a script document to prepend.
+	PstScript   string    `bson:"pst_script" json:"pst_script"`               // Name of a script document to append.
+	Params      []Param   `bson:"params" json:"params"`                       // Collection of parameters.
+	Queries     []Query   `bson:"queries" json:"queries"`                     // Collection of queries.
+	Enabled     bool      `bson:"enabled" json:"enabled"`                     // If the query set is enabled to run.
+	Explain     bool      `bson:"explain" json:"explain"`                     // If we want the explain output.
+	ACL         *auth.ACL `bson:"acl,omitempty" json:"acl,omitempty"`         // Roles required to read, execute or write the set.
 }
 
 // Validate checks the set value for consistency.
@@ -121,7 +124,7 @@ func (s *Set) PrepareForInsert() {
 	// Fix the commands so it can be inserted.
 	for q := range s.Queries {
 		for c := range s.Queries[q].Commands {
-			prepareForInsert(s.Queries[q].Commands[c])
+			mongo.PrepareForInsert(s.Queries[q].Commands[c])
 		}
 	}
 }
@@ -132,7 +135,7 @@ func (s *Set) PrepareForUse() {
 	// Fix the commands so things are back to their orginal form.
 	for q := range s.Queries {
 		for c := range s.Queries[q].Commands {
-			prepareForUse(s.Queries[q].Commands[c])
+			mongo.PrepareForUse(s.Queries[q].Commands[c])
 		}
 	}
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/ardanlabs/kit/log"
 	"github.com/coralproject/shelf/internal/platform/db"
 	"github.com/coralproject/shelf/internal/platform/db/mongo"
+	"github.com/coralproject/shelf/internal/xenia/policy"
 	gc "github.com/patrickmn/go-cache"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -101,6 +102,14 @@ func Upsert(context interface{}, db *db.DB, set *Set) error {
 		return err
 	}
 
+	// Validate the pipelines are allowed by the policy.
+	for _, q := range set.Queries {
+		if err := policy.Check(q.Name, q.Collection, q.Commands); err != nil {
+			log.Error(context, "Upsert", err, "Completed")
+			return err
+		}
+	}
+
 	// We need to know if this is a new set.
 	var new bool
 	if _, err := GetByName(context, db, set.Name); err != nil {
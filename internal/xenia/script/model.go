@@ -3,6 +3,7 @@ package script
 import (
 	"errors"
 
+	"github.com/coralproject/shelf/internal/platform/auth"
 	"gopkg.in/bluesuncorp/validator.v8"
 )
 
@@ -19,6 +20,7 @@ func init() {
 type Script struct {
 	Name     string                   `bson:"name" json:"name" validate:"required,min=3"` // Unique name per Script document
 	Commands []map[string]interface{} `bson:"commands" json:"commands"`                   // Commands to add to a query.
+	ACL      *auth.ACL                `bson:"acl,omitempty" json:"acl,omitempty"`         // Roles required to read, execute or write the script.
 }
 
 // Validate checks the query value for consistency.
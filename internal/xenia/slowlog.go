@@ -0,0 +1,191 @@
+package xenia
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/kit/log"
+	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/platform/db/mongo"
+	"github.com/coralproject/shelf/internal/xenia/query"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SlowLogCollection is the capped Mongo collection holding slow queries.
+const SlowLogCollection = "query_slowlog"
+
+// slowLogSize is the maximum size in bytes of the slow query collection.
+const slowLogSize = 16 * 1024 * 1024
+
+// SlowQuery contains the details of a query that exceeded the threshold.
+type SlowQuery struct {
+	Set        string                   `bson:"set" json:"set"`
+	Query      string                   `bson:"query" json:"query"`
+	Collection string                   `bson:"collection" json:"collection"`
+	Commands   []map[string]interface{} `bson:"commands" json:"commands"`
+	Vars       map[string]string        `bson:"vars" json:"vars"`
+	Duration   float64                  `bson:"duration_ms" json:"duration_ms"`
+	Documents  int                      `bson:"documents" json:"documents"`
+	Error      string                   `bson:"error,omitempty" json:"error,omitempty"`
+	Date       time.Time                `bson:"date" json:"date"`
+}
+
+// slowLog holds the threshold after which a query is logged. A threshold
+// of zero disables the slow query log.
+var slowLog = struct {
+	sync.RWMutex
+	threshold time.Duration
+	created   bool
+}{}
+
+// SetSlowThreshold sets the duration after which a query is logged.
+func SetSlowThreshold(d time.Duration) {
+	slowLog.Lock()
+	{
+		slowLog.threshold = d
+	}
+	slowLog.Unlock()
+}
+
+// SlowLog returns the most recent slow queries, newest first.
+func SlowLog(context interface{}, db *db.DB, limit int) ([]SlowQuery, error) {
+	log.Dev(context, "SlowLog", "Started : Limit[%d]", limit)
+
+	var sqs []SlowQuery
+	f := func(c *mgo.Collection) error {
+		log.Dev(context, "SlowLog", "MGO : db.%s.find().sort({$natural: -1}).limit(%d)", c.Name, limit)
+		return c.Find(nil).Sort("-$natural").Limit(limit).All(&sqs)
+	}
+
+	if err := db.ExecuteMGO(context, SlowLogCollection, f); err != nil {
+		log.Error(context, "SlowLog", err, "Completed")
+		return nil, err
+	}
+
+	// Commands are stored with their keys replaced, so put them back.
+	for i := range sqs {
+		query.PrepareCommandsForUse(sqs[i].Commands)
+	}
+
+	log.Dev(context, "SlowLog", "Completed : Queries[%d]", len(sqs))
+	return sqs, nil
+}
+
+// recordSlowQuery saves the query into the slow query log if it ran for
+// longer than the threshold. Failing to save is logged but not reported
+// since it must not fail the execution of the query.
+func recordSlowQuery(context interface{}, db *db.DB, sq *SlowQuery, d time.Duration) {
+	slowLog.RLock()
+	threshold, created := slowLog.threshold, slowLog.created
+	slowLog.RUnlock()
+
+	if threshold == 0 || d < threshold {
+		return
+	}
+
+	log.Dev(context, "recordSlowQuery", "Started : Set[%s] Query[%s] Duration[%v]", sq.Set, sq.Query, d)
+
+	// Create the capped collection the first time we log a query.
+	if !created {
+		f := func(c *mgo.Collection) error {
+			info := mgo.CollectionInfo{Capped: true, MaxBytes: slowLogSize}
+			if err := c.Create(&info); err != nil && err.Error() != "collection already exists" {
+				return err
+			}
+			return nil
+		}
+
+		if err := db.ExecuteMGO(context, SlowLogCollection, f); err != nil {
+			log.Error(context, "recordSlowQuery", err, "Completed")
+			return
+		}
+
+		slowLog.Lock()
+		slowLog.created = true
+		slowLog.Unlock()
+	}
+
+	sq.Duration = float64(d) / float64(time.Millisecond)
+	sq.Date = time.Now()
+
+	// The commands may be shared with the cached query set and other
+	// executions, so fix a copy of them for the insert.
+	doc := *sq
+	doc.Commands = copyCommands(sq.Commands)
+	query.PrepareCommandsForInsert(doc.Commands)
+
+	f := func(c *mgo.Collection) error {
+		log.Dev(context, "recordSlowQuery", "MGO : db.%s.insert(%s)", c.Name, mongo.Query(doc))
+		return c.Insert(&doc)
+	}
+
+	if err := db.ExecuteMGO(context, SlowLogCollection, f); err != nil {
+		log.Error(context, "recordSlowQuery", err, "Completed")
+		return
+	}
+
+	log.Dev(context, "recordSlowQuery", "Completed")
+}
+
+// copyCommands returns a deep copy of the documents and arrays of the
+// commands.
+func copyCommands(commands []map[string]interface{}) []map[string]interface{} {
+	if commands == nil {
+		return nil
+	}
+
+	cmds := make([]map[string]interface{}, len(commands))
+	for i, cmd := range commands {
+		cmds[i] = copyValue(cmd).(map[string]interface{})
+	}
+
+	return cmds
+}
+
+// copyValue returns a deep copy of a value of a command.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = copyValue(value)
+		}
+		return m
+
+	case bson.M:
+		if v == nil {
+			return v
+		}
+		m := make(bson.M, len(v))
+		for key, value := range v {
+			m[key] = copyValue(value)
+		}
+		return m
+
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		a := make([]interface{}, len(v))
+		for i, value := range v {
+			a[i] = copyValue(value)
+		}
+		return a
+
+	case []map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		a := make([]map[string]interface{}, len(v))
+		for i, value := range v {
+			a[i] = copyValue(value).(map[string]interface{})
+		}
+		return a
+	}
+
+	return value
+}
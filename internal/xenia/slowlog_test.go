@@ -0,0 +1,47 @@
+package xenia
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kit/tests"
+	"github.com/coralproject/shelf/internal/xenia/query"
+)
+
+// TestCopyCommands tests preparing the commands of a slow query for insert
+// without changing the commands of the query set.
+func TestCopyCommands(t *testing.T) {
+	t.Log("Given the need to save the commands of a slow query.")
+	{
+		t.Log("\tWhen preparing a copy of the commands for insert")
+		{
+			commands := []map[string]interface{}{
+				{"$match": map[string]interface{}{
+					"data.name": "bilbo",
+					"$or":       []interface{}{map[string]interface{}{"type": "comment"}},
+				}},
+			}
+
+			cmds := copyCommands(commands)
+			query.PrepareCommandsForInsert(cmds)
+
+			if _, ok := cmds[0]["_$match"]; !ok {
+				t.Fatalf("\t%s\tShould prepare the copy : %v", tests.Failed, cmds)
+			}
+			t.Logf("\t%s\tShould prepare the copy.", tests.Success)
+
+			match, ok := commands[0]["$match"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("\t%s\tShould keep the keys of the commands : %v", tests.Failed, commands)
+			}
+
+			if _, ok := match["data.name"]; !ok {
+				t.Fatalf("\t%s\tShould keep the keys of the commands : %v", tests.Failed, commands)
+			}
+
+			if _, ok := match["$or"].([]interface{})[0].(map[string]interface{})["type"]; !ok {
+				t.Fatalf("\t%s\tShould keep the keys of the commands : %v", tests.Failed, commands)
+			}
+			t.Logf("\t%s\tShould keep the keys of the commands.", tests.Success)
+		}
+	}
+}
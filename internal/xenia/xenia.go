@@ -4,10 +4,13 @@ package xenia
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ardanlabs/kit/log"
 	"github.com/coralproject/shelf/internal/platform/db"
+	"github.com/coralproject/shelf/internal/xenia/metrics"
 	"github.com/coralproject/shelf/internal/xenia/query"
 	"github.com/coralproject/shelf/internal/xenia/script"
 	"gopkg.in/mgo.v2/bson"
@@ -29,6 +32,13 @@ var emptyResult []docs
 func Exec(context interface{}, db *db.DB, set *query.Set, vars map[string]string) *query.Result {
 	log.Dev(context, "Exec", "Started : Name[%s]", set.Name)
 
+	// Record the execution of the set once we are done.
+	start := time.Now()
+	failed := true
+	defer func() {
+		metrics.ObserveSet(set.Name, time.Since(start), failed)
+	}()
+
 	// Validate the set that is provided.
 	if err := set.Validate(); err != nil {
 		return errResult(context, err, "Validated")
@@ -67,10 +77,28 @@ func Exec(context interface{}, db *db.DB, set *query.Set, vars map[string]string
 		var err error
 
 		// We only have pipeline right now.
+		qStart := time.Now()
 		switch strings.ToLower(q.Type) {
 		case "pipeline":
 			result, commands, err = execPipeline(context, db, &q, vars, data, set.Explain)
 		}
+		d := time.Since(qStart)
+
+		// Record the execution of the query.
+		metrics.ObserveQuery(set.Name, q.Name, d, len(result.Docs), err != nil, err == errTimeout || err == errNetTimeout)
+
+		sq := SlowQuery{
+			Set:        set.Name,
+			Query:      q.Name,
+			Collection: q.Collection,
+			Commands:   commands,
+			Vars:       vars,
+			Documents:  len(result.Docs),
+		}
+		if err != nil {
+			sq.Error = err.Error()
+		}
+		recordSlowQuery(context, db, &sq, d)
 
 		// Was there an error processing the query.
 		if err != nil {
@@ -100,10 +128,61 @@ func Exec(context interface{}, db *db.DB, set *query.Set, vars map[string]string
 		Results: results,
 	}
 
+	failed = false
+
 	log.Dev(context, "Exec", "Completed")
 	return &r
 }
 
+// ResultDocs returns the documents returned by the named query in the result
+// of executing a set. An empty name returns the documents of the first query.
+func ResultDocs(result *query.Result, name string) ([]bson.M, error) {
+	switch r := result.Results.(type) {
+	case bson.M:
+		return nil, fmt.Errorf("%v", r["error"])
+
+	case []docs:
+		for _, d := range r {
+			if name == "" || d.Name == name {
+				return d.Docs, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("Result for query %q not found", name)
+}
+
+// ExecIDs executes the specified query set and returns the distinct values of
+// the field in the documents of its first returned query, such as the item
+// keys a view starts from. Documents without the field are skipped.
+func ExecIDs(context interface{}, db *db.DB, set *query.Set, vars map[string]string, field string) ([]string, error) {
+	log.Dev(context, "ExecIDs", "Started : Name[%s] Field[%s]", set.Name, field)
+
+	docs, err := ResultDocs(Exec(context, db, set, vars), "")
+	if err != nil {
+		log.Error(context, "ExecIDs", err, "Completed")
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var ids []string
+	for _, doc := range docs {
+		v, err := docFieldLookup(context, doc, field)
+		if err != nil {
+			continue
+		}
+
+		id := fmt.Sprintf("%v", v)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	log.Dev(context, "ExecIDs", "Completed : IDs[%d]", len(ids))
+	return ids, nil
+}
+
 // errResult creates a result value with the error.
 func errResult(context interface{}, err error, msg string) *query.Result {
 	r := query.Result{